@@ -0,0 +1,129 @@
+package main
+
+import (
+	"html"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/abigpotostew/endless/train"
+	"github.com/gorilla/mux"
+)
+
+// ampContentSecurityPolicy replaces the site policy on AMP pages, which must
+// load the AMP runtime from its CDN and use inline styles
+const ampContentSecurityPolicy = "default-src 'self'; script-src https://cdn.ampproject.org; style-src 'unsafe-inline'; img-src 'self' data:; worker-src blob:; base-uri 'self'; form-action 'self'; frame-ancestors 'none'"
+
+// ampBoilerplate is the required AMP boilerplate style, verbatim
+const ampBoilerplate = `<style amp-boilerplate>body{-webkit-animation:-amp-start 8s steps(1,end) 0s 1 normal both;-moz-animation:-amp-start 8s steps(1,end) 0s 1 normal both;-ms-animation:-amp-start 8s steps(1,end) 0s 1 normal both;animation:-amp-start 8s steps(1,end) 0s 1 normal both}@-webkit-keyframes -amp-start{from{visibility:hidden}to{visibility:visible}}@-moz-keyframes -amp-start{from{visibility:hidden}to{visibility:visible}}@-ms-keyframes -amp-start{from{visibility:hidden}to{visibility:visible}}@-o-keyframes -amp-start{from{visibility:hidden}to{visibility:visible}}@keyframes -amp-start{from{visibility:hidden}to{visibility:visible}}</style><noscript><style amp-boilerplate>body{-webkit-animation:none;-moz-animation:none;-ms-animation:none;animation:none}</style></noscript>`
+
+// ampHandler renders a post as a static AMP page. AMP pages can't stream or
+// run custom scripts, so the whole story is written at once.
+func (app *App) ampHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	seed, err := parsePostSeed(id)
+	if err != nil {
+		log.Printf("Invalid ID in URL %s: %v", r.URL.Path, err)
+		http.Error(w, "Invalid ID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Keep one AMP URL per story, like the regular post page
+	if app.redirectToCanonicalPost(w, r, id, seed, "/amp") {
+		return
+	}
+
+	story, err := app.generatePage(seed, "", "")
+	if err != nil {
+		log.Printf("Failed to generate AMP page for seed %d: %v", seed, err)
+		app.writeErrorPage(w, generationErrorStatus(err), "Something went wrong while writing this story.")
+		return
+	}
+	train.ObserveStoryWords(story.Content)
+
+	canonicalURL := app.baseURL(r) + story.Link.Url
+	articleLD := ArticleLD{
+		Context:       "https://schema.org",
+		Type:          "Article",
+		Headline:      story.Link.Title,
+		Description:   app.socialDescription(story.Content),
+		Image:         canonicalURL + "/og-image.jpg",
+		Author:        Person{Type: "Person", Name: story.Author, URL: app.baseURL(r) + "/author/" + train.AuthorSlug(story.Author)},
+		Publisher:     siteOrganization(app.baseURL(r)),
+		DatePublished: story.LastUpdated.Format("2006-01-02T15:04:05Z07:00"),
+		DateModified:  story.LastUpdated.Format("2006-01-02T15:04:05Z07:00"),
+		MainEntityOfPage: WebPage{
+			Type: "WebPage",
+			ID:   canonicalURL,
+		},
+		WordCount:      len(strings.Fields(story.Content)),
+		ArticleSection: "Fiction",
+		Keywords:       "story, fiction, narrative, creative writing, " + story.Author,
+	}
+
+	var paragraphsHTML strings.Builder
+	for _, paragraph := range story.Paragraphs {
+		paragraphsHTML.WriteString(`
+            <p>` + html.EscapeString(paragraph) + `</p>`)
+	}
+	var linksHTML strings.Builder
+	for _, link := range story.Links {
+		linksHTML.WriteString(`
+            <li><a href="` + html.EscapeString(sitePath(link.Url+"/amp")) + `">` + html.EscapeString(link.Title) + `</a></li>`)
+	}
+
+	if w.Header().Get("Content-Security-Policy") != "" {
+		w.Header().Set("Content-Security-Policy", ampContentSecurityPolicy)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write([]byte(`<!doctype html>
+<html ⚡ lang="` + html.EscapeString(app.siteLang) + `">
+<head>
+    <meta charset="utf-8">
+    <script async src="https://cdn.ampproject.org/v0.js"></script>
+    <title>` + html.EscapeString(story.Link.Title) + `</title>
+    <link rel="canonical" href="` + html.EscapeString(canonicalURL) + `">
+    <meta name="viewport" content="width=device-width">
+    <meta name="description" content="` + html.EscapeString(app.metaDescription(story.Content)) + `">
+    <script type="application/ld+json">
+    ` + jsonLDScript(articleLD) + `
+    </script>
+    ` + ampBoilerplate + `
+    <style amp-custom>
+        body {
+            font-family: Arial, sans-serif;
+            max-width: 800px;
+            margin: 0 auto;
+            padding: 20px;
+            line-height: 1.6;
+            color: #333;
+        }
+        a {
+            color: ` + app.themeColor(seed) + `;
+        }
+        .author {
+            color: #666;
+            font-size: 0.9em;
+        }
+        .links-list {
+            list-style: none;
+            padding: 0;
+        }
+        .links-list li {
+            margin: 10px 0;
+        }
+    </style>
+</head>
+<body>
+    <nav><a href="` + html.EscapeString(sitePath("/")) + `">Endless Stories</a></nav>
+    <article>
+        <h1>` + html.EscapeString(story.Link.Title) + `</h1>
+        <p class="author">By <a href="` + html.EscapeString(sitePath("/author/"+train.AuthorSlug(story.Author))) + `">` + html.EscapeString(story.Author) + `</a></p>` + paragraphsHTML.String() + `
+    </article>
+    <h2>Related Stories</h2>
+    <ul class="links-list">` + linksHTML.String() + `
+    </ul>
+</body>
+</html>`))
+}
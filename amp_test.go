@@ -0,0 +1,60 @@
+package main
+
+import (
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/abigpotostew/endless/train"
+	"github.com/gorilla/mux"
+)
+
+func TestAMPPage(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	app.publicHost = "https://example.com"
+	link, err := app.postLink(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := path.Base(link.Url)
+
+	before := train.StoryWordCounts().Count
+	req := httptest.NewRequest("GET", link.Url+"/amp", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rec := httptest.NewRecorder()
+	app.ampHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "<html ⚡") {
+		t.Error("the page isn't marked as AMP")
+	}
+	canonical := `<link rel="canonical" href="` + html.EscapeString("https://example.com"+link.Url) + `">`
+	if !strings.Contains(body, canonical) {
+		t.Errorf("the page has no canonical link to the post, want %s", canonical)
+	}
+	if !strings.Contains(body, html.EscapeString(link.Title)) {
+		t.Errorf("the page doesn't show the title %q", link.Title)
+	}
+	if n := train.StoryWordCounts().Count - before; n != 1 {
+		t.Errorf("serving the page recorded %d stories, want 1", n)
+	}
+
+	// A redirect serves no story, so it isn't recorded
+	before = train.StoryWordCounts().Count
+	req = httptest.NewRequest("GET", "/post/42/amp", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "42"})
+	rec = httptest.NewRecorder()
+	app.ampHandler(rec, req)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("a slugless id got status %d, want 302", rec.Code)
+	}
+	if n := train.StoryWordCounts().Count - before; n != 0 {
+		t.Errorf("redirecting recorded %d stories, want none", n)
+	}
+}
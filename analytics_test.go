@@ -0,0 +1,83 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnalyticsSettings(t *testing.T) {
+	const snippet = `<script src="https://cdn.example.com/a.js"></script>`
+	tests := []struct {
+		name       string
+		snippet    string
+		scriptHost string
+		countURL   string
+		wantHTML   string
+		wantHost   string
+		wantErr    bool
+	}{
+		{name: "off"},
+		{name: "goatcounter", countURL: "https://stats.example.com/count", wantHTML: "data-goatcounter", wantHost: "stats.example.com"},
+		{name: "snippet on its own origin", snippet: snippet, wantHTML: snippet},
+		{name: "snippet with script host", snippet: snippet, scriptHost: "https://cdn.example.com", wantHTML: snippet, wantHost: "https://cdn.example.com"},
+		{name: "snippet wins over goatcounter", snippet: snippet, scriptHost: "cdn.example.com", countURL: "https://stats.example.com/count", wantHTML: snippet, wantHost: "cdn.example.com"},
+		{name: "script host with a directive", snippet: snippet, scriptHost: "cdn.example.com; script-src *", wantErr: true},
+		{name: "goatcounter without a host", countURL: "/count", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, host, err := analyticsSettings(tt.snippet, tt.scriptHost, tt.countURL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !strings.Contains(html, tt.wantHTML) {
+				t.Errorf("html %q doesn't contain %q", html, tt.wantHTML)
+			}
+			if host != tt.wantHost {
+				t.Errorf("host is %q, want %q", host, tt.wantHost)
+			}
+		})
+	}
+}
+
+func TestContentSecurityPolicyAllowsScriptHost(t *testing.T) {
+	_, host, err := analyticsSettings(`<script src="https://cdn.example.com/a.js"></script>`, "https://cdn.example.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	csp := defaultContentSecurityPolicy(host)
+	for _, directive := range []string{"script-src 'self' https://cdn.example.com;", "connect-src 'self' https://cdn.example.com;"} {
+		if !strings.Contains(csp, directive) {
+			t.Errorf("policy %q lacks %q", csp, directive)
+		}
+	}
+}
+
+func TestContentSecurityPolicyAllowsPage(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	app.streamTimeout = time.Minute
+	story, err := app.generatePage(3, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	csp := defaultContentSecurityPolicy("https://gc.zgo.at")
+	// The page's inline <style> is allowed, but inline scripts never are
+	if !strings.Contains(csp, "style-src 'self' 'unsafe-inline';") || !strings.Contains(csp, "script-src 'self' https://gc.zgo.at;") {
+		t.Errorf("unexpected policy %q", csp)
+	}
+	page := getPost(app, story.Link.Url, "").Body.String()
+	if !strings.Contains(page, "<style>") {
+		t.Error("the page has no inline style to allow")
+	}
+	for _, script := range regexp.MustCompile(`<script[^>]*>`).FindAllString(page, -1) {
+		// JSON-LD is data, which the policy doesn't apply to
+		if !strings.Contains(script, "src=") && !strings.Contains(script, `type="application/ld+json"`) {
+			t.Errorf("the policy blocks inline %s", script)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"html"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/abigpotostew/endless/train"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// authorBioSentences is the length of a generated author bio
+	authorBioSentences = 3
+	// authorPostCount is how many posts an author page lists
+	authorPostCount = 6
+)
+
+// authorHandler renders an author's profile: a generated bio and a few of the
+// posts credited to them. Both are derived from the name, so the page is
+// stable for a given model.
+func (app *App) authorHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	author, ok := train.AuthorBySlug(train.Slugify(name))
+	if !ok {
+		app.writeErrorPage(w, http.StatusNotFound, "We don't know that writer, but there are plenty of other stories.")
+		return
+	}
+
+	// Send /author/Arlo%20Mills and similar to the canonical slug
+	if slug := train.AuthorSlug(author); name != slug {
+		http.Redirect(w, r, sitePath("/author/"+slug), http.StatusMovedPermanently)
+		return
+	}
+
+	chain, err := app.loadLatestChain()
+	if err != nil {
+		app.writeErrorPage(w, http.StatusServiceUnavailable, "Stories are temporarily unavailable. Please try again soon.")
+		return
+	}
+
+	bio, err := train.GenerateAuthorBio(chain, author, authorBioSentences)
+	if err != nil {
+		log.Printf("Failed to generate bio for %s: %v", author, err)
+		app.writeErrorPage(w, generationErrorStatus(err), "Something went wrong while writing this profile.")
+		return
+	}
+
+	posts := []train.GeneratedPage{}
+	for _, seed := range train.SeedsForAuthor(author, authorPostCount) {
+		post, err := train.GeneratePage(seed, chain)
+		if err != nil {
+			log.Printf("Failed to generate post %d for %s: %v", seed, author, err)
+			app.writeErrorPage(w, generationErrorStatus(err), "Something went wrong while writing this profile.")
+			return
+		}
+		posts = append(posts, post)
+	}
+
+	profileLD := ProfilePageLD{
+		Context: "https://schema.org",
+		Type:    "ProfilePage",
+		MainEntity: Person{
+			Type:        "Person",
+			Name:        author,
+			URL:         app.fullURL(r),
+			Description: bio,
+		},
+	}
+
+	var postsHTML strings.Builder
+	for _, post := range posts {
+		postsHTML.WriteString(`
+            <li>
+                <a href="` + html.EscapeString(sitePath(post.Link.Url)) + `">` + html.EscapeString(post.Link.Title) + `</a>
+                <p>` + html.EscapeString(app.excerpt(post.Content)) + `</p>
+            </li>`)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write([]byte(`<!DOCTYPE html>
+<html lang="` + html.EscapeString(app.siteLang) + `">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>` + html.EscapeString(author) + ` - Endless Stories</title>
+    <meta name="description" content="` + html.EscapeString(truncateString(bio, 160)) + `">
+    <meta property="og:type" content="profile">
+    <meta property="og:url" content="` + html.EscapeString(app.fullURL(r)) + `">
+    <meta property="og:title" content="` + html.EscapeString(author) + `">
+    <meta property="og:site_name" content="Endless Stories">
+    <meta property="og:locale" content="` + html.EscapeString(app.siteLocale) + `">
+    <link rel="canonical" href="` + html.EscapeString(app.fullURL(r)) + `">
+    <script type="application/ld+json">
+    ` + jsonLDScript(profileLD) + `
+    </script>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            max-width: 800px;
+            margin: 0 auto;
+            padding: 20px;
+            line-height: 1.6;
+            color: #333;
+        }
+        a {
+            color: #007cba;
+        }
+        .bio {
+            font-size: 1.1em;
+            margin-bottom: 30px;
+        }
+        .posts-list {
+            list-style: none;
+            padding: 0;
+        }
+        .posts-list li {
+            margin-bottom: 20px;
+        }
+        .posts-list a {
+            font-weight: bold;
+            text-decoration: none;
+        }
+        .posts-list p {
+            margin: 5px 0 0 0;
+            color: #666;
+        }
+    </style>
+</head>
+<body>
+    <nav><a href="` + html.EscapeString(sitePath("/")) + `">Endless Stories</a></nav>
+    <main itemscope itemtype="https://schema.org/Person">
+        <h1 itemprop="name">` + html.EscapeString(author) + `</h1>
+        <p class="bio" itemprop="description">` + html.EscapeString(bio) + `</p>
+        <h2>Stories by ` + html.EscapeString(author) + `</h2>
+        <ul class="posts-list">` + postsHTML.String() + `
+        </ul>
+    </main>
+</body>
+</html>`))
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abigpotostew/endless/train"
+	"github.com/gorilla/mux"
+)
+
+// getPost asks the post handler for target, a /post/ URL, as accept
+func getPost(app *App, target, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	id := strings.TrimPrefix(req.URL.Path, "/post/")
+	rec := httptest.NewRecorder()
+	app.generatePageStreamHandler(rec, mux.SetURLVars(req, map[string]string{"id": id}))
+	return rec
+}
+
+func TestRequestedAuthor(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	app.streamTimeout = time.Minute
+	authors := train.Authors()
+
+	for seed := int64(0); seed < 5; seed++ {
+		page, err := app.generatePage(seed, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Ask for someone other than the seed's own author
+		author := authors[0]
+		if author == page.Author {
+			author = authors[1]
+		}
+		target := page.Link.Url + "?author=" + url.QueryEscape(author)
+
+		rec := getPost(app, target, "application/json")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("seed %d got status %d: %s", seed, rec.Code, rec.Body)
+		}
+		var post PostResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &post); err != nil {
+			t.Fatal(err)
+		}
+		if post.Author != author {
+			t.Errorf("seed %d is by %q, want %q", seed, post.Author, author)
+		}
+		if post.Title != page.Link.Title {
+			t.Errorf("seed %d is titled %q with an author, %q without", seed, post.Title, page.Link.Title)
+		}
+
+		rec = getPost(app, target, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("seed %d got status %d for HTML", seed, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), html.EscapeString(author)) {
+			t.Errorf("the HTML page for seed %d doesn't credit %q", seed, author)
+		}
+	}
+
+	page, err := app.generatePage(1, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := getPost(app, page.Link.Url+"?author=Nobody+Known", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("an unknown author got status %d, want 400", rec.Code)
+	}
+}
+
+// getAuthor asks for the profile page of the author named name in the url
+func getAuthor(app *App, name string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/author/"+url.PathEscape(name), nil)
+	rec := httptest.NewRecorder()
+	app.authorHandler(rec, mux.SetURLVars(req, map[string]string{"name": name}))
+	return rec
+}
+
+func TestAuthorPage(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	app.streamTimeout = time.Minute
+	chain, err := app.loadLatestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	author := train.Authors()[0]
+	slug := train.AuthorSlug(author)
+
+	rec := getAuthor(app, slug)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	page := rec.Body.String()
+	if !strings.Contains(page, `<h1 itemprop="name">`+html.EscapeString(author)+`</h1>`) {
+		t.Errorf("the page doesn't name %s", author)
+	}
+	bio, err := train.GenerateAuthorBio(chain, author, authorBioSentences)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(page, `<p class="bio" itemprop="description">`+html.EscapeString(bio)+`</p>`) {
+		t.Errorf("the page lacks the bio %q", bio)
+	}
+	seeds := train.SeedsForAuthor(author, authorPostCount)
+	if len(seeds) == 0 {
+		t.Fatal("the author has no posts")
+	}
+	for _, seed := range seeds {
+		post, err := train.GeneratePage(seed, chain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if post.Author != author {
+			t.Errorf("post %d listed for %s is by %s", seed, author, post.Author)
+		}
+		if !strings.Contains(page, `<a href="`+html.EscapeString(post.Link.Url)+`">`) {
+			t.Errorf("the page doesn't link to post %d", seed)
+		}
+	}
+	if again := getAuthor(app, slug).Body.String(); again != page {
+		t.Error("the author page changed between requests")
+	}
+
+	// Posts by the author link back to the profile
+	story, err := app.generatePage(seeds[0], "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post := getPost(app, story.Link.Url, "").Body.String(); !strings.Contains(post, `href="/author/`+slug+`"`) {
+		t.Error("the post doesn't link to its author's page")
+	}
+
+	if rec := getAuthor(app, author); rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/author/"+slug {
+		t.Errorf("%q got status %d to %q", author, rec.Code, rec.Header().Get("Location"))
+	}
+	if rec := getAuthor(app, "nobody-we-know"); rec.Code != http.StatusNotFound {
+		t.Errorf("an unknown author got status %d", rec.Code)
+	}
+}
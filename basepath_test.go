@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestBasePath(t *testing.T) {
+	basePath = "/stories"
+	t.Cleanup(func() { basePath = "" })
+	app := newTestApp(t, testCorpus)
+	app.streamTimeout = time.Minute
+	router := mux.NewRouter()
+	app.registerRoutes(router.PathPrefix(basePath).Subrouter())
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		if rec.Code != http.StatusOK && rec.Code != http.StatusFound {
+			t.Fatalf("%s got status %d", target, rec.Code)
+		}
+		return rec
+	}
+
+	// Every post link on the home page is under the prefix
+	home := get("/stories/").Body.String()
+	links := regexp.MustCompile(`href="(/[^"]*post/[^"]*)"`).FindAllStringSubmatch(home, -1)
+	if len(links) == 0 {
+		t.Fatal("the home page links to no posts")
+	}
+	for _, link := range links {
+		if !strings.HasPrefix(link[1], "/stories/post/") {
+			t.Errorf("the home page links to %s", link[1])
+		}
+	}
+
+	// The slugless post URL redirects within the prefix, to a page linking home
+	location := get("/stories/post/5").Header().Get("Location")
+	if !strings.HasPrefix(location, "/stories/post/5-") {
+		t.Fatalf("redirected to %q", location)
+	}
+	if post := get(location).Body.String(); !strings.Contains(post, `href="/stories/"`) {
+		t.Error("the post page doesn't link to /stories/")
+	}
+
+	sitemap := get("/stories/sitemap.xml").Body.String()
+	locs := regexp.MustCompile(`<loc>([^<]*)</loc>`).FindAllStringSubmatch(sitemap, -1)
+	if len(locs) < 2 {
+		t.Fatalf("the sitemap lists %d URLs", len(locs))
+	}
+	for _, loc := range locs {
+		if !strings.HasPrefix(loc[1], "http://example.com/stories/") {
+			t.Errorf("the sitemap lists %s", loc[1])
+		}
+	}
+	if robots := get("/stories/robots.txt").Body.String(); !strings.Contains(robots, "Sitemap: http://example.com/stories/sitemap.xml") {
+		t.Errorf("robots.txt doesn't point at the prefixed sitemap:\n%s", robots)
+	}
+}
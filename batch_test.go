@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTrainBatchPartialSuccess(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	before, err := app.store.CountMarkovChainModels()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `[
+		{"name": "cats", "text": "The cat sat on the mat. The cat slept."},
+		{"name": "empty", "text": "   "},
+		{"name": "birds", "text": "A bird sang. A bird flew away."}
+	]`
+	rec := httptest.NewRecorder()
+	app.trainBatchHandler(rec, httptest.NewRequest("POST", "/api/train/batch", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	var results []BatchTrainResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+
+	for i, want := range []struct {
+		name    string
+		success bool
+	}{{"cats", true}, {"empty", false}, {"birds", true}} {
+		result := results[i]
+		if result.Name != want.name || result.Success != want.success {
+			t.Errorf("result %d is %+v, want %s with success %v", i, result, want.name, want.success)
+		}
+		if want.success {
+			if result.Model == nil {
+				t.Errorf("%s: no model", result.Name)
+			} else if _, err := app.store.GetMarkovChainModel(result.Model.ID); err != nil {
+				t.Errorf("%s: model %d isn't stored: %v", result.Name, result.Model.ID, err)
+			}
+		} else if result.Error == "" || result.Model != nil {
+			t.Errorf("%s: got error %q and model %v", result.Name, result.Error, result.Model)
+		}
+	}
+
+	after, err := app.store.CountMarkovChainModels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after != before+2 {
+		t.Errorf("stored %d models, want 2", after-before)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/abigpotostew/endless/store"
+)
+
+func TestBootstrapEmptyDatabase(t *testing.T) {
+	dir := t.TempDir()
+	postStore, err := store.NewSQLiteStore(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { postStore.Close() })
+	corpus := filepath.Join(dir, "corpus.txt")
+	if err := os.WriteFile(corpus, []byte(testCorpus), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	app := &App{store: postStore}
+	if err := app.bootstrap(corpus); err != nil {
+		t.Fatal(err)
+	}
+	model, err := app.getLatestModel()
+	if err != nil {
+		t.Fatalf("no model after bootstrapping: %v", err)
+	}
+
+	// A restart with models in the database doesn't train again
+	if err := app.bootstrap(corpus); err != nil {
+		t.Fatal(err)
+	}
+	count, err := postStore.CountMarkovChainModels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("got %d models after bootstrapping twice, want 1", count)
+	}
+	if current, err := postStore.GetCurrentMarkovChainModelID(); err != nil || current != model.ID {
+		t.Errorf("current model is %d, %v, want the bootstrapped %d", current, err, model.ID)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// instantUserAgents are lowercase fragments of crawler and link-unfurler user
+// agents. These clients can't watch a story being typed out, and the delays
+// can time out their fetch, so they get the whole page at once.
+var instantUserAgents = []string{
+	"googlebot",
+	"google-inspectiontool",
+	"bingbot",
+	"slurp",
+	"duckduckbot",
+	"baiduspider",
+	"yandex",
+	"applebot",
+	"facebookexternalhit",
+	"facebookbot",
+	"meta-externalagent",
+	"twitterbot",
+	"linkedinbot",
+	"slackbot",
+	"discordbot",
+	"telegrambot",
+	"whatsapp",
+	"pinterest",
+	"redditbot",
+	"embedly",
+	"skypeuripreview",
+	"mastodon",
+	"bot/",
+	"crawler",
+	"spider",
+}
+
+// isInstantUserAgent reports whether userAgent belongs to a crawler or
+// unfurler that should skip the streaming delays
+func isInstantUserAgent(userAgent string) bool {
+	userAgent = strings.ToLower(userAgent)
+	for _, fragment := range instantUserAgents {
+		if strings.Contains(userAgent, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// pauserFor returns the pause used while streaming to r. Crawlers,
+// unfurlers and non-streamed responses get one that only checks for
+// cancellation.
+func (app *App) pauserFor(r *http.Request) func(ctx context.Context, d time.Duration) bool {
+	if !app.streaming || (app.instantBots && isInstantUserAgent(r.UserAgent())) {
+		return instantPause
+	}
+	return app.pause
+}
+
+// instantPause skips the delay, returning false only if ctx has ended
+func instantPause(ctx context.Context, d time.Duration) bool {
+	return ctx.Err() == nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	googlebotUA = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+	browserUA   = "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15"
+)
+
+func TestIsInstantUserAgent(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      bool
+	}{
+		{googlebotUA, true},
+		{"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", true},
+		{"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", true},
+		{"Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)", true},
+		{"SomeNewBot/1.0", true},
+		{browserUA, false},
+		{"curl/8.4.0", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isInstantUserAgent(tt.userAgent); got != tt.want {
+			t.Errorf("isInstantUserAgent(%q) = %v, want %v", tt.userAgent, got, tt.want)
+		}
+	}
+}
+
+func TestInstantBotsSkipDelays(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	app.streaming = true
+	app.streamTimeout = time.Minute
+	story, err := app.generatePage(3, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strings.TrimPrefix(story.Link.Url, "/post/")
+
+	tests := []struct {
+		userAgent   string
+		instantBots bool
+		wantPauses  bool
+	}{
+		{googlebotUA, true, false},
+		{browserUA, true, true},
+		{googlebotUA, false, true},
+	}
+	for _, tt := range tests {
+		app.instantBots = tt.instantBots
+		clock := &recordingClock{}
+		app.clock = clock
+		req := httptest.NewRequest("GET", story.Link.Url, nil)
+		req.Header.Set("User-Agent", tt.userAgent)
+		rec := httptest.NewRecorder()
+		app.generatePageStreamHandler(rec, mux.SetURLVars(req, map[string]string{"id": id}))
+
+		if got := len(clock.pauses) > 0; got != tt.wantPauses {
+			t.Errorf("%q with instant bots %v paused %d times", tt.userAgent, tt.instantBots, len(clock.pauses))
+		}
+		if page := rec.Body.String(); !strings.Contains(page, `<div class="links-section">`) || !strings.HasSuffix(page, "</html>") {
+			t.Errorf("%q with instant bots %v got an incomplete page", tt.userAgent, tt.instantBots)
+		}
+	}
+}
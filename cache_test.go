@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/abigpotostew/endless/store"
+	"github.com/abigpotostew/endless/train"
+)
+
+// countingStore counts loads of the current model, holding each one long
+// enough for concurrent readers to pile up behind it
+type countingStore struct {
+	store.PostStore
+	loads atomic.Int32
+}
+
+func (s *countingStore) GetCurrentMarkovChainModel() (*store.MarkovChainModel, error) {
+	s.loads.Add(1)
+	time.Sleep(50 * time.Millisecond)
+	return s.PostStore.GetCurrentMarkovChainModel()
+}
+
+func TestConcurrentColdReadsLoadOnce(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	counting := &countingStore{PostStore: app.store}
+	app.store = counting
+	app.clearModelCache()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := app.getLatestModel(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if loads := counting.loads.Load(); loads != 1 {
+		t.Errorf("loaded the model %d times, want 1", loads)
+	}
+}
+
+func TestClearCacheLoadsNewModel(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	cached, err := app.getLatestModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A model saved by another process isn't seen until the cache is cleared
+	chain, err := train.BuildModel("The bird sang. The bird flew.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := train.SerializeModel(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := app.store.SaveMarkovChainModel(data, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model, _ := app.getLatestModel(); model.ID != cached.ID {
+		t.Fatalf("got model %d before clearing the cache, want the cached %d", model.ID, cached.ID)
+	}
+
+	rec := httptest.NewRecorder()
+	app.clearCacheHandler(rec, httptest.NewRequest("POST", "/api/cache/clear", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", rec.Code)
+	}
+	if body := rec.Body.String(); body != "{\"success\":true}\n" {
+		t.Errorf("got body %q", body)
+	}
+
+	model, err := app.getLatestModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.ID != saved.ID {
+		t.Errorf("got model %d after clearing the cache, want the new %d", model.ID, saved.ID)
+	}
+}
+
+func TestPollPicksUpOutOfBandModel(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	cached, err := app.getLatestModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastID := app.checkCurrentModel(cached.ID)
+
+	// Another process saves a new current model
+	chain, err := train.BuildModel("The bird sang. The bird flew.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := train.SerializeModel(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := app.store.SaveMarkovChainModel(data, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model, _ := app.getLatestModel(); model.ID != cached.ID {
+		t.Fatalf("got model %d before polling, want the cached %d", model.ID, cached.ID)
+	}
+
+	if lastID = app.checkCurrentModel(lastID); lastID != saved.ID {
+		t.Errorf("the poll saw model %d, want %d", lastID, saved.ID)
+	}
+	model, err := app.getLatestModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.ID != saved.ID {
+		t.Errorf("got model %d after polling, want the new %d", model.ID, saved.ID)
+	}
+}
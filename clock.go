@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Clock is the time source streamed pages are paced with. Replacing it lets
+// tests skip or record the delays between streamed words.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the wall clock
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// clockOrDefault returns the App's clock, or the wall clock when none is set
+func (app *App) clockOrDefault() Clock {
+	if app.clock == nil {
+		return realClock{}
+	}
+	return app.clock
+}
+
+// newJitterPRNG returns the source of streaming jitter. Without an injected
+// source it is seeded from the clock, so every stream is paced differently.
+func (app *App) newJitterPRNG() *rand.Rand {
+	if app.jitterSource != nil {
+		return app.jitterSource()
+	}
+	return rand.New(rand.NewSource(app.clockOrDefault().Now().UnixNano()))
+}
+
+// pause sleeps for d on the App's clock, returning false if ctx ends first
+func (app *App) pause(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-app.clockOrDefault().After(d):
+		return true
+	}
+}
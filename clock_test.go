@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingClock ends every pause at once and records how long it was
+type recordingClock struct {
+	mu     sync.Mutex
+	pauses []time.Duration
+}
+
+func (c *recordingClock) Now() time.Time { return time.Unix(0, 0) }
+
+func (c *recordingClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	c.pauses = append(c.pauses, d)
+	c.mu.Unlock()
+	after := make(chan time.Time, 1)
+	after <- time.Unix(0, 0).Add(d)
+	return after
+}
+
+func TestInjectedJitter(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	app.streaming = true
+	app.streamTimeout = time.Minute
+	story, err := app.generatePage(3, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// stream returns the page and pauses streamed with jitter seeded by seed
+	stream := func(seed int64) (string, []time.Duration) {
+		clock := &recordingClock{}
+		app.clock = clock
+		app.jitterSource = func() *rand.Rand { return rand.New(rand.NewSource(seed)) }
+		page := getPost(app, story.Link.Url, "").Body.String()
+		return page, clock.pauses
+	}
+
+	page, pauses := stream(1)
+	againPage, again := stream(1)
+	if againPage != page || !slices.Equal(again, pauses) {
+		t.Errorf("the same jitter source paced the page differently:\n%v\n%v", pauses, again)
+	}
+	if _, other := stream(2); slices.Equal(other, pauses) {
+		t.Error("a different jitter source paced the page identically")
+	}
+
+	// Jitter is at most 30% either way, and the page pauses after every
+	// streamed word and character
+	var total time.Duration
+	for _, pause := range pauses {
+		total += pause
+	}
+	want := streamDelay(story, false)
+	if total < want*7/10 || total > want*13/10 {
+		t.Errorf("the page paused for %s in total, want about %s", total, want)
+	}
+}
@@ -0,0 +1,418 @@
+package config
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of the settings whose default isn't their zero value
+const (
+	defaultPort             = "8080"
+	defaultTLSPort          = "443"
+	defaultSiteLang         = "en"
+	defaultSiteLocale       = "en_US"
+	defaultExcerptLength    = 150
+	defaultStreamTimeout    = 60 * time.Second
+	defaultMaxResponseBytes = 1 << 20
+	defaultGzipMinSize      = 1024
+	// defaultReferrerPolicy sends only the origin to other sites
+	defaultReferrerPolicy = "strict-origin-when-cross-origin"
+)
+
+// Config holds the server settings. Every setting is named after its
+// environment variable; a JSON config file can supply any of them, and the
+// environment overrides the file. Unparseable values are ignored in favor of
+// the default, except where Load returns an error.
+//
+// Settings that tune the train package are zero when unset, which keeps the
+// train package's own default.
+type Config struct {
+	// Port is the port the server listens on
+	Port string
+
+	// SQLiteDBDir is the directory holding endless.db
+	SQLiteDBDir string
+
+	// PublicHost is the scheme and host used in canonical URLs, taken from
+	// the request when empty
+	PublicHost string
+
+	// SiteLang is the BCP 47 language of the generated pages, e.g. "de"
+	SiteLang string
+
+	// SiteLocale is the Open Graph locale of the pages, e.g. "de_DE"
+	SiteLocale string
+
+	// BasePath is the path prefix the app is served under, e.g. "/stories",
+	// with a leading slash and no trailing slash. Empty serves from the root.
+	BasePath string
+
+	// ModelRetention is how many models are kept after each save; zero keeps
+	// them all
+	ModelRetention int
+
+	// ModelStartToken and ModelEndToken replace the sentence delimiters of
+	// imported models
+	ModelStartToken string
+	ModelEndToken   string
+
+	// RelatedLinksMode is "vocabulary" to bias related links toward the
+	// page's words
+	RelatedLinksMode string
+
+	// AuthorWeights credits some authors with more posts, from
+	// "Arlo Mills=3,Diana White=2"; nil keeps the default weights
+	AuthorWeights map[string]int
+
+	// LinkTitleMaxWords shortens related link titles
+	LinkTitleMaxWords int
+
+	// SlugMaxLength is the longest title slug in post URLs, in bytes
+	SlugMaxLength int
+
+	// PostDateMaxAge and PostDateMinAge bound the past dates given to posts
+	PostDateMaxAge time.Duration
+	PostDateMinAge time.Duration
+
+	// ClampToSentence trims runaway generations back to their last complete
+	// sentence instead of failing
+	ClampToSentence bool
+
+	// HeadingPattern replaces the line patterns strip_headings removes
+	HeadingPattern *regexp.Regexp
+
+	// Generator names how generated sentences pick each next word
+	Generator string
+
+	// MaxSentenceWords cuts rambling sentences short
+	MaxSentenceWords int
+
+	// RepetitionPenalty is the chance from 0 to 1 that a word already used
+	// on a page is redrawn
+	RepetitionPenalty float64
+
+	// SentenceTerminators is the punctuation that ends a sentence
+	SentenceTerminators []string
+
+	// ParagraphSentences breaks stories into paragraphs of this many sentences
+	ParagraphSentences int
+
+	// HomeMinContentLength and HomeMinWords are the least content home page
+	// posts aim for
+	HomeMinContentLength int
+	HomeMinWords         int
+
+	// HomePublishedPosts fills the home page with up to this many of the
+	// newest published posts instead of generated ones; zero turns it off
+	HomePublishedPosts int
+
+	// MaxRegenerations is how often a post may be regenerated across all
+	// checks; nil keeps the default, since zero turns regeneration off
+	MaxRegenerations *int
+
+	// ExcerptLength is the length of the excerpt on home page cards
+	ExcerptLength int
+
+	// StreamTimeout is how long a single streamed page may take
+	StreamTimeout time.Duration
+
+	// TypingCurve paces streamed words like a typist
+	TypingCurve bool
+
+	// GoatcounterURL is the goatcounter count endpoint, e.g.
+	// https://stats.example.com/count
+	GoatcounterURL string
+
+	// AnalyticsSnippet is raw HTML injected into every page, loading its
+	// scripts from AnalyticsScriptHost
+	AnalyticsSnippet    string
+	AnalyticsScriptHost string
+
+	// MinVocabulary withholds readiness until the model knows this many words
+	MinVocabulary int
+
+	// MaxResponseBytes closes streamed pages at this size; zero disables it
+	MaxResponseBytes int64
+
+	// InstantBots serves crawlers and link unfurlers without typing delays
+	InstantBots bool
+
+	// StreamingEnabled sends pages as they are written
+	StreamingEnabled bool
+
+	// ExcerptSentences ends card excerpts on a complete sentence
+	ExcerptSentences bool
+
+	// MetaDescriptionSentences ends meta descriptions on a complete sentence
+	MetaDescriptionSentences bool
+
+	// ThemeColors gives each post its own accent color
+	ThemeColors bool
+
+	// WebSubHubURL is notified that WebSubTopic changed when a model is
+	// trained. The topic defaults to the home page under PublicHost.
+	WebSubHubURL string
+	WebSubTopic  string
+
+	// BootstrapCorpus is trained into a first model when there is none
+	BootstrapCorpus string
+
+	// ModelPollInterval is how often the database is checked for a change
+	// of current model; zero doesn't check
+	ModelPollInterval time.Duration
+
+	// ContentSecurityPolicy replaces the default Content-Security-Policy
+	// header; nil keeps the default and empty sends none
+	ContentSecurityPolicy *string
+
+	// ReferrerPolicy is the Referrer-Policy header; empty sends none
+	ReferrerPolicy string
+
+	// GzipLevel compresses responses at this level; zero doesn't compress.
+	// Responses under GzipMinSize bytes aren't compressed.
+	GzipLevel   int
+	GzipMinSize int
+
+	// AutocertDomains get Let's Encrypt certificates, cached in
+	// AutocertCacheDir
+	AutocertDomains  []string
+	AutocertCacheDir string
+
+	// TLSCertFile and TLSKeyFile serve HTTPS with a certificate of their own
+	TLSCertFile string
+	TLSKeyFile  string
+
+	file map[string]string
+}
+
+// Load reads the JSON object in the file named by CONFIG_FILE, if any, and
+// resolves the settings against the environment and defaults
+func Load() (*Config, error) {
+	c := &Config{file: map[string]string{}}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := c.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.resolve(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// resolve fills in the settings from the environment, config file and
+// defaults
+func (c *Config) resolve() error {
+	c.SQLiteDBDir = c.get("SQLITE_DB_DIR")
+	if c.SQLiteDBDir == "" {
+		c.SQLiteDBDir = "."
+	}
+	c.PublicHost = c.get("PUBLIC_HOST")
+	c.SiteLang = c.get("SITE_LANG")
+	if c.SiteLang == "" {
+		c.SiteLang = defaultSiteLang
+	}
+	c.SiteLocale = c.get("SITE_LOCALE")
+	if c.SiteLocale == "" {
+		c.SiteLocale = defaultSiteLocale
+	}
+	if basePath := strings.Trim(c.get("BASE_PATH"), "/"); basePath != "" {
+		c.BasePath = "/" + basePath
+	}
+	if domains := c.get("AUTOCERT_DOMAINS"); domains != "" {
+		c.AutocertDomains = strings.Split(domains, ",")
+	}
+	c.Port = c.get("PORT")
+	if c.Port == "" {
+		c.Port = defaultPort
+		if len(c.AutocertDomains) > 0 {
+			c.Port = defaultTLSPort
+		}
+	}
+	c.AutocertCacheDir = c.get("AUTOCERT_CACHE_DIR")
+	if c.AutocertCacheDir == "" {
+		c.AutocertCacheDir = filepath.Join(c.SQLiteDBDir, "autocert")
+	}
+	c.TLSCertFile = c.get("TLS_CERT_FILE")
+	c.TLSKeyFile = c.get("TLS_KEY_FILE")
+
+	c.ModelRetention = c.intSetting("MODEL_RETENTION", 0, positive)
+	c.ModelStartToken = c.get("MODEL_START_TOKEN")
+	c.ModelEndToken = c.get("MODEL_END_TOKEN")
+	c.ModelPollInterval = c.durationSetting("MODEL_POLL_INTERVAL", 0, positive)
+	c.BootstrapCorpus = c.get("BOOTSTRAP_CORPUS")
+
+	c.RelatedLinksMode = c.get("RELATED_LINKS_MODE")
+	if weights := c.get("AUTHOR_WEIGHTS"); weights != "" {
+		parsed, err := parseAuthorWeights(weights)
+		if err != nil {
+			return err
+		}
+		c.AuthorWeights = parsed
+	}
+	c.LinkTitleMaxWords = c.intSetting("LINK_TITLE_MAX_WORDS", 0, positive)
+	c.SlugMaxLength = c.intSetting("SLUG_MAX_LENGTH", 0, positive)
+	c.PostDateMaxAge = c.durationSetting("POST_DATE_MAX_AGE", 0, positive)
+	c.PostDateMinAge = c.durationSetting("POST_DATE_MIN_AGE", 0, nonNegative)
+	c.ClampToSentence = c.boolSetting("CLAMP_TO_SENTENCE", false)
+	if pattern := c.get("HEADING_PATTERN"); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("Invalid HEADING_PATTERN: %w", err)
+		}
+		c.HeadingPattern = re
+	}
+	c.Generator = c.get("GENERATOR")
+	c.MaxSentenceWords = c.intSetting("MAX_SENTENCE_WORDS", 0, positive)
+	if p, err := strconv.ParseFloat(c.get("REPETITION_PENALTY"), 64); err == nil && p > 0 && p <= 1 {
+		c.RepetitionPenalty = p
+	}
+	c.SentenceTerminators = strings.Fields(c.get("SENTENCE_TERMINATORS"))
+	c.ParagraphSentences = c.intSetting("PARAGRAPH_SENTENCES", 0, positive)
+	c.HomeMinContentLength = c.intSetting("HOME_MIN_CONTENT_LENGTH", 0, positive)
+	c.HomeMinWords = c.intSetting("HOME_MIN_WORDS", 0, positive)
+	c.HomePublishedPosts = c.intSetting("HOME_PUBLISHED_POSTS", 0, positive)
+	if n, err := strconv.Atoi(c.get("MAX_REGENERATIONS")); err == nil && n >= 0 {
+		c.MaxRegenerations = &n
+	}
+
+	c.ExcerptLength = c.intSetting("EXCERPT_LENGTH", defaultExcerptLength, positive)
+	c.ExcerptSentences = c.boolSetting("EXCERPT_SENTENCES", false)
+	c.MetaDescriptionSentences = c.boolSetting("META_DESCRIPTION_SENTENCES", true)
+	c.ThemeColors = c.boolSetting("THEME_COLORS", false)
+	c.MinVocabulary = c.intSetting("MIN_VOCABULARY", 0, positive)
+
+	c.StreamingEnabled = c.boolSetting("STREAMING_ENABLED", true)
+	c.StreamTimeout = c.durationSetting("STREAM_TIMEOUT", defaultStreamTimeout, positive)
+	c.TypingCurve = c.boolSetting("TYPING_CURVE", false)
+	c.InstantBots = c.boolSetting("INSTANT_BOTS", true)
+	c.MaxResponseBytes = defaultMaxResponseBytes
+	if n, err := strconv.ParseInt(c.get("MAX_RESPONSE_BYTES"), 10, 64); err == nil && n >= 0 {
+		c.MaxResponseBytes = n
+	}
+
+	c.GoatcounterURL = c.get("GOATCOUNTER_URL")
+	c.AnalyticsSnippet = c.get("ANALYTICS_SNIPPET")
+	c.AnalyticsScriptHost = c.get("ANALYTICS_SCRIPT_HOST")
+
+	c.WebSubHubURL = c.get("WEBSUB_HUB_URL")
+	c.WebSubTopic = c.get("WEBSUB_TOPIC")
+	if c.WebSubHubURL != "" && c.WebSubTopic == "" {
+		if c.PublicHost == "" {
+			return fmt.Errorf("WEBSUB_HUB_URL needs WEBSUB_TOPIC or PUBLIC_HOST to name the topic")
+		}
+		c.WebSubTopic = strings.TrimRight(c.PublicHost, "/") + c.BasePath + "/"
+	}
+
+	if csp, ok := c.lookup("CONTENT_SECURITY_POLICY"); ok {
+		c.ContentSecurityPolicy = &csp
+	}
+	c.ReferrerPolicy = defaultReferrerPolicy
+	if policy, ok := c.lookup("REFERRER_POLICY"); ok {
+		c.ReferrerPolicy = policy
+	}
+	c.GzipLevel = c.intSetting("GZIP_LEVEL", 0, nil)
+	if c.GzipLevel != 0 && (c.GzipLevel < gzip.HuffmanOnly || c.GzipLevel > gzip.BestCompression) {
+		return fmt.Errorf("Invalid GZIP_LEVEL %d: must be between %d and %d", c.GzipLevel, gzip.HuffmanOnly, gzip.BestCompression)
+	}
+	c.GzipMinSize = c.intSetting("GZIP_MIN_SIZE", defaultGzipMinSize, nonNegative)
+	return nil
+}
+
+// parseAuthorWeights reads name=weight pairs separated by commas
+func parseAuthorWeights(weights string) (map[string]int, error) {
+	parsed := map[string]int{}
+	for _, entry := range strings.Split(weights, ",") {
+		name, weight, found := strings.Cut(entry, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(weight))
+		if !found || err != nil || n < 0 || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("Invalid AUTHOR_WEIGHTS entry %q: want name=weight", entry)
+		}
+		parsed[strings.TrimSpace(name)] = n
+	}
+	return parsed, nil
+}
+
+// positive and nonNegative check numeric settings
+func positive[T int | time.Duration](v T) bool    { return v > 0 }
+func nonNegative[T int | time.Duration](v T) bool { return v >= 0 }
+
+// intSetting returns the named integer setting, or def when it is unset,
+// unparseable or rejected by valid. A nil valid accepts any integer.
+func (c *Config) intSetting(name string, def int, valid func(int) bool) int {
+	n, err := strconv.Atoi(c.get(name))
+	if err != nil || (valid != nil && !valid(n)) {
+		return def
+	}
+	return n
+}
+
+// durationSetting returns the named duration setting, e.g. "30s", or def
+// when it is unset, unparseable or rejected by valid
+func (c *Config) durationSetting(name string, def time.Duration, valid func(time.Duration) bool) time.Duration {
+	d, err := time.ParseDuration(c.get(name))
+	if err != nil || !valid(d) {
+		return def
+	}
+	return d
+}
+
+// boolSetting returns the named boolean setting, or def when it is unset or
+// unparseable
+func (c *Config) boolSetting(name string, def bool) bool {
+	b, err := strconv.ParseBool(c.get(name))
+	if err != nil {
+		return def
+	}
+	return b
+}
+
+// readFile loads settings from a JSON object. Values may be strings, numbers
+// or booleans, e.g. {"PORT": 8080, "STREAMING_ENABLED": false}.
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	for name, value := range values {
+		switch v := value.(type) {
+		case string:
+			c.file[name] = v
+		case float64:
+			c.file[name] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			c.file[name] = strconv.FormatBool(v)
+		default:
+			return fmt.Errorf("config file %s: %s must be a string, number or boolean", path, name)
+		}
+	}
+	return nil
+}
+
+// lookup returns the named setting from the environment, falling back to the
+// config file, and whether either set it
+func (c *Config) lookup(name string) (string, bool) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true
+	}
+	value, ok := c.file[name]
+	return value, ok
+}
+
+// get returns the named setting, or "" when it is not set
+func (c *Config) get(name string) string {
+	value, _ := c.lookup(name)
+	return value
+}
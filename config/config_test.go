@@ -0,0 +1,118 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeConfigFile points CONFIG_FILE at a file holding contents
+func writeConfigFile(t *testing.T, contents string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	c, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != "8080" || c.SQLiteDBDir != "." || c.SiteLang != "en" || c.SiteLocale != "en_US" || c.BasePath != "" {
+		t.Errorf("unexpected site defaults: %+v", c)
+	}
+	if c.ExcerptLength != 150 || c.StreamTimeout != 60*time.Second || c.MaxResponseBytes != 1<<20 || c.GzipMinSize != 1024 {
+		t.Errorf("unexpected numeric defaults: %+v", c)
+	}
+	if !c.StreamingEnabled || !c.InstantBots || !c.MetaDescriptionSentences || c.TypingCurve {
+		t.Errorf("unexpected boolean defaults: %+v", c)
+	}
+	if c.ReferrerPolicy != "strict-origin-when-cross-origin" || c.ContentSecurityPolicy != nil {
+		t.Errorf("unexpected header defaults: %q, %v", c.ReferrerPolicy, c.ContentSecurityPolicy)
+	}
+	if c.MaxRegenerations != nil || c.AuthorWeights != nil || c.HeadingPattern != nil {
+		t.Errorf("unset train settings aren't empty: %+v", c)
+	}
+	if c.AutocertCacheDir != "autocert" {
+		t.Errorf("AutocertCacheDir is %q, want it under the database directory", c.AutocertCacheDir)
+	}
+}
+
+func TestLoadFileAndEnvironment(t *testing.T) {
+	writeConfigFile(t, `{"PORT": 9000, "STREAMING_ENABLED": false, "EXCERPT_LENGTH": 80, "BASE_PATH": "/stories/", "MAX_REGENERATIONS": 0, "SITE_LOCALE": "de_DE"}`)
+	t.Setenv("EXCERPT_LENGTH", "200")
+	t.Setenv("SITE_LANG", "de")
+	t.Setenv("STREAM_TIMEOUT", "nonsense")
+	t.Setenv("CONTENT_SECURITY_POLICY", "")
+	t.Setenv("AUTHOR_WEIGHTS", "Arlo Mills=3, Diana White=2")
+
+	c, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != "9000" || c.StreamingEnabled || c.BasePath != "/stories" {
+		t.Errorf("file settings weren't read: %+v", c)
+	}
+	if c.SiteLang != "de" || c.SiteLocale != "de_DE" {
+		t.Errorf("got language %q and locale %q, want de and de_DE", c.SiteLang, c.SiteLocale)
+	}
+	if c.ExcerptLength != 200 {
+		t.Errorf("ExcerptLength is %d, want the environment's 200", c.ExcerptLength)
+	}
+	if c.StreamTimeout != 60*time.Second {
+		t.Errorf("an unparseable STREAM_TIMEOUT gave %v, want the default", c.StreamTimeout)
+	}
+	if c.ContentSecurityPolicy == nil || *c.ContentSecurityPolicy != "" {
+		t.Errorf("an empty CONTENT_SECURITY_POLICY should disable the header, got %v", c.ContentSecurityPolicy)
+	}
+	if c.MaxRegenerations == nil || *c.MaxRegenerations != 0 {
+		t.Errorf("MAX_REGENERATIONS 0 should turn regeneration off, got %v", c.MaxRegenerations)
+	}
+	if c.AuthorWeights["Arlo Mills"] != 3 || c.AuthorWeights["Diana White"] != 2 {
+		t.Errorf("AuthorWeights is %v", c.AuthorWeights)
+	}
+}
+
+func TestLoadWebSubTopic(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("WEBSUB_HUB_URL", "https://hub.example.com")
+	t.Setenv("PUBLIC_HOST", "https://example.com/")
+	t.Setenv("BASE_PATH", "stories")
+	c, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.WebSubTopic != "https://example.com/stories/" {
+		t.Errorf("WebSubTopic is %q", c.WebSubTopic)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{name: "author weight without a weight", env: map[string]string{"AUTHOR_WEIGHTS": "Arlo Mills"}},
+		{name: "negative author weight", env: map[string]string{"AUTHOR_WEIGHTS": "Arlo Mills=-1"}},
+		{name: "gzip level out of range", env: map[string]string{"GZIP_LEVEL": "12"}},
+		{name: "heading pattern", env: map[string]string{"HEADING_PATTERN": "(unclosed"}},
+		{name: "websub without a topic", env: map[string]string{"WEBSUB_HUB_URL": "https://hub.example.com"}},
+		{name: "config file", env: map[string]string{"CONFIG_FILE": "/nonexistent/config.json"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", "")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if _, err := Load(); err == nil {
+				t.Error("Load succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// stalledClock never lets a pause end, so a stream only moves on when its
+// deadline passes
+type stalledClock struct{}
+
+func (stalledClock) Now() time.Time { return time.Now() }
+
+func (stalledClock) After(time.Duration) <-chan time.Time { return nil }
+
+func TestStreamStopsAtDeadline(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	app.streaming = true
+	app.clock = stalledClock{}
+	app.streamTimeout = 100 * time.Millisecond
+	story, err := app.generatePage(3, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	rec := getPost(app, story.Link.Url, "")
+	if elapsed := time.Since(start); elapsed < app.streamTimeout || elapsed > 5*time.Second {
+		t.Errorf("the stream took %s with a deadline of %s", elapsed, app.streamTimeout)
+	}
+	page := rec.Body.String()
+	if !strings.HasSuffix(page, streamCloseHTML) {
+		t.Errorf("the cut off page isn't closed:\n%s", page[max(0, len(page)-200):])
+	}
+	if strings.Contains(page, `<div class="links-section">`) {
+		t.Error("the page streamed past the deadline")
+	}
+}
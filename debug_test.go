@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/abigpotostew/endless/train"
+	"github.com/gorilla/mux"
+)
+
+func TestDebugGenerateTokens(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	router := mux.NewRouter()
+	app.registerRoutes(router)
+	chain, err := app.loadLatestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sentinels := chain.Sentinels()
+
+	for _, seed := range []int64{0, 1, 42} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "http://localhost/api/debug/generate?seed="+strconv.FormatInt(seed, 10), nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("seed %d got status %d: %s", seed, rec.Code, rec.Body)
+		}
+		var got DebugTokensResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		tokens := got.Tokens
+		if got.Seed != seed || len(tokens) < 3 || tokens[0] != sentinels.Start || tokens[len(tokens)-1] != sentinels.End {
+			t.Fatalf("seed %d got %+v, want a sentence between %q and %q", seed, got, sentinels.Start, sentinels.End)
+		}
+		// The tokens between the sentinels are the sentence GenerateStory joins
+		story, _, err := train.GenerateStory(seed, chain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if joined := strings.Join(tokens[1:len(tokens)-1], " "); joined != story {
+			t.Errorf("seed %d got tokens %q, but the story is %q", seed, tokens, story)
+		}
+	}
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"http://localhost/api/debug/generate?seed=-1", http.StatusBadRequest},
+		{"http://localhost/api/debug/generate", http.StatusBadRequest},
+		{"http://example.com/api/debug/generate?seed=1", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", tt.target, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s got status %d, want %d", tt.target, rec.Code, tt.status)
+		}
+	}
+}
+
+func TestDebugTiming(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	router := mux.NewRouter()
+	app.registerRoutes(router)
+	model, err := app.getLatestModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	story, err := app.generatePage(7, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := len(strings.Fields(story.Link.Title)) + len(strings.Fields(story.Content))
+	for _, link := range story.Links {
+		tokens += len(strings.Fields(link.Title))
+	}
+
+	for _, typingCurve := range []bool{false, true} {
+		app.typingCurve = typingCurve
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "http://localhost/api/debug/timing?seed=7", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+		}
+		var got DebugTimingResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Seed != 7 || got.ModelID != model.ID || got.Tokens != tokens {
+			t.Errorf("got %+v, want seed 7 of model %d with %d tokens", got, model.ID, tokens)
+		}
+		if got.GenerateMs <= 0 || got.ModelLoadMs <= 0 {
+			t.Errorf("got load %vms and generation %vms, want both positive", got.ModelLoadMs, got.GenerateMs)
+		}
+		if want := durationMs(streamDelay(story, typingCurve)); got.StreamDelayMs != want {
+			t.Errorf("typing curve %v: got a stream delay of %vms, want %vms", typingCurve, got.StreamDelayMs, want)
+		}
+	}
+
+	for target, status := range map[string]int{
+		"http://localhost/api/debug/timing?seed=x":   http.StatusBadRequest,
+		"http://example.com/api/debug/timing?seed=7": http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		if rec.Code != status {
+			t.Errorf("%s got status %d, want %d", target, rec.Code, status)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSocialDescription(t *testing.T) {
+	content := strings.Repeat("The cat sat on the mat. ", 6) + strings.Repeat("word ", 40)
+
+	app := &App{sentenceDescriptions: true}
+	got := app.socialDescription(content)
+	if len(got) > socialDescriptionLength {
+		t.Errorf("description of %d bytes exceeds %d", len(got), socialDescriptionLength)
+	}
+	if !strings.HasSuffix(got, "mat.") {
+		t.Errorf("description %q doesn't end on a sentence", got)
+	}
+	if meta := app.metaDescription(content); len(meta) > metaDescriptionLength || !strings.HasPrefix(got, meta) {
+		t.Errorf("meta description %q isn't a shorter cut of %q", meta, got)
+	}
+
+	app.sentenceDescriptions = false
+	if got := app.socialDescription(content); !strings.HasSuffix(got, "...") {
+		t.Errorf("without sentence descriptions got %q, want a cut ending in ...", got)
+	}
+}
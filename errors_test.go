@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/abigpotostew/endless/routes"
+	"github.com/gorilla/mux"
+)
+
+func TestAPIErrorEnvelope(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		req     *http.Request
+		status  int
+	}{
+		{"empty corpus", app.trainMarkovModelHandler, httptest.NewRequest("POST", "/api/train", nil), http.StatusBadRequest},
+		{"unknown model", app.exportMarkovModelHandler, mux.SetURLVars(httptest.NewRequest("GET", "/api/train/999/export", nil), map[string]string{"id": "999"}), http.StatusNotFound},
+		{"unknown job", app.trainingJobHandler, mux.SetURLVars(httptest.NewRequest("GET", "/api/train/jobs/999", nil), map[string]string{"id": "999"}), http.StatusNotFound},
+		{"invalid count", app.homeJSONHandler, httptest.NewRequest("GET", "/api/home?count=many", nil), http.StatusBadRequest},
+		{"invalid inline model", app.generateInlineHandler, httptest.NewRequest("POST", "/api/generate", strings.NewReader("not json")), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		tt.handler(rec, tt.req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: got status %d, want %d", tt.name, rec.Code, tt.status)
+		}
+		if got := rec.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("%s: got Content-Type %q", tt.name, got)
+		}
+		var envelope map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		msg, _ := envelope["error"].(string)
+		if len(envelope) != 2 || envelope["success"] != false || msg == "" {
+			t.Errorf("%s: got %s, want {\"success\":false,\"error\":...}", tt.name, rec.Body)
+		}
+	}
+}
+
+func TestErrorPageNoIndex(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	handler := routes.NoIndexMiddleware(http.HandlerFunc(app.notFoundHandler))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/no/such/page", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("got status %d", rec.Code)
+	}
+	if got := rec.Header().Get("X-Robots-Tag"); got != "noindex" {
+		t.Errorf("got X-Robots-Tag %q", got)
+	}
+	if !strings.Contains(rec.Body.String(), `<meta name="robots" content="noindex">`) {
+		t.Errorf("the error page has no robots meta tag:\n%s", rec.Body)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// hostileCorpus writes titles and bodies full of characters that are special
+// in HTML, XML, JSON and scripts
+const hostileCorpus = `He wrote "</script><b>bold</b>" & left. She typed C:\temp\new & <i>saved</i> it. They said "</script><b>bold</b>" & <i>saved</i> C:\temp\new.`
+
+// ldBlocks returns the JSON-LD blocks of page
+var ldBlocks = regexp.MustCompile(`(?s)<script type="application/ld\+json">(.*?)</script>`)
+
+func TestGeneratedTextIsEscaped(t *testing.T) {
+	app := newTestApp(t, hostileCorpus)
+	app.streamTimeout = time.Minute
+
+	for seed := int64(0); seed < 5; seed++ {
+		story, err := app.generatePage(seed, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		id := strings.TrimPrefix(story.Link.Url, "/post/")
+		vars := map[string]string{"id": id}
+		pages := map[string]string{}
+
+		rec := httptest.NewRecorder()
+		app.generatePageStreamHandler(rec, mux.SetURLVars(httptest.NewRequest("GET", story.Link.Url, nil), vars))
+		pages["post"] = rec.Body.String()
+		rec = httptest.NewRecorder()
+		app.ampHandler(rec, mux.SetURLVars(httptest.NewRequest("GET", story.Link.Url+"/amp", nil), vars))
+		pages["amp"] = rec.Body.String()
+
+		for name, page := range pages {
+			for _, tag := range []string{"<b>", "<i>"} {
+				if strings.Contains(page, tag) {
+					t.Errorf("seed %d %s page has a raw %s", seed, name, tag)
+				}
+			}
+			if opens, closes := strings.Count(page, "<script"), strings.Count(page, "</script>"); opens != closes {
+				t.Errorf("seed %d %s page opens %d scripts and closes %d", seed, name, opens, closes)
+			}
+			blocks := ldBlocks.FindAllStringSubmatch(page, -1)
+			if len(blocks) == 0 {
+				t.Errorf("seed %d %s page has no JSON-LD", seed, name)
+			}
+			for _, block := range blocks {
+				var article struct {
+					Headline string `json:"headline"`
+				}
+				if err := json.Unmarshal([]byte(block[1]), &article); err != nil {
+					t.Errorf("seed %d %s page has invalid JSON-LD: %v", seed, name, err)
+				} else if article.Headline != "" && article.Headline != story.Link.Title {
+					t.Errorf("seed %d %s page has headline %q, want %q", seed, name, article.Headline, story.Link.Title)
+				}
+			}
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	app.sitemapHandler(rec, httptest.NewRequest("GET", "/sitemap.xml", nil))
+	decoder := xml.NewDecoder(rec.Body)
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("the sitemap isn't well formed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"html"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/abigpotostew/endless/train"
+)
+
+func TestTruncateAtSentence(t *testing.T) {
+	tests := []struct {
+		text   string
+		maxLen int
+		want   string
+	}{
+		{"The cat sat. The dog ran.", 100, "The cat sat. The dog ran."},
+		{"The cat sat. The dog ran.", 24, "The cat sat."},
+		{"The cat sat. The dog ran away.", 25, "The cat sat."},
+		{"Who sat? The cat! The dog ran away.", 20, "Who sat? The cat!"},
+		{`He said "Stop." The dog ran away.`, 20, `He said "Stop."`},
+		// Without a whole sentence in reach it ends on a word
+		{"The cat sat on the mat all day long.", 20, "The cat sat on the..."},
+	}
+	for _, tt := range tests {
+		if got := truncateAtSentence(tt.text, tt.maxLen); got != tt.want {
+			t.Errorf("truncateAtSentence(%q, %d) = %q, want %q", tt.text, tt.maxLen, got, tt.want)
+		}
+	}
+}
+
+func TestHomeExcerptsEndOnSentences(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	app.excerptLength = 40
+	excerpts := regexp.MustCompile(`<p class="post-excerpt">([^<]*)</p>`)
+
+	for _, sentences := range []bool{false, true} {
+		app.sentenceExcerpts = sentences
+		rec := httptest.NewRecorder()
+		app.homeHandler(rec, httptest.NewRequest("GET", "/", nil))
+		cut := 0
+		for _, match := range excerpts.FindAllStringSubmatch(rec.Body.String(), -1) {
+			excerpt := html.UnescapeString(match[1])
+			words := strings.Fields(strings.TrimSuffix(excerpt, "..."))
+			if !strings.HasSuffix(excerpt, "...") {
+				if sentences && !train.EndsSentence(words[len(words)-1]) {
+					t.Errorf("the excerpt %q doesn't end on a sentence", excerpt)
+				}
+				continue
+			}
+			cut++
+			// Only a first sentence too long for the excerpt is cut at a word
+			if sentences && slices.ContainsFunc(words, train.EndsSentence) {
+				t.Errorf("the excerpt %q was cut after a whole sentence", excerpt)
+			}
+		}
+		if !sentences && cut == 0 {
+			t.Error("no excerpt was long enough to cut")
+		}
+	}
+}
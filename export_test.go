@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/abigpotostew/endless/store"
+	"github.com/gorilla/mux"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	model, err := app.getLatestModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := strconv.Itoa(model.ID)
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/train/"+id+"/export", nil), map[string]string{"id": id})
+	exported := httptest.NewRecorder()
+	app.exportMarkovModelHandler(exported, req)
+	if exported.Code != http.StatusOK {
+		t.Fatalf("export got status %d: %s", exported.Code, exported.Body)
+	}
+	if got, want := exported.Header().Get("Content-Disposition"), fmt.Sprintf("attachment; filename=model-%d.json", model.ID); got != want {
+		t.Errorf("got Content-Disposition %q, want %q", got, want)
+	}
+
+	// Restore the backup into an empty database
+	restoreStore, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "restore.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { restoreStore.Close() })
+	restored := &App{store: restoreStore, siteLang: "en", siteLocale: "en_US", excerptLength: 150}
+
+	imported := httptest.NewRecorder()
+	restored.importMarkovModelHandler(imported, httptest.NewRequest("POST", "/api/train/import", bytes.NewReader(exported.Body.Bytes())))
+	if imported.Code != http.StatusCreated {
+		t.Fatalf("import got status %d: %s", imported.Code, imported.Body)
+	}
+	var created CreateMarkovModelRequest
+	if err := json.Unmarshal(imported.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if !created.Success || created.Model == nil {
+		t.Fatalf("import responded %s", imported.Body)
+	}
+
+	for _, seed := range []int64{1, 42, 1000} {
+		want, err := app.generatePage(seed, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := restored.generatePage(seed, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Link != want.Link || got.Author != want.Author || got.Content != want.Content {
+			t.Errorf("seed %d: the restored model wrote %+v, want %+v", seed, got.Link, want.Link)
+		}
+	}
+}
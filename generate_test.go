@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/abigpotostew/endless/train"
+)
+
+func TestGenerateInlineModel(t *testing.T) {
+	chain, err := train.BuildModel(testCorpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := train.SerializeModel(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := train.GeneratePage(123, chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is read from or saved to the store
+	app := &App{store: unreachableStore{}}
+	body, err := json.Marshal(map[string]interface{}{"model": json.RawMessage(model), "seed": 123})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	app.generateInlineHandler(rec, httptest.NewRequest("POST", "/api/generate", strings.NewReader(string(body))))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	var page PostResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Seed != 123 || page.Title == "" || page.Content == "" {
+		t.Fatalf("got page %+v", page)
+	}
+	if page.Title != want.Link.Title || page.Content != want.Content {
+		t.Errorf("got %q, want the page the model writes for the seed, %q", page.Title, want.Link.Title)
+	}
+
+	rec = httptest.NewRecorder()
+	app.generateInlineHandler(rec, httptest.NewRequest("POST", "/api/generate", strings.NewReader(`{"model": {"format": 1}, "seed": 1}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("an invalid model got status %d, want 400", rec.Code)
+	}
+}
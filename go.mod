@@ -8,3 +8,11 @@ require (
 )
 
 require github.com/mattn/go-sqlite3 v1.14.28
+
+require golang.org/x/sync v0.10.0
+
+require (
+	golang.org/x/crypto v0.31.0
+	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/text v0.21.0
+)
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/abigpotostew/endless/version"
+	"github.com/gorilla/mux"
+)
+
+func TestHealthReportsBuild(t *testing.T) {
+	// As if built with -ldflags "-X .../version.Version=v1.2.0 ..."
+	oldVersion, oldCommit := version.Version, version.Commit
+	t.Cleanup(func() { version.Version, version.Commit = oldVersion, oldCommit })
+	version.Version, version.Commit = "v1.2.0", "abc1234"
+
+	app := newTestApp(t, testCorpus)
+	router := mux.NewRouter()
+	app.registerRoutes(router)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "http://localhost/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("got Content-Type %q, want application/json", got)
+	}
+	// Decode loosely so the test checks the field names deploy scripts read
+	var got map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["status"] != "ok" || got["version"] != "v1.2.0" || got["commit"] != "abc1234" {
+		t.Errorf("got %s, want status ok with version v1.2.0 and commit abc1234", rec.Body)
+	}
+	if uptime, ok := got["uptime_seconds"].(float64); !ok || uptime < 0 {
+		t.Errorf("got uptime_seconds %v, want a number of seconds of at least 0", got["uptime_seconds"])
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/health", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("a remote host got status %d, want 404", rec.Code)
+	}
+}
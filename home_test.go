@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// getHomeJSON asks /api/home for target
+func getHomeJSON(app *App, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	app.homeJSONHandler(rec, httptest.NewRequest("GET", target, nil))
+	return rec
+}
+
+func TestHomeJSONCount(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{target: "/api/home", want: defaultHomePostCount},
+		{target: "/api/home?count=5", want: 5},
+		{target: "/api/home?count=0", want: 1},
+		{target: "/api/home?count=1000", want: maxHomePostCount},
+	}
+	for _, tt := range tests {
+		rec := getHomeJSON(app, tt.target)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s got status %d: %s", tt.target, rec.Code, rec.Body)
+		}
+		var posts []HomePost
+		if err := json.Unmarshal(rec.Body.Bytes(), &posts); err != nil {
+			t.Fatal(err)
+		}
+		if len(posts) != tt.want {
+			t.Errorf("%s returned %d posts, want %d", tt.target, len(posts), tt.want)
+		}
+		for i, post := range posts {
+			if !strings.HasPrefix(post.Url, "/post/") || post.Title == "" {
+				t.Errorf("%s post %d has url %q and title %q", tt.target, i, post.Url, post.Title)
+			}
+		}
+
+		// The posts are the same all day
+		if again := getHomeJSON(app, tt.target).Body.String(); again != rec.Body.String() {
+			t.Errorf("%s changed between requests", tt.target)
+		}
+	}
+
+	if rec := getHomeJSON(app, "/api/home?count=many"); rec.Code != http.StatusBadRequest {
+		t.Errorf("a count that isn't a number got status %d, want 400", rec.Code)
+	}
+}
+
+// cancellingClock ends pauses at once until the nth, which cancels the
+// request instead
+type cancellingClock struct {
+	n      int
+	pauses int
+	cancel context.CancelFunc
+}
+
+func (c *cancellingClock) Now() time.Time { return time.Unix(0, 0) }
+
+func (c *cancellingClock) After(d time.Duration) <-chan time.Time {
+	c.pauses++
+	if c.pauses >= c.n {
+		c.cancel()
+		return nil
+	}
+	after := make(chan time.Time, 1)
+	after <- time.Unix(0, 0).Add(d)
+	return after
+}
+
+// failingWriter accepts the first write and fails every one after it
+type failingWriter struct {
+	*httptest.ResponseRecorder
+	writes int
+}
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	w.writes++
+	if w.writes > 1 {
+		return 0, errors.New("connection reset")
+	}
+	return w.ResponseRecorder.Write(b)
+}
+
+func TestHomePageStopsEarly(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	app.streaming = true
+	cards := func(body string) int {
+		return strings.Count(body, `class="post-card"`)
+	}
+
+	// A reader leaving after the third card stops the grid there
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	app.clock = &cancellingClock{n: 3, cancel: cancel}
+	rec := httptest.NewRecorder()
+	app.homeHandler(rec, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+	if n := cards(rec.Body.String()); n != 3 || strings.Contains(rec.Body.String(), "</html>") {
+		t.Errorf("a cancelled home page streamed %d cards:\n%s", n, rec.Body)
+	}
+
+	// So does the first failed write
+	app.clock = &recordingClock{}
+	w := &failingWriter{ResponseRecorder: httptest.NewRecorder()}
+	app.homeHandler(w, httptest.NewRequest("GET", "/", nil))
+	if w.writes != 2 {
+		t.Errorf("the home page kept writing %d times after a write failed", w.writes-2)
+	}
+
+	// Crawlers get the whole grid without pauses
+	clock := &recordingClock{}
+	app.clock = clock
+	app.instantBots = true
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("User-Agent", googlebotUA)
+	rec = httptest.NewRecorder()
+	app.homeHandler(rec, req)
+	if n := cards(rec.Body.String()); n != defaultHomePostCount || len(clock.pauses) != 0 {
+		t.Errorf("a crawler got %d cards after %d pauses", n, len(clock.pauses))
+	}
+}
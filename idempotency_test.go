@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyLocksOnlyBlockTheSameKey(t *testing.T) {
+	var locks keyLocks
+	unlockA := locks.lock("a")
+
+	// Another key doesn't wait for a
+	done := make(chan struct{})
+	go func() {
+		locks.lock("b")()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("locking b waited for a")
+	}
+
+	// The same key does
+	acquired := make(chan struct{})
+	go func() {
+		unlock := locks.lock("a")
+		close(acquired)
+		unlock()
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("a was locked twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlockA()
+	<-acquired
+
+	locks.mu.Lock()
+	defer locks.mu.Unlock()
+	if len(locks.locks) != 0 {
+		t.Errorf("%d locks left after every key was unlocked", len(locks.locks))
+	}
+}
+
+func TestConcurrentRetriesTrainOnce(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	before, err := app.store.CountMarkovChainModels()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	codes := make([]int, 4)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = postTrain(app, "/api/train", "same-key", testCorpus).Code
+		}()
+	}
+	wg.Wait()
+
+	for i, code := range codes {
+		if code != http.StatusCreated {
+			t.Errorf("request %d got status %d, want 201", i, code)
+		}
+	}
+	after, err := app.store.CountMarkovChainModels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after != before+1 {
+		t.Errorf("%d concurrent retries created %d models, want 1", len(codes), after-before)
+	}
+}
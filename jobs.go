@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/abigpotostew/endless/routes"
+	"github.com/abigpotostew/endless/store"
+	"github.com/abigpotostew/endless/train"
+	"github.com/gorilla/mux"
+)
+
+// trainingJobPoll is how often the worker looks for queued jobs when it
+// hasn't been woken by a new one
+const trainingJobPoll = time.Minute
+
+// TrainingJobResponse is returned when a corpus is queued for training
+type TrainingJobResponse struct {
+	Success bool               `json:"success"`
+	Error   string             `json:"error,omitempty"`
+	Job     *store.TrainingJob `json:"job,omitempty"`
+}
+
+// enqueueTraining saves text as a training job and wakes the worker
+func (app *App) enqueueTraining(text string, opts train.TrainOptions) (*store.TrainingJob, error) {
+	options, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+	job, err := app.store.EnqueueTrainingJob(text, options)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case app.trainingJobs <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// writeQueuedJob answers a request that queued job with a 202 linking to its status
+func writeQueuedJob(w http.ResponseWriter, job *store.TrainingJob) {
+	w.Header().Set("Location", sitePath(fmt.Sprintf("/api/train/jobs/%d", job.ID)))
+	routes.WriteJSON(w, http.StatusAccepted, TrainingJobResponse{
+		Success: true,
+		Job:     job,
+	})
+}
+
+// runTrainingJobs trains queued jobs one at a time, forever. Jobs left
+// running by a previous process are queued again first.
+func (app *App) runTrainingJobs() {
+	if err := app.store.RequeueRunningTrainingJobs(); err != nil {
+		log.Printf("Failed to requeue running training jobs: %v", err)
+	}
+	for {
+		job, err := app.store.ClaimTrainingJob()
+		if err != nil {
+			log.Printf("Failed to claim training job: %v", err)
+		}
+		if job == nil {
+			select {
+			case <-app.trainingJobs:
+			case <-time.After(trainingJobPoll):
+			}
+			continue
+		}
+		app.runTrainingJob(job)
+	}
+}
+
+// runTrainingJob trains one claimed job and records the outcome
+func (app *App) runTrainingJob(job *store.TrainingJob) {
+	var opts train.TrainOptions
+	var modelID int
+	err := json.Unmarshal([]byte(job.Options), &opts)
+	if err == nil {
+		var model *store.MarkovChainModel
+		model, err = app.trainModel(job.Corpus, opts)
+		if model != nil {
+			modelID = model.ID
+		}
+	}
+	if err != nil {
+		log.Printf("Training job %d failed: %v", job.ID, err)
+	} else {
+		log.Printf("Training job %d created model %d", job.ID, modelID)
+	}
+	if err := app.store.FinishTrainingJob(job.ID, modelID, err); err != nil {
+		log.Printf("Failed to record outcome of training job %d: %v", job.ID, err)
+	}
+}
+
+// trainingJobHandler reports the status of a queued training job
+func (app *App) trainingJobHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the job ID from the URL
+	vars := mux.Vars(r)
+	id, err := parseModelID(vars["id"])
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Invalid job ID: "+err.Error())
+		return
+	}
+
+	job, err := app.store.GetTrainingJob(id)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusNotFound, "Failed to retrieve job: "+err.Error())
+		return
+	}
+	routes.WriteJSON(w, http.StatusOK, job)
+}
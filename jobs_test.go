@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/abigpotostew/endless/store"
+	"github.com/gorilla/mux"
+)
+
+// postTrain sends corpus to the train handler with the Idempotency-Key key
+func postTrain(app *App, target, key, corpus string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", target, strings.NewReader(corpus))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	app.trainMarkovModelHandler(rec, req)
+	return rec
+}
+
+// getJob asks the job handler for the status of job id
+func getJob(t *testing.T, app *App, location string) store.TrainingJob {
+	t.Helper()
+	req := httptest.NewRequest("GET", location, nil)
+	req = mux.SetURLVars(req, map[string]string{"id": location[strings.LastIndex(location, "/")+1:]})
+	rec := httptest.NewRecorder()
+	app.trainingJobHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("job status returned %d: %s", rec.Code, rec.Body)
+	}
+	var job store.TrainingJob
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestAsyncTrainingJobLifecycle(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+
+	rec := postTrain(app, "/api/train?async=1", "retry-me", testCorpus)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("got status %d, want 202: %s", rec.Code, rec.Body)
+	}
+	location := rec.Header().Get("Location")
+	if location == "" {
+		t.Fatal("no Location header")
+	}
+	if job := getJob(t, app, location); job.Status != store.JobQueued {
+		t.Errorf("new job is %q, want %q", job.Status, store.JobQueued)
+	}
+
+	// A retry replays the 202 for the same job instead of queuing another
+	retry := postTrain(app, "/api/train?async=1", "retry-me", testCorpus)
+	if retry.Code != http.StatusAccepted {
+		t.Fatalf("retry got status %d, want 202: %s", retry.Code, retry.Body)
+	}
+	if got := retry.Header().Get("Location"); got != location {
+		t.Errorf("retry Location is %q, want %q", got, location)
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("retry wasn't marked as replayed")
+	}
+
+	job, err := app.store.ClaimTrainingJob()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job == nil {
+		t.Fatal("no job was queued")
+	}
+	if getJob(t, app, location).Status != store.JobRunning {
+		t.Errorf("claimed job isn't %q", store.JobRunning)
+	}
+	app.runTrainingJob(job)
+	if next, err := app.store.ClaimTrainingJob(); err != nil || next != nil {
+		t.Errorf("the retry queued another job: %v, %v", next, err)
+	}
+
+	done := getJob(t, app, location)
+	if done.Status != store.JobDone || done.ModelID == nil {
+		t.Fatalf("finished job is %q with model %v", done.Status, done.ModelID)
+	}
+	current, err := app.getLatestModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.ID != *done.ModelID {
+		t.Errorf("current model is %d, want the job's model %d", current.ID, *done.ModelID)
+	}
+
+	// Once trained, a retry reports the finished job
+	var replayed TrainingJobResponse
+	if err := json.Unmarshal(postTrain(app, "/api/train?async=1", "retry-me", testCorpus).Body.Bytes(), &replayed); err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Job == nil || replayed.Job.Status != store.JobDone {
+		t.Errorf("replayed job is %+v, want it done", replayed.Job)
+	}
+}
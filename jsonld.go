@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// Schema.org types used for the JSON-LD structured data blocks
+
+type ImageObject struct {
+	Type string `json:"@type"`
+	URL  string `json:"url"`
+}
+
+type Organization struct {
+	Type string      `json:"@type"`
+	Name string      `json:"name"`
+	Logo ImageObject `json:"logo"`
+}
+
+type Person struct {
+	Type        string `json:"@type"`
+	Name        string `json:"name"`
+	URL         string `json:"url,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type WebPage struct {
+	Type string `json:"@type"`
+	ID   string `json:"@id"`
+}
+
+type SearchAction struct {
+	Type       string `json:"@type"`
+	Target     string `json:"target"`
+	QueryInput string `json:"query-input"`
+}
+
+type WebSiteLD struct {
+	Context         string       `json:"@context"`
+	Type            string       `json:"@type"`
+	Name            string       `json:"name"`
+	Description     string       `json:"description"`
+	URL             string       `json:"url"`
+	Publisher       Organization `json:"publisher"`
+	PotentialAction SearchAction `json:"potentialAction"`
+}
+
+type ArticleLD struct {
+	Context          string       `json:"@context"`
+	Type             string       `json:"@type"`
+	Headline         string       `json:"headline"`
+	Description      string       `json:"description"`
+	Image            string       `json:"image"`
+	Author           Person       `json:"author"`
+	Publisher        Organization `json:"publisher"`
+	DatePublished    string       `json:"datePublished"`
+	DateModified     string       `json:"dateModified"`
+	MainEntityOfPage WebPage      `json:"mainEntityOfPage"`
+	WordCount        int          `json:"wordCount"`
+	ArticleSection   string       `json:"articleSection"`
+	Keywords         string       `json:"keywords"`
+}
+
+type ProfilePageLD struct {
+	Context    string `json:"@context"`
+	Type       string `json:"@type"`
+	MainEntity Person `json:"mainEntity"`
+}
+
+// siteOrganization returns the publisher block shared by every page
+func siteOrganization(baseURL string) Organization {
+	return Organization{
+		Type: "Organization",
+		Name: "Endless Stories",
+		Logo: ImageObject{
+			Type: "ImageObject",
+			URL:  baseURL + "/logo.png",
+		},
+	}
+}
+
+// Helper function to encode structured data for a <script type="application/ld+json"> block.
+// json.Marshal escapes <, > and & as unicode escapes so the output can't close the script tag early.
+func jsonLDScript(v interface{}) string {
+	data, err := json.MarshalIndent(v, "    ", "    ")
+	if err != nil {
+		log.Printf("Failed to marshal JSON-LD: %v", err)
+		return "{}"
+	}
+	return string(data)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONLDScriptEscaping(t *testing.T) {
+	for _, title := range []string{
+		`He said "stop"`,
+		`C:\temp\new`,
+		`The end</script><script>alert(1)</script>`,
+		`Fish & <chips>`,
+	} {
+		script := jsonLDScript(ArticleLD{Type: "Article", Headline: title, Author: Person{Type: "Person", Name: title}})
+		if strings.ContainsAny(script, "<>") {
+			t.Errorf("%q: the script has a raw angle bracket: %s", title, script)
+		}
+		var article ArticleLD
+		if err := json.Unmarshal([]byte(script), &article); err != nil {
+			t.Fatalf("%q: %v", title, err)
+		}
+		if article.Headline != title || article.Author.Name != title {
+			t.Errorf("%q: decoded headline %q and author %q", title, article.Headline, article.Author.Name)
+		}
+	}
+}
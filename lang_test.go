@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abigpotostew/endless/train"
+	"github.com/gorilla/mux"
+)
+
+func TestSiteLanguage(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	app.streamTimeout = time.Minute
+	story, err := app.generatePage(42, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strings.TrimPrefix(story.Link.Url, "/post/")
+	// pages renders every HTML page, keyed by name
+	pages := func() map[string]string {
+		amp := httptest.NewRecorder()
+		app.ampHandler(amp, mux.SetURLVars(httptest.NewRequest("GET", story.Link.Url+"/amp", nil), map[string]string{"id": id}))
+		home := httptest.NewRecorder()
+		app.homeHandler(home, httptest.NewRequest("GET", "/", nil))
+		notFound := httptest.NewRecorder()
+		app.notFoundHandler(notFound, httptest.NewRequest("GET", "/nowhere", nil))
+		return map[string]string{
+			"post":      getPost(app, story.Link.Url, "").Body.String(),
+			"amp":       amp.Body.String(),
+			"home":      home.Body.String(),
+			"author":    getAuthor(app, train.AuthorSlug(story.Author)).Body.String(),
+			"not found": notFound.Body.String(),
+		}
+	}
+	htmlLang := regexp.MustCompile(`<html[^>]* lang="([^"]*)"`)
+
+	tests := []struct {
+		lang, locale, language string
+	}{
+		{lang: "en", locale: "en_US", language: "English"},
+		{lang: "de", locale: "de_DE", language: "de"},
+	}
+	for _, tt := range tests {
+		app.siteLang, app.siteLocale = tt.lang, tt.locale
+		for name, page := range pages() {
+			if match := htmlLang.FindStringSubmatch(page); match == nil || match[1] != tt.lang {
+				t.Errorf("%s: the %s page has <html lang> %q", tt.lang, name, match)
+			}
+			// Only the error and AMP pages go without Open Graph tags
+			if name != "not found" && name != "amp" && !strings.Contains(page, `<meta property="og:locale" content="`+tt.locale+`">`) {
+				t.Errorf("%s: the %s page doesn't have og:locale %s", tt.lang, name, tt.locale)
+			}
+			if (name == "post" || name == "home") && !strings.Contains(page, `<meta name="language" content="`+tt.language+`">`) {
+				t.Errorf("%s: the %s page doesn't have language %s", tt.lang, name, tt.language)
+			}
+		}
+	}
+}
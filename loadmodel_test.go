@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoadModelFallsBackPastBrokenModels(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	good, err := app.getLatestModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken, err := app.store.SaveMarkovChainModel([]byte("not a model"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.clearModelCache()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	model, _, err := app.loadModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.ID != good.ID {
+		t.Errorf("loaded model %d, want the last good model %d", model.ID, good.ID)
+	}
+	if want := fmt.Sprintf("Failed to load model %d, trying an older one", broken.ID); !strings.Contains(logs.String(), want) {
+		t.Errorf("log %q doesn't mention %q", logs.String(), want)
+	}
+}
@@ -1,24 +1,37 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html"
 	"io"
 	"log"
+	"math"
 	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/abigpotostew/endless/config"
 	"github.com/abigpotostew/endless/routes"
 	"github.com/abigpotostew/endless/store"
 	"github.com/abigpotostew/endless/train"
+	"github.com/abigpotostew/endless/version"
 
 	"github.com/gorilla/mux"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/sync/singleflight"
 )
 
 type CreateMarkovModelRequest struct {
@@ -27,20 +40,293 @@ type CreateMarkovModelRequest struct {
 	Model   *store.MarkovChainModel `json:"model,omitempty"`
 }
 
+type HomePost struct {
+	Title   string `json:"title"`
+	Excerpt string `json:"excerpt"`
+	Author  string `json:"author"`
+	Date    string `json:"date"`
+	Url     string `json:"url"`
+}
+
+type GenerateRequest struct {
+	Model json.RawMessage `json:"model"`
+	Seed  *int64          `json:"seed"`
+}
+
+type ClearCacheResponse struct {
+	Success bool `json:"success"`
+}
+
 type App struct {
-	store       store.PostStore
-	cachedModel *store.MarkovChainModel
+	store store.PostStore
+	// publicHost, siteLang and siteLocale are the PUBLIC_HOST, SITE_LANG
+	// and SITE_LOCALE settings, see config.Config
+	publicHost string
+	siteLang   string
+	siteLocale string
+
+	cacheMu       sync.Mutex
+	cachedModel   *store.MarkovChainModel
+	cachedChain   train.MarkovChain
+	excerptLength int
+	streamTimeout time.Duration
+	typingCurve   bool
+	analyticsHtml string
+	minVocabulary int
+
+	// maxResponseBytes stops streamed pages once they reach this size; zero disables it
+	maxResponseBytes int64
+
+	// Streaming is paced by clock with jitter drawn from jitterSource; both
+	// default to real time when nil
+	clock        Clock
+	jitterSource func() *rand.Rand
+
+	// instantBots serves crawlers and link unfurlers without streaming delays
+	instantBots bool
+	// streaming sends pages as they are written, with typing delays
+	streaming bool
+	// sentenceExcerpts ends card excerpts on a complete sentence when one fits
+	sentenceExcerpts bool
+	// sentenceDescriptions ends meta descriptions on a complete sentence
+	// when one fits
+	sentenceDescriptions bool
+	// seedThemes gives each post its own accent color, see train.ThemeColorForSeed
+	seedThemes bool
+	// homePublishedPosts shows up to this many published posts on the home
+	// page in place of generated ones; zero turns it off
+	homePublishedPosts int
+
+	// importSentinels are the MODEL_START_TOKEN and MODEL_END_TOKEN
+	// settings, the delimiters of imported models; empty tokens keep
+	// train.DefaultSentinels
+	importSentinels train.Sentinels
+
+	// websubHub is notified that websubTopic changed whenever a model is
+	// trained or updated; empty disables it
+	websubHub   string
+	websubTopic string
+
+	// trainingJobs wakes the training worker when a job is queued
+	trainingJobs chan struct{}
+
+	// cacheGen counts cache clears, so a load that started before a clear
+	// doesn't cache the model it read
+	cacheGen uint64
+	// modelLoads lets concurrent cold readers share one database load
+	modelLoads singleflight.Group
+	// trending caches the trending posts generated for cache generation
+	// trendingGen, guarded by cacheMu
+	trending    []train.GeneratedPage
+	trendingGen uint64
+
+	// Readiness results are cached briefly so frequent probes stay cheap
+	readyMu        sync.Mutex
+	readyCheckedAt time.Time
+	readyErr       error
+
+	// Models created by POST /api/train, keyed by the request's Idempotency-Key
+	idempotencyMu   sync.Mutex
+	idempotencyKeys map[string]idempotentTrain
+	// idempotencyLocks serializes requests that share an Idempotency-Key
+	idempotencyLocks keyLocks
+}
+
+// readyCacheTTL is how long a readiness result is reused before checking again
+const readyCacheTTL = 10 * time.Second
+
+// idempotencyKeyTTL is how long an Idempotency-Key is remembered after its model is created
+const idempotencyKeyTTL = 24 * time.Hour
+
+// idempotentTrain records the model created for an Idempotency-Key, or the
+// job queued for it by an asynchronous request
+type idempotentTrain struct {
+	model     *store.MarkovChainModel
+	jobID     int
+	createdAt time.Time
+}
+
+// keyLocks is a set of mutexes created on demand, one per key, so requests
+// for one key wait for each other without holding up other keys. The zero
+// value is ready to use.
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+// keyLock is one key's mutex and the number of requests holding or waiting
+// for it
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock locks key and returns the function that unlocks it. The key's mutex
+// is dropped once nobody holds or waits for it.
+func (k *keyLocks) lock(key string) func() {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = make(map[string]*keyLock)
+	}
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyLock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		k.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+// defaultContentSecurityPolicy allows the inline page styles and, when
+// statsHost is set, the analytics script served from it. JSON-LD blocks are
+// data, not script, so they need no exception.
+func defaultContentSecurityPolicy(statsHost string) string {
+	stats := ""
+	if statsHost != "" {
+		stats = " " + statsHost
+	}
+	return "default-src 'self'; " +
+		"script-src 'self'" + stats + "; " +
+		"connect-src 'self'" + stats + "; " +
+		"img-src 'self' data:" + stats + "; " +
+		"style-src 'self' 'unsafe-inline'; " +
+		"base-uri 'self'; form-action 'self'; frame-ancestors 'none'"
+}
+
+// analyticsSettings returns the analytics html injected into every page and
+// the host the default Content-Security-Policy lets it load scripts from. A
+// snippet takes precedence over a goatcounter count URL; its scripts must come
+// from scriptHost, or the page's own origin when that is empty, since inline
+// scripts are blocked.
+func analyticsSettings(snippet, scriptHost, countURL string) (string, string, error) {
+	if snippet != "" {
+		if strings.ContainsAny(scriptHost, " \t;,'\"") {
+			return "", "", fmt.Errorf("Invalid ANALYTICS_SCRIPT_HOST %q", scriptHost)
+		}
+		return snippet, scriptHost, nil
+	}
+	if countURL == "" {
+		return "", "", nil
+	}
+	parsed, err := url.Parse(countURL)
+	if err != nil || parsed.Host == "" {
+		return "", "", fmt.Errorf("Invalid GOATCOUNTER_URL %q", countURL)
+	}
+	return goatcounterSnippet(parsed), parsed.Host, nil
+}
+
+// goatcounterSnippet returns the goatcounter script tag that reports to
+// countURL, e.g. https://stats.example.com/count
+func goatcounterSnippet(countURL *url.URL) string {
+	return `<script data-goatcounter="` + html.EscapeString(countURL.String()) + `"
+        async src="//` + html.EscapeString(countURL.Host) + `/count.js"></script>`
 }
 
-const statsHtml = `<script data-goatcounter="https://stats.stewart.codes/count"
-        async src="//stats.stewart.codes/count.js"></script>`
+// configureGeneration applies the settings that tune the train package. Zero
+// settings keep the package defaults.
+func configureGeneration(cfg *config.Config) {
+	// Bias related links toward the page's vocabulary instead of random seeds
+	if cfg.RelatedLinksMode == "vocabulary" {
+		train.RelatedByVocabulary = true
+	}
+
+	// Credit some authors with more posts
+	if cfg.AuthorWeights != nil {
+		train.AuthorWeights = cfg.AuthorWeights
+	}
+
+	// Keep long generated sentences from cluttering the related stories list
+	if cfg.LinkTitleMaxWords > 0 {
+		train.LinkTitleMaxWords = cfg.LinkTitleMaxWords
+	}
+
+	// Longer slugs keep more of the title in post URLs
+	if cfg.SlugMaxLength > 0 {
+		train.MaxSlugLength = cfg.SlugMaxLength
+	}
+
+	// Window of past dates assigned to generated posts, e.g. 168h for the last week
+	if cfg.PostDateMaxAge > 0 {
+		train.PostDateMaxAge = cfg.PostDateMaxAge
+	}
+	if cfg.PostDateMinAge > 0 {
+		train.PostDateMinAge = cfg.PostDateMinAge
+	}
+	if train.PostDateMinAge > train.PostDateMaxAge {
+		log.Fatalf("POST_DATE_MIN_AGE (%v) must not exceed POST_DATE_MAX_AGE (%v)", train.PostDateMinAge, train.PostDateMaxAge)
+	}
+
+	// Trim runaway generations back to their last complete sentence instead of failing
+	train.ClampToSentence = cfg.ClampToSentence
+
+	// Replace the line patterns strip_headings removes with a single regex
+	if cfg.HeadingPattern != nil {
+		train.HeadingPatterns = []*regexp.Regexp{cfg.HeadingPattern}
+	}
+
+	// Choose how generated sentences pick each next word
+	if cfg.Generator != "" {
+		generator, err := train.GeneratorByName(cfg.Generator)
+		if err != nil {
+			log.Fatalf("Invalid GENERATOR: %v", err)
+		}
+		train.DefaultGenerator = generator
+	}
+
+	// Cut rambling sentences short after this many words
+	if cfg.MaxSentenceWords > 0 {
+		train.MaxSentenceWords = cfg.MaxSentenceWords
+	}
+
+	// Redraw tokens already used on a page to vary the story body
+	if cfg.RepetitionPenalty > 0 {
+		train.RepetitionPenalty = cfg.RepetitionPenalty
+	}
+
+	// Replace the punctuation that ends a sentence, e.g. ". ! ? …"
+	if len(cfg.SentenceTerminators) > 0 {
+		train.SentenceTerminators = cfg.SentenceTerminators
+	}
+
+	// Optionally break generated stories into paragraphs of N sentences
+	if cfg.ParagraphSentences > 0 {
+		train.SentencesPerParagraph = cfg.ParagraphSentences
+	}
+
+	// Aim for home page posts of at least this many characters
+	if cfg.HomeMinContentLength > 0 {
+		train.HomePageMinContentLength = cfg.HomeMinContentLength
+	}
+	if cfg.HomeMinWords > 0 {
+		train.HomePageMinWords = cfg.HomeMinWords
+	}
+
+	// Regenerations allowed per post across all checks before the best attempt is kept
+	if cfg.MaxRegenerations != nil {
+		train.MaxRegenerations = *cfg.MaxRegenerations
+	}
+}
 
 func main() {
-	sqliteDbPath := os.Getenv("SQLITE_DB_DIR")
-	if sqliteDbPath == "" {
-		sqliteDbPath = "."
+	// Settings come from the environment, then CONFIG_FILE, then defaults
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
 	}
-	sqliteDbPath = filepath.Join(sqliteDbPath, "endless.db")
+	basePath = cfg.BasePath
+
+	sqliteDbPath := filepath.Join(cfg.SQLiteDBDir, "endless.db")
 	// Initialize database store
 	postStore, err := store.NewSQLiteStore(sqliteDbPath)
 	if err != nil {
@@ -54,7 +340,61 @@ func main() {
 		log.Fatal(err)
 	}
 
-	app := &App{store: postStore}
+	// Optionally delete old models after each save so the database stops growing
+	if cfg.ModelRetention > 0 {
+		postStore.SetModelRetention(cfg.ModelRetention)
+	}
+
+	configureGeneration(cfg)
+
+	// Analytics are off unless configured, either as a goatcounter count URL
+	// or as a raw snippet injected into every page
+	analyticsHtml, statsHost, err := analyticsSettings(cfg.AnalyticsSnippet, cfg.AnalyticsScriptHost, cfg.GoatcounterURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	app := &App{
+		store:                postStore,
+		publicHost:           cfg.PublicHost,
+		siteLang:             cfg.SiteLang,
+		siteLocale:           cfg.SiteLocale,
+		excerptLength:        cfg.ExcerptLength,
+		streamTimeout:        cfg.StreamTimeout,
+		typingCurve:          cfg.TypingCurve,
+		analyticsHtml:        analyticsHtml,
+		minVocabulary:        cfg.MinVocabulary,
+		maxResponseBytes:     cfg.MaxResponseBytes,
+		instantBots:          cfg.InstantBots,
+		streaming:            cfg.StreamingEnabled,
+		sentenceExcerpts:     cfg.ExcerptSentences,
+		sentenceDescriptions: cfg.MetaDescriptionSentences,
+		seedThemes:           cfg.ThemeColors,
+		homePublishedPosts:   cfg.HomePublishedPosts,
+		websubHub:            cfg.WebSubHubURL,
+		websubTopic:          cfg.WebSubTopic,
+		importSentinels:      train.Sentinels{Start: cfg.ModelStartToken, End: cfg.ModelEndToken},
+		trainingJobs:         make(chan struct{}, 1),
+	}
+	// Train a first model from a corpus file so a new deployment can serve pages
+	if cfg.BootstrapCorpus != "" {
+		if err := app.bootstrap(cfg.BootstrapCorpus); err != nil {
+			log.Fatalf("Failed to bootstrap from BOOTSTRAP_CORPUS: %v", err)
+		}
+	}
+
+	// Train corpora queued with POST /api/train?async=1 in the background
+	go app.runTrainingJobs()
+
+	if app.minVocabulary > 0 {
+		// Report an undersized model at startup rather than on the first probe
+		app.checkReady()
+	}
+
+	// Optionally watch for models written to the database by another process
+	if cfg.ModelPollInterval > 0 {
+		go app.pollModels(cfg.ModelPollInterval)
+	}
 
 	// Setup router
 	r := mux.NewRouter()
@@ -62,56 +402,178 @@ func main() {
 	// Add logging middleware to all routes
 	r.Use(routes.LoggingMiddleware)
 
+	// Security headers limit the damage of any escaping mistake in generated pages
+	contentSecurityPolicy := defaultContentSecurityPolicy(statsHost)
+	if cfg.ContentSecurityPolicy != nil {
+		contentSecurityPolicy = *cfg.ContentSecurityPolicy
+	}
+	r.Use(routes.SecurityHeaders(contentSecurityPolicy, cfg.ReferrerPolicy))
+
+	// Keep API and error responses out of search indexes
+	r.Use(routes.NoIndexUnder(basePath))
+
+	// Optionally gzip responses big enough to benefit, e.g. GZIP_LEVEL=6
+	if cfg.GzipLevel != 0 {
+		r.Use(routes.GzipMiddleware(cfg.GzipLevel, cfg.GzipMinSize))
+	}
+
+	// Without streaming, pages are built in memory and sent in one write
+	if !app.streaming {
+		r.Use(routes.BufferMiddleware)
+	}
+	r.NotFoundHandler = routes.LoggingMiddleware(routes.SecurityHeaders(contentSecurityPolicy, cfg.ReferrerPolicy)(routes.NoIndexUnder(basePath)(http.HandlerFunc(app.notFoundHandler))))
+
+	// Under a BASE_PATH every route lives below the prefix, and the bare
+	// prefix redirects to the home page
+	router := r
+	if basePath != "" {
+		r.Handle(basePath, http.RedirectHandler(basePath+"/", http.StatusMovedPermanently))
+		r = r.PathPrefix(basePath).Subrouter()
+	}
+
+	app.registerRoutes(r)
+	checkOpenAPIPaths(router)
+
+	// Start server
+	//accept port from env
+	port := cfg.Port
+	// Keep-alive is managed by the server, so handlers must not set the
+	// hop-by-hop Connection header themselves (it is invalid under HTTP/2).
+	// There is no WriteTimeout because it would cut off streamed pages;
+	// streamPage enforces its own deadline instead.
+	log.Printf("endless %s (commit %s)", version.Version, version.Commit)
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           router,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Fatal(serve(server, listener, cfg))
+}
+
+// serve accepts connections on listener, serving HTTPS directly when
+// certificates are configured
+func serve(server *http.Server, listener net.Listener, cfg *config.Config) error {
+	addr := listener.Addr().String()
+	certFile, keyFile := cfg.TLSCertFile, cfg.TLSKeyFile
+	switch {
+	case len(cfg.AutocertDomains) > 0:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.AutocertDomains...),
+			Cache:      autocert.DirCache(cfg.AutocertCacheDir),
+		}
+		server.TLSConfig = manager.TLSConfig()
+		log.Println("Server starting with Let's Encrypt certificates on " + addr)
+		return server.ServeTLS(listener, "", "")
+	case certFile != "" || keyFile != "":
+		log.Println("Server starting with TLS on " + addr)
+		return server.ServeTLS(listener, certFile, keyFile)
+	default:
+		log.Println("Server starting on " + addr)
+		return server.Serve(listener)
+	}
+}
+
+// registerRoutes adds the pages and API endpoints of app to r
+func (app *App) registerRoutes(r *mux.Router) {
 	// Serve static files
 	r.HandleFunc("/", app.homeHandler).Methods("GET")
 	r.HandleFunc("/sitemap.xml", app.sitemapHandler).Methods("GET")
 	r.HandleFunc("/robots.txt", app.robotsHandler).Methods("GET")
 	r.HandleFunc("/post/{id}", app.generatePageStreamHandler).Methods("GET")
+	r.HandleFunc("/post/{id}/related.json", app.relatedJSONHandler).Methods("GET")
+	r.HandleFunc("/post/{id}/reroll", app.rerollHandler).Methods("GET")
+	r.HandleFunc("/post/{id}/amp", app.ampHandler).Methods("GET")
+	r.HandleFunc("/author/{name}", app.authorHandler).Methods("GET")
+	r.HandleFunc("/trending", app.trendingHandler).Methods("GET")
+	r.HandleFunc("/search", app.searchHandler).Methods("GET")
+	r.HandleFunc("/api/home", app.homeJSONHandler).Methods("GET")
+	r.HandleFunc("/api/openapi.json", app.openAPIHandler).Methods("GET")
 	// need to restrict these to only allow requests from localhost
 	r.HandleFunc("/health", app.healthHandler).Methods("GET").Host("localhost")
+	r.HandleFunc("/ready", app.readyHandler).Methods("GET").Host("localhost")
 	r.HandleFunc("/api/train", app.trainMarkovModelHandler).Methods("POST").Host("localhost")
+	r.HandleFunc("/api/train/jobs/{id}", app.trainingJobHandler).Methods("GET").Host("localhost")
+	r.HandleFunc("/api/posts/{id}", app.publishPostHandler).Methods("POST").Host("localhost")
 	r.HandleFunc("/api/train/{id}", app.updateMarkovModelHandler).Methods("PUT").Host("localhost")
-
-	// Start server
-	//accept port from env
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-	log.Println("Server starting on :" + port)
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	r.HandleFunc("/api/train/import", app.importMarkovModelHandler).Methods("POST").Host("localhost")
+	r.HandleFunc("/api/train/batch", app.trainBatchHandler).Methods("POST").Host("localhost")
+	r.HandleFunc("/api/train/validate", app.validateCorpusHandler).Methods("POST").Host("localhost")
+	r.HandleFunc("/api/train/{id}/export", app.exportMarkovModelHandler).Methods("GET").Host("localhost")
+	r.HandleFunc("/api/train/{id}/size", app.modelSizeHandler).Methods("GET").Host("localhost")
+	r.HandleFunc("/api/train/{id}/graph", app.graphMarkovModelHandler).Methods("GET").Host("localhost")
+	r.HandleFunc("/api/train/{id}/prune", app.pruneMarkovModelHandler).Methods("POST").Host("localhost")
+	r.HandleFunc("/api/train/{id}/options", app.modelOptionsHandler).Methods("GET").Host("localhost")
+	r.HandleFunc("/api/train/{id}/options", app.updateModelOptionsHandler).Methods("PUT").Host("localhost")
+	r.HandleFunc("/api/generate", app.generateInlineHandler).Methods("POST").Host("localhost")
+	r.Handle("/preview", routes.BufferMiddleware(http.HandlerFunc(app.previewHandler))).Methods("POST").Host("localhost")
+	r.HandleFunc("/api/variants", app.variantsHandler).Methods("GET").Host("localhost")
+	r.HandleFunc("/api/debug/generate", app.debugGenerateHandler).Methods("GET").Host("localhost")
+	r.HandleFunc("/api/debug/timing", app.debugTimingHandler).Methods("GET").Host("localhost")
+	r.HandleFunc("/api/cache/clear", app.clearCacheHandler).Methods("POST").Host("localhost")
+	r.HandleFunc("/api/metrics", app.metricsHandler).Methods("GET").Host("localhost")
 }
 
 func (app *App) homeHandler(w http.ResponseWriter, r *http.Request) {
 	// Set headers for HTML response
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
+	app.setHubLinks(w)
 
 	// Get the latest model using cache
-	model, err := app.getLatestModel()
+	chain, err := app.loadLatestChain()
 	if err != nil {
-		http.Error(w, "Failed to retrieve model: "+err.Error(), http.StatusInternalServerError)
+		app.writeErrorPage(w, http.StatusServiceUnavailable, "Stories are temporarily unavailable. Please try again soon.")
 		return
 	}
 
-	// Load the model from JSON data
-	chain, err := train.LoadModel([]byte(model.ModelData))
+	// Generate 12 posts for the grid (3x4 layout)
+	posts, err := app.homePosts(chain, defaultHomePostCount)
 	if err != nil {
-		http.Error(w, "Failed to load model: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Failed to generate home page posts: %v", err)
+		app.writeErrorPage(w, generationErrorStatus(err), "Something went wrong while writing today's stories.")
 		return
 	}
 
-	// Generate 12 posts for the grid (3x4 layout)
-	posts, err := train.GenerateHomePagePosts(chain, 12)
+	// One larger story above the grid, chosen from its own daily seed
+	featured, err := train.GenerateFeaturedPost(chain)
 	if err != nil {
-		http.Error(w, "Failed to generate posts: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Failed to generate featured post: %v", err)
+		app.writeErrorPage(w, generationErrorStatus(err), "Something went wrong while writing today's stories.")
 		return
 	}
 
+	// A few trending stories, which stay put until the model changes. A
+	// failure only costs the home page its trending section.
+	trending, err := app.trendingPosts(homeTrendingCount)
+	if err != nil {
+		log.Printf("Failed to generate trending posts: %v", err)
+		trending = nil
+	}
+
+	// Structured data is marshaled rather than concatenated so it is always valid JSON
+	websiteLD := WebSiteLD{
+		Context:     "https://schema.org",
+		Type:        "WebSite",
+		Name:        "Endless Stories",
+		Description: "Discover endless stories generated daily. A collection of unique narratives created with AI-powered Markov chains.",
+		URL:         app.fullURL(r),
+		Publisher:   siteOrganization(app.fullURL(r)),
+		PotentialAction: SearchAction{
+			Type:       "SearchAction",
+			Target:     app.fullURL(r) + "/search?q={search_term_string}",
+			QueryInput: "required name=search_term_string",
+		},
+	}
+
 	// Send the HTML header with SEO meta tags
 	headerHTML := `<!DOCTYPE html>
-<html lang="en">
+<html lang="` + html.EscapeString(app.siteLang) + `">
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
@@ -122,18 +584,18 @@ func (app *App) homeHandler(w http.ResponseWriter, r *http.Request) {
     <meta name="keywords" content="stories, fiction, narrative, creative writing, AI generated, markov chain, endless stories">
     <meta name="author" content="Endless Stories">
     <meta name="robots" content="index, follow">
-    <meta name="language" content="English">
+    <meta name="language" content="` + html.EscapeString(app.languageName()) + `">
     <meta name="revisit-after" content="1 day">
     <meta name="distribution" content="global">
     <meta name="rating" content="general">
     
     <!-- Open Graph / Facebook -->
     <meta property="og:type" content="website">
-    <meta property="og:url" content="` + html.EscapeString(getFullURL(r)) + `">
+    <meta property="og:url" content="` + html.EscapeString(app.fullURL(r)) + `">
     <meta property="og:title" content="Endless Stories - Daily Collection">
     <meta property="og:description" content="Discover endless stories generated daily. A collection of unique narratives created with AI-powered Markov chains.">
     <meta property="og:site_name" content="Endless Stories">
-    <meta property="og:locale" content="en_US">
+    <meta property="og:locale" content="` + html.EscapeString(app.siteLocale) + `">
     
     <!-- Twitter -->
     <meta name="twitter:card" content="summary_large_image">
@@ -142,34 +604,15 @@ func (app *App) homeHandler(w http.ResponseWriter, r *http.Request) {
     <meta name="twitter:site" content="@endlessstories">
     
     <!-- Canonical URL -->
-    <link rel="canonical" href="` + html.EscapeString(getFullURL(r)) + `">
+    <link rel="canonical" href="` + html.EscapeString(app.fullURL(r)) + `">
     
     <!-- Favicon -->
-    <link rel="icon" type="image/x-icon" href="/favicon.ico">
-    <link rel="apple-touch-icon" sizes="180x180" href="/apple-touch-icon.png">
+    <link rel="icon" type="image/x-icon" href="` + html.EscapeString(sitePath("/favicon.ico")) + `">
+    <link rel="apple-touch-icon" sizes="180x180" href="` + html.EscapeString(sitePath("/apple-touch-icon.png")) + `">
     
     <!-- Structured Data (JSON-LD) -->
     <script type="application/ld+json">
-    {
-        "@context": "https://schema.org",
-        "@type": "WebSite",
-        "name": "Endless Stories",
-        "description": "Discover endless stories generated daily. A collection of unique narratives created with AI-powered Markov chains.",
-        "url": "` + html.EscapeString(getFullURL(r)) + `",
-        "publisher": {
-            "@type": "Organization",
-            "name": "Endless Stories",
-            "logo": {
-                "@type": "ImageObject",
-                "url": "` + html.EscapeString(getFullURL(r)) + `/logo.png"
-            }
-        },
-        "potentialAction": {
-            "@type": "SearchAction",
-            "target": "` + html.EscapeString(getFullURL(r)) + `/search?q={search_term_string}",
-            "query-input": "required name=search_term_string"
-        }
-    }
+    ` + jsonLDScript(websiteLD) + `
     </script>
     
     <!-- Additional SEO Meta Tags -->
@@ -211,6 +654,58 @@ func (app *App) homeHandler(w http.ResponseWriter, r *http.Request) {
             opacity: 0.9;
         }
         
+        .featured {
+            background: white;
+            border-radius: 10px;
+            border-left: 6px solid #007cba;
+            padding: 30px;
+            margin-bottom: 30px;
+            box-shadow: 0 2px 10px rgba(0, 0, 0, 0.1);
+            text-decoration: none;
+            color: inherit;
+            display: block;
+        }
+        
+        .featured-label {
+            text-transform: uppercase;
+            letter-spacing: 0.1em;
+            font-size: 0.75em;
+            color: #007cba;
+            margin-bottom: 10px;
+        }
+        
+        .featured .post-title {
+            font-size: 2em;
+        }
+        
+        .featured .post-excerpt {
+            font-size: 1.05em;
+            -webkit-line-clamp: 6;
+        }
+        
+        .trending {
+            background: white;
+            border-radius: 10px;
+            padding: 20px 30px;
+            margin-bottom: 30px;
+            box-shadow: 0 2px 10px rgba(0, 0, 0, 0.1);
+        }
+        
+        .trending h2 {
+            margin: 0 0 10px 0;
+            font-size: 1.2em;
+            color: #333;
+        }
+        
+        .trending a {
+            color: ` + defaultThemeColor + `;
+            text-decoration: none;
+        }
+        
+        .trending-more {
+            font-size: 0.9em;
+        }
+        
         .posts-grid {
             display: grid;
             grid-template-columns: repeat(auto-fit, minmax(350px, 1fr));
@@ -298,7 +793,7 @@ func (app *App) homeHandler(w http.ResponseWriter, r *http.Request) {
             }
         }
     </style>
-	` + statsHtml + `
+	` + app.analyticsHtml + `
 </head>
 <body>
     <div class="header">
@@ -310,18 +805,30 @@ func (app *App) homeHandler(w http.ResponseWriter, r *http.Request) {
         <strong>New stories added daily!</strong> The collection refreshes every day at midnight.
     </div>
     
+    <a href="` + html.EscapeString(sitePath(featured.Link.Url)) + `" class="featured">
+        <div class="featured-label">Featured story</div>
+        <h2 class="post-title">` + html.EscapeString(featured.Link.Title) + `</h2>
+        <p class="post-excerpt">` + html.EscapeString(app.excerptOfLength(featured.Content, featuredExcerptScale*app.excerptLength)) + `</p>
+        <div class="post-meta">
+            <span class="post-author">` + html.EscapeString(featured.Author) + `</span>
+            <span class="post-date">` + featured.LastUpdated.Format("Jan 2, 2006") + `</span>
+        </div>
+    </a>
+    ` + trendingSectionHTML(trending) + `
     <div class="posts-grid">`
 
 	w.Write([]byte(headerHTML))
 	w.(http.Flusher).Flush()
 
+	pause := app.pauserFor(r)
+
 	// Stream each post card
 	for _, post := range posts {
-		// Create excerpt from content (first 150 characters)
-		excerpt := truncateString(post.Content, 150)
+		// Create excerpt from content
+		excerpt := app.excerpt(post.Content)
 
 		postCard := `
-        <a href="` + html.EscapeString(post.Link.Url) + `" class="post-card">
+        <a href="` + html.EscapeString(sitePath(post.Link.Url)) + `" class="post-card">
             <h2 class="post-title">` + html.EscapeString(post.Link.Title) + `</h2>
             <p class="post-excerpt">` + html.EscapeString(excerpt) + `</p>
             <div class="post-meta">
@@ -330,15 +837,28 @@ func (app *App) homeHandler(w http.ResponseWriter, r *http.Request) {
             </div>
         </a>`
 
-		w.Write([]byte(postCard))
+		if !app.fitsResponse(w, len(postCard), len(homeFooterHTML)) {
+			log.Printf("Home page stopped early at %d bytes", app.maxResponseBytes)
+			break
+		}
+		if _, err := w.Write([]byte(postCard)); err != nil {
+			return
+		}
 		w.(http.Flusher).Flush()
 
-		// Add a small delay for streaming effect
-		time.Sleep(50 * time.Millisecond)
+		// Add a small delay for streaming effect, giving up if the reader left
+		if !pause(r.Context(), 50*time.Millisecond) {
+			return
+		}
 	}
 
 	// Send the closing HTML
-	footerHTML := `
+	w.Write([]byte(homeFooterHTML))
+	w.(http.Flusher).Flush()
+}
+
+// homeFooterHTML closes the home page
+const homeFooterHTML = `
     </div>
     
     <div class="footer">
@@ -347,260 +867,1279 @@ func (app *App) homeHandler(w http.ResponseWriter, r *http.Request) {
 </body>
 </html>`
 
-	w.Write([]byte(footerHTML))
-	w.(http.Flusher).Flush()
-}
+const (
+	defaultHomePostCount = 12
+	maxHomePostCount     = 50
+)
 
-// getLatestModel returns the latest model, using cache if available
-func (app *App) getLatestModel() (*store.MarkovChainModel, error) {
-	// Return cached model if available
-	if app.cachedModel != nil {
-		return app.cachedModel, nil
+// featuredExcerptScale is how many times longer the featured story's
+// excerpt is than a grid card's
+const featuredExcerptScale = 3
+
+// homeJSONHandler returns the home page posts as JSON
+func (app *App) homeJSONHandler(w http.ResponseWriter, r *http.Request) {
+	// Clamp the requested count to a sane range
+	count, err := queryInt(r, "count", defaultHomePostCount, 1, maxHomePostCount)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	// Get the first available model from the database
-	models, err := app.store.GetAllMarkovChainModels(1)
+	// Get the latest model using cache
+	chain, err := app.loadLatestChain()
 	if err != nil {
-		log.Printf("Error retrieving models from database: %v", err)
-		return nil, err
+		routes.WriteJSONError(w, http.StatusServiceUnavailable, err.Error())
+		return
 	}
 
-	if len(models) == 0 {
-		log.Printf("No models found in database - this is likely the cause of 404 errors")
-		return nil, fmt.Errorf("no models found in database")
+	posts, err := app.homePosts(chain, count)
+	if err != nil {
+		routes.WriteJSONError(w, generationErrorStatus(err), "Failed to generate posts: "+err.Error())
+		return
+	}
+
+	response := make([]HomePost, len(posts))
+	for i, post := range posts {
+		response[i] = HomePost{
+			Title:   post.Link.Title,
+			Excerpt: app.excerpt(post.Content),
+			Author:  post.Author,
+			Date:    post.LastUpdated.Format("2006-01-02T15:04:05Z07:00"),
+			Url:     sitePath(post.Link.Url),
+		}
 	}
 
-	// Cache the first (most recent) model
-	app.cachedModel = &models[0]
-	log.Printf("Retrieved and cached model ID: %d", app.cachedModel.ID)
-	return app.cachedModel, nil
+	routes.WriteJSON(w, http.StatusOK, response)
 }
 
-// clearModelCache clears the cached model
-func (app *App) clearModelCache() {
-	app.cachedModel = nil
+// maxInlineModelBytes limits the size of models posted to /api/generate
+const maxInlineModelBytes = 10 << 20
+
+// generateInlineHandler generates a page from a model supplied in the request
+// body without storing it
+func (app *App) generateInlineHandler(w http.ResponseWriter, r *http.Request) {
+	var request GenerateRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxInlineModelBytes))
+	if err := decoder.Decode(&request); err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	if len(request.Model) == 0 {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Request must include a model")
+		return
+	}
+
+	// Default to a random seed when none is given
+	seed := rand.Int63()
+	if request.Seed != nil {
+		seed = *request.Seed
+	}
+	if seed < 0 {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Invalid seed: must be a non-negative integer")
+		return
+	}
+
+	chain, err := train.LoadModel(request.Model)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Invalid model: "+err.Error())
+		return
+	}
+
+	story, err := train.GeneratePage(seed, chain)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusUnprocessableEntity, "Failed to generate page: "+err.Error())
+		return
+	}
+
+	routes.WriteJSON(w, http.StatusOK, newPostResponse(story))
 }
 
-func (app *App) trainMarkovModelHandler(w http.ResponseWriter, r *http.Request) {
-	// Read the plain text body
-	body, err := io.ReadAll(r.Body)
+// previewHandler trains a throwaway model on the posted text and returns one
+// page from it as HTML, so a corpus can be tried out before it is saved.
+// Training options are read from the query string like /api/train.
+func (app *App) previewHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxInlineModelBytes))
 	if err != nil {
-		response := CreateMarkovModelRequest{
-			Success: false,
-			Error:   "Failed to read request body: " + err.Error(),
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(response)
+		http.Error(w, "Failed to read request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
 
-	// Check if body is empty
 	if len(body) == 0 {
-		response := CreateMarkovModelRequest{
-			Success: false,
-			Error:   "Request body cannot be empty",
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(response)
+		http.Error(w, "Request body cannot be empty", http.StatusBadRequest)
 		return
 	}
 
-	// Convert body to string for processing
-	inputText := string(body)
-
-	// Build the markov chain model
-	chain, err := train.BuildModel(inputText)
+	opts, err := parseTrainOptions(r)
 	if err != nil {
-		response := CreateMarkovModelRequest{
-			Success: false,
-			Error:   "Failed to build model: " + err.Error(),
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Default to a random seed when none is given
+	seed := rand.Int63()
+	if seedStr := r.URL.Query().Get("seed"); seedStr != "" {
+		seed, err = strconv.ParseInt(seedStr, 10, 64)
+		if err != nil || seed < 0 {
+			http.Error(w, "Invalid seed: must be a non-negative integer", http.StatusBadRequest)
+			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(response)
+	}
+
+	chain, err := train.BuildModelWithOptions(string(body), opts)
+	if err != nil {
+		http.Error(w, "Failed to build model: "+err.Error(), http.StatusUnprocessableEntity)
 		return
 	}
 
-	// Serialize the model to JSON
-	modelData, err := train.SerializeModel(chain)
+	story, err := train.GeneratePageFromPrefix(seed, chain, startPhrase(r))
 	if err != nil {
-		response := CreateMarkovModelRequest{
-			Success: false,
-			Error:   "Failed to serialize model: " + err.Error(),
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(response)
+		http.Error(w, "Failed to generate page: "+err.Error(), http.StatusUnprocessableEntity)
 		return
 	}
 
-	// Save the model to the database
-	model, err := app.store.SaveMarkovChainModel(modelData)
+	streamStory(w, r, story, app, instantPause)
+}
+
+// DebugTimingResponse compares the real cost of generating a page with the
+// delays added while streaming it. Durations are in milliseconds.
+type DebugTimingResponse struct {
+	Seed          int64   `json:"seed"`
+	ModelID       int     `json:"model_id"`
+	ModelLoadMs   float64 `json:"model_load_ms"`
+	GenerateMs    float64 `json:"generate_ms"`
+	Tokens        int     `json:"tokens"`
+	StreamDelayMs float64 `json:"stream_delay_ms"`
+}
+
+// debugTimingHandler times loading the current model and generating the page
+// for ?seed=, next to the streaming delay that page would get
+func (app *App) debugTimingHandler(w http.ResponseWriter, r *http.Request) {
+	seed, err := strconv.ParseInt(r.URL.Query().Get("seed"), 10, 64)
+	if err != nil || seed < 0 {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Invalid seed: must be a non-negative integer")
+		return
+	}
+
+	model, err := app.getLatestModel()
 	if err != nil {
-		response := CreateMarkovModelRequest{
-			Success: false,
-			Error:   "Failed to save model to database: " + err.Error(),
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(response)
+		routes.WriteJSONError(w, http.StatusServiceUnavailable, err.Error())
 		return
 	}
 
-	// Clear the cache since we have a new model
-	app.clearModelCache()
+	// Load the model again rather than using the cached chain, to time it
+	loadStart := time.Now()
+	chain, err := train.LoadModel([]byte(model.ModelData))
+	loadTime := time.Since(loadStart)
+	if err != nil {
+		routes.WriteJSONError(w, generationErrorStatus(err), err.Error())
+		return
+	}
 
-	// Return success response
-	response := CreateMarkovModelRequest{
-		Success: true,
-		Model:   model,
+	generateStart := time.Now()
+	story, err := train.GeneratePageFromPrefix(seed, chain, startPhrase(r))
+	generateTime := time.Since(generateStart)
+	if err != nil {
+		routes.WriteJSONError(w, generationErrorStatus(err), err.Error())
+		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(response)
+
+	tokens := len(strings.Fields(story.Link.Title)) + len(strings.Fields(story.Content))
+	for _, link := range story.Links {
+		tokens += len(strings.Fields(link.Title))
+	}
+
+	routes.WriteJSON(w, http.StatusOK, DebugTimingResponse{
+		Seed:          seed,
+		ModelID:       model.ID,
+		ModelLoadMs:   durationMs(loadTime),
+		GenerateMs:    durationMs(generateTime),
+		Tokens:        tokens,
+		StreamDelayMs: durationMs(streamDelay(story, app.typingCurve)),
+	})
 }
 
-func (app *App) updateMarkovModelHandler(w http.ResponseWriter, r *http.Request) {
-	// Get the model ID from the URL
-	vars := mux.Vars(r)
-	idStr := vars["id"]
+// durationMs converts d to fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
 
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		response := CreateMarkovModelRequest{
-			Success: false,
-			Error:   "Invalid model ID: " + err.Error(),
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(response)
+// DebugTokensResponse is the raw token walk of a single generated sentence
+type DebugTokensResponse struct {
+	Seed   int64    `json:"seed"`
+	Tokens []string `json:"tokens"`
+}
+
+// debugGenerateHandler returns the raw tokens of one GenerateStory call,
+// sentinels included, for diagnosing tokenization problems
+func (app *App) debugGenerateHandler(w http.ResponseWriter, r *http.Request) {
+	seed, err := strconv.ParseInt(r.URL.Query().Get("seed"), 10, 64)
+	if err != nil || seed < 0 {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Invalid seed: must be a non-negative integer")
 		return
 	}
 
-	// Read the plain text body
-	body, err := io.ReadAll(r.Body)
+	chain, err := app.loadLatestChain()
+	if err != nil {
+		routes.WriteJSONError(w, generationErrorStatus(err), err.Error())
+		return
+	}
+
+	tokens, err := train.GenerateRawTokens(seed, chain)
+	if err != nil {
+		routes.WriteJSONError(w, generationErrorStatus(err), "Failed to generate tokens: "+err.Error())
+		return
+	}
+
+	routes.WriteJSON(w, http.StatusOK, DebugTokensResponse{Seed: seed, Tokens: tokens})
+}
+
+const maxVariants = 20
+
+// variantsHandler generates n stories from the consecutive seeds seed, seed+1, ...
+func (app *App) variantsHandler(w http.ResponseWriter, r *http.Request) {
+	seed, err := strconv.ParseInt(r.URL.Query().Get("seed"), 10, 64)
+	if err != nil || seed < 0 {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Invalid seed: must be a non-negative integer")
+		return
+	}
+
+	n, err := queryInt(r, "n", 5, 1, maxVariants)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// The variants use the seeds from seed to seed+n-1, which must not overflow
+	if seed > math.MaxInt64-int64(n-1) {
+		routes.WriteJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid seed: must be at most %d for %d variants", int64(math.MaxInt64-(n-1)), n))
+		return
+	}
+
+	chain, err := app.loadLatestChain()
+	if err != nil {
+		routes.WriteJSONError(w, generationErrorStatus(err), err.Error())
+		return
+	}
+
+	variants := make([]PostResponse, n)
+	for i := 0; i < n; i++ {
+		story, err := train.GeneratePage(seed+int64(i), chain)
+		if err != nil {
+			routes.WriteJSONError(w, generationErrorStatus(err), "Failed to generate page: "+err.Error())
+			return
+		}
+		variants[i] = newPostResponse(story)
+	}
+
+	routes.WriteJSON(w, http.StatusOK, variants)
+}
+
+// modelFallbackDepth is how many of the newest models are tried when the latest can't be loaded
+const modelFallbackDepth = 5
+
+// errModelUnavailable wraps every failure to find a usable model
+var errModelUnavailable = errors.New("no usable model")
+
+// generationErrorStatus maps a model or generation error to an HTTP status.
+// A missing, empty or corrupt model is an outage until a good model is
+// trained; anything else is a server error.
+func generationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errModelUnavailable), errors.Is(err, train.ErrEmptyModel), errors.Is(err, train.ErrCorruptModel), errors.Is(err, train.ErrUnsupportedModelFormat):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// getLatestModel returns the newest model that loads successfully, using cache
+// if available. Corrupt models are logged and skipped in favour of older ones.
+func (app *App) getLatestModel() (*store.MarkovChainModel, error) {
+	model, _, err := app.latestModel()
+	return model, err
+}
+
+// loadLatestChain returns the markov chain of the latest loadable model
+func (app *App) loadLatestChain() (train.MarkovChain, error) {
+	_, chain, err := app.latestModel()
+	if err != nil {
+		log.Printf("Failed to retrieve model: %v", err)
+		return train.MarkovChain{}, fmt.Errorf("%w: %v", errModelUnavailable, err)
+	}
+	return chain, nil
+}
+
+// cachedLatestModel is a model and its chain as shared by modelLoads
+type cachedLatestModel struct {
+	model *store.MarkovChainModel
+	chain train.MarkovChain
+}
+
+// latestModel returns the cached model and its chain, loading the current
+// model first if needed. Concurrent callers on a cold cache wait for a single
+// load instead of each querying the database.
+func (app *App) latestModel() (*store.MarkovChainModel, train.MarkovChain, error) {
+	app.cacheMu.Lock()
+	// Return cached model if available
+	if app.cachedModel != nil {
+		defer app.cacheMu.Unlock()
+		return app.cachedModel, app.cachedChain, nil
+	}
+	gen := app.cacheGen
+	app.cacheMu.Unlock()
+
+	result, err, _ := app.modelLoads.Do("latest", func() (interface{}, error) {
+		model, chain, err := app.loadModel()
+		if err != nil {
+			return nil, err
+		}
+
+		app.cacheMu.Lock()
+		defer app.cacheMu.Unlock()
+		if app.cacheGen == gen {
+			app.cachedModel = model
+			app.cachedChain = chain
+			log.Printf("Retrieved and cached model ID: %d", model.ID)
+		}
+		return cachedLatestModel{model: model, chain: chain}, nil
+	})
+	if err != nil {
+		return nil, train.MarkovChain{}, err
+	}
+	loaded := result.(cachedLatestModel)
+	return loaded.model, loaded.chain, nil
+}
+
+// loadModel reads the current model from the database, falling back to the
+// most recent loadable one
+func (app *App) loadModel() (*store.MarkovChainModel, train.MarkovChain, error) {
+	// Prefer the current model, falling back to the most recent ones
+	current, err := app.store.GetCurrentMarkovChainModel()
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error retrieving current model from database: %v", err)
+		return nil, train.MarkovChain{}, err
+	}
+
+	recent, err := app.store.GetAllMarkovChainModels(modelFallbackDepth)
+	if err != nil {
+		log.Printf("Error retrieving models from database: %v", err)
+		return nil, train.MarkovChain{}, err
+	}
+
+	models := []store.MarkovChainModel{}
+	if current != nil {
+		models = append(models, *current)
+	}
+	for _, model := range recent {
+		if current == nil || model.ID != current.ID {
+			models = append(models, model)
+		}
+	}
+
+	if len(models) == 0 {
+		log.Printf("No models found in database - this is likely the cause of 404 errors")
+		return nil, train.MarkovChain{}, fmt.Errorf("no models found in database")
+	}
+
+	for i := range models {
+		chain, err := train.LoadModel([]byte(models[i].ModelData))
+		if err != nil {
+			log.Printf("Failed to load model %d, trying an older one: %v", models[i].ID, err)
+			continue
+		}
+
+		return &models[i], chain, nil
+	}
+
+	return nil, train.MarkovChain{}, fmt.Errorf("none of the current or %d newest models could be loaded", len(recent))
+}
+
+// clearModelCache clears the cached model
+func (app *App) clearModelCache() {
+	app.cacheMu.Lock()
+	defer app.cacheMu.Unlock()
+	app.cachedModel = nil
+	app.cachedChain = train.MarkovChain{}
+	app.trending = nil
+	app.cacheGen++
+	// Callers arriving after the clear start a fresh load
+	app.modelLoads.Forget("latest")
+}
+
+// pollModels clears the model cache whenever the current model in the database
+// changes, so models written by another process are picked up without a restart
+func (app *App) pollModels(interval time.Duration) {
+	lastID, err := app.store.GetCurrentMarkovChainModelID()
+	if err != nil {
+		log.Printf("Failed to poll for new models: %v", err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		lastID = app.checkCurrentModel(lastID)
+	}
+}
+
+// checkCurrentModel is one poll of pollModels. It clears the model cache when
+// the current model is no longer lastID, and returns the current model's ID.
+func (app *App) checkCurrentModel(lastID int) int {
+	id, err := app.store.GetCurrentMarkovChainModelID()
+	if err != nil {
+		log.Printf("Failed to poll for new models: %v", err)
+		return lastID
+	}
+	if id != lastID {
+		log.Printf("Current model changed from ID %d to %d, clearing cache", lastID, id)
+		app.clearModelCache()
+	}
+	return id
+}
+
+// MetricsResponse is the generation failure counts, with the distribution of
+// story lengths alongside
+type MetricsResponse struct {
+	train.GenerationFailures
+	StoryWords train.WordCountHistogram `json:"story_words"`
+	Models     int                      `json:"models"`
+	Posts      int                      `json:"posts"`
+}
+
+// metricsHandler reports how often generation has failed since startup, how
+// long the generated stories were and how many models and posts are stored
+func (app *App) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	models, err := app.store.CountMarkovChainModels()
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to count models: "+err.Error())
+		return
+	}
+	posts, err := app.store.CountPosts()
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to count posts: "+err.Error())
+		return
+	}
+
+	routes.WriteJSON(w, http.StatusOK, MetricsResponse{
+		GenerationFailures: train.GenerationFailureCounts(),
+		StoryWords:         train.StoryWordCounts(),
+		Models:             models,
+		Posts:              posts,
+	})
+}
+
+// clearCacheHandler drops the cached model so the next request reloads it from the database
+func (app *App) clearCacheHandler(w http.ResponseWriter, r *http.Request) {
+	app.clearModelCache()
+	log.Printf("Model cache cleared")
+
+	routes.WriteJSON(w, http.StatusOK, ClearCacheResponse{
+		Success: true,
+	})
+}
+
+func (app *App) trainMarkovModelHandler(w http.ResponseWriter, r *http.Request) {
+	// A retried request with the same Idempotency-Key gets the original model back.
+	// The key stays locked while training so a concurrent retry waits for the
+	// first, while requests with other keys go ahead.
+	key := r.Header.Get("Idempotency-Key")
+	if key != "" {
+		defer app.idempotencyLocks.lock(key)()
+
+		if entry, ok := app.idempotentEntry(key); ok {
+			w.Header().Set("Idempotent-Replayed", "true")
+			if entry.jobID != 0 {
+				// Replay the 202 with the job's current status
+				job, err := app.store.GetTrainingJob(entry.jobID)
+				if err != nil {
+					routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to retrieve job: "+err.Error())
+					return
+				}
+				writeQueuedJob(w, job)
+				return
+			}
+			routes.WriteJSON(w, http.StatusCreated, CreateMarkovModelRequest{
+				Success: true,
+				Model:   entry.model,
+			})
+			return
+		}
+	}
+
+	// Read the plain text body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Failed to read request body: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	// Check if body is empty
+	if len(body) == 0 {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Request body cannot be empty")
+		return
+	}
+
+	// Convert body to string for processing
+	inputText := string(body)
+
+	opts, err := parseTrainOptions(r)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Large corpora can be queued and trained in the background instead
+	if asyncStr := r.URL.Query().Get("async"); asyncStr != "" {
+		async, err := strconv.ParseBool(asyncStr)
+		if err != nil {
+			routes.WriteJSONError(w, http.StatusBadRequest, "Invalid async: "+err.Error())
+			return
+		}
+		if async {
+			job, err := app.enqueueTraining(inputText, opts)
+			if err != nil {
+				routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to queue training job: "+err.Error())
+				return
+			}
+			if key != "" {
+				app.rememberIdempotent(key, idempotentTrain{jobID: job.ID, createdAt: time.Now()})
+			}
+			writeQueuedJob(w, job)
+			return
+		}
+	}
+
+	model, err := app.trainModel(inputText, opts)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if key != "" {
+		app.rememberIdempotent(key, idempotentTrain{model: model, createdAt: time.Now()})
+	}
+
+	// Return success response
+	routes.WriteJSON(w, http.StatusCreated, CreateMarkovModelRequest{
+		Success: true,
+		Model:   model,
+	})
+}
+
+// trainModel builds a model from text, saves it and clears the model cache
+func (app *App) trainModel(text string, opts train.TrainOptions) (*store.MarkovChainModel, error) {
+	// Build the markov chain model
+	chain, err := train.BuildModelWithOptions(text, opts)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to build model: %w", err)
+	}
+
+	// Serialize the model to JSON
+	modelData, err := train.SerializeModel(chain)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to serialize model: %w", err)
+	}
+
+	// Save the model to the database
+	model, err := app.store.SaveMarkovChainModel(modelData, true)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to save model to database: %w", err)
+	}
+
+	// Clear the cache since we have a new model
+	app.clearModelCache()
+	app.notifyHub()
+	return model, nil
+}
+
+// bootstrap trains a model from the corpus at path when the database has no
+// models yet. Existing models are left alone, so restarts don't retrain.
+func (app *App) bootstrap(path string) error {
+	count, err := app.store.CountMarkovChainModels()
+	if err != nil {
+		return fmt.Errorf("Failed to count models: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	model, err := app.trainModel(string(text), train.TrainOptions{})
+	if err != nil {
+		return err
+	}
+	log.Printf("Bootstrapped model %d from %s", model.ID, path)
+	return nil
+}
+
+// validateCorpusHandler reports how a corpus would be tokenized by
+// POST /api/train with the same options, and what may be wrong with it,
+// without training a model
+func (app *App) validateCorpusHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Failed to read request body: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	if len(body) == 0 {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Request body cannot be empty")
+		return
+	}
+
+	opts, err := parseTrainOptions(r)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report := train.ValidateCorpus(string(body), opts)
+	if app.minVocabulary > 0 && report.Vocabulary < app.minVocabulary {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("vocabulary of %d is below MIN_VOCABULARY (%d), so the server would not be ready with this model", report.Vocabulary, app.minVocabulary))
+	}
+	routes.WriteJSON(w, http.StatusOK, report)
+}
+
+// BatchTrainItem is one corpus submitted to POST /api/train/batch
+type BatchTrainItem struct {
+	Name string `json:"name"`
+	Text string `json:"text"`
+}
+
+// ModelSummary identifies a stored model without its data
+type ModelSummary struct {
+	ID        int    `json:"id"`
+	CreatedAt string `json:"created_at"`
+}
+
+// BatchTrainResult reports the outcome of training one batch item
+type BatchTrainResult struct {
+	Name    string        `json:"name"`
+	Success bool          `json:"success"`
+	Model   *ModelSummary `json:"model,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// Limits for POST /api/train/batch
+const (
+	maxBatchItems = 50
+	maxBatchBytes = 50 << 20
+)
+
+// trainBatchHandler trains and saves a model for each corpus in the request.
+// Items fail independently, so the response may mix successes and errors.
+func (app *App) trainBatchHandler(w http.ResponseWriter, r *http.Request) {
+	var items []BatchTrainItem
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBytes))
+	if err := decoder.Decode(&items); err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	if len(items) == 0 || len(items) > maxBatchItems {
+		routes.WriteJSONError(w, http.StatusBadRequest, fmt.Sprintf("Request must contain between 1 and %d items", maxBatchItems))
+		return
+	}
+
+	opts, err := parseTrainOptions(r)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	results := make([]BatchTrainResult, len(items))
+	for i, item := range items {
+		results[i] = BatchTrainResult{Name: item.Name}
+		if strings.TrimSpace(item.Text) == "" {
+			results[i].Error = "text cannot be empty"
+			continue
+		}
+		model, err := app.trainModel(item.Text, opts)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Success = true
+		results[i].Model = &ModelSummary{ID: model.ID, CreatedAt: model.CreatedAt}
+	}
+
+	routes.WriteJSON(w, http.StatusOK, results)
+}
+
+// idempotentEntry returns what was already created for key, dropping expired
+// keys as it goes
+func (app *App) idempotentEntry(key string) (idempotentTrain, bool) {
+	app.idempotencyMu.Lock()
+	defer app.idempotencyMu.Unlock()
+	for k, entry := range app.idempotencyKeys {
+		if time.Since(entry.createdAt) > idempotencyKeyTTL {
+			delete(app.idempotencyKeys, k)
+		}
+	}
+	entry, ok := app.idempotencyKeys[key]
+	return entry, ok
+}
+
+// rememberIdempotent records what was created for key
+func (app *App) rememberIdempotent(key string, entry idempotentTrain) {
+	app.idempotencyMu.Lock()
+	defer app.idempotencyMu.Unlock()
+	if app.idempotencyKeys == nil {
+		app.idempotencyKeys = make(map[string]idempotentTrain)
+	}
+	app.idempotencyKeys[key] = entry
+}
+
+// parseTrainOptions reads tokenization options from the query string
+func parseTrainOptions(r *http.Request) (train.TrainOptions, error) {
+	opts := train.TrainOptions{}
+	flags := map[string]*bool{
+		// Fold tokens to lower case to shrink the vocabulary
+		"lowercase": &opts.Lowercase,
+		// Treat blank lines as paragraph breaks
+		"paragraphs": &opts.Paragraphs,
+		// Train a separate chain on opening sentences for post titles
+		"titles": &opts.Titles,
+		// Drop chapter headings, all-caps lines and page numbers
+		"strip_headings": &opts.StripHeadings,
+		// Prefer two-word context, backing off to one word when it is unseen
+		"backoff": &opts.Backoff,
+		// Map curly quotes, dashes and other unicode punctuation to ASCII
+		"normalize": &opts.Normalize,
+	}
+	for name, flag := range flags {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return train.TrainOptions{}, fmt.Errorf("Invalid %s: %w", name, err)
+		}
+		*flag = parsed
+	}
+	return opts, nil
+}
+
+func (app *App) updateMarkovModelHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the model ID from the URL
+	vars := mux.Vars(r)
+	id, err := parseModelID(vars["id"])
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Invalid model ID: "+err.Error())
+		return
+	}
+
+	// Read the plain text body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Failed to read request body: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	// Check if body is empty
+	if len(body) == 0 {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Request body cannot be empty")
+		return
+	}
+
+	// Convert body to string for processing
+	additionalText := string(body)
+
+	// Get the existing model from the database
+	existingModel, err := app.store.GetMarkovChainModel(id)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusNotFound, "Failed to retrieve model: "+err.Error())
+		return
+	}
+
+	// Load the existing model from JSON data
+	chain, err := train.LoadModel([]byte(existingModel.ModelData))
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to load existing model: "+err.Error())
+		return
+	}
+
+	// Add the additional text to the existing model
+	err = train.AddTextToModel(chain, additionalText)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to add text to model: "+err.Error())
+		return
+	}
+
+	// Serialize the updated model to JSON
+	modelData, err := train.SerializeModel(chain)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to serialize updated model: "+err.Error())
+		return
+	}
+
+	// Update the model in the database
+	updatedModel, err := app.store.UpdateMarkovChainModel(id, modelData)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to update model in database: "+err.Error())
+		return
+	}
+
+	// Clear the cache since the model was updated
+	app.clearModelCache()
+	app.notifyHub()
+
+	// Return success response
+	routes.WriteJSON(w, http.StatusOK, CreateMarkovModelRequest{
+		Success: true,
+		Model:   updatedModel,
+	})
+}
+
+// exportMarkovModelHandler downloads a stored model's JSON for backup
+func (app *App) exportMarkovModelHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the model ID from the URL
+	vars := mux.Vars(r)
+	id, err := parseModelID(vars["id"])
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Invalid model ID: "+err.Error())
+		return
+	}
+
+	model, err := app.store.GetMarkovChainModel(id)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusNotFound, "Failed to retrieve model: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=model-%d.json", model.ID))
+	serveText(w, r, model.ModelData)
+}
+
+// ModelSizeResponse is the size of a stored model's data
+type ModelSizeResponse struct {
+	ID        int   `json:"id"`
+	SizeBytes int64 `json:"size_bytes"`
+}
+
+// modelSizeHandler reports how large a stored model is without sending it
+func (app *App) modelSizeHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the model ID from the URL
+	vars := mux.Vars(r)
+	id, err := parseModelID(vars["id"])
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Invalid model ID: "+err.Error())
+		return
+	}
+
+	size, err := app.store.GetMarkovChainModelSize(id)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusNotFound, "Failed to retrieve model: "+err.Error())
+		return
+	}
+
+	routes.WriteJSON(w, http.StatusOK, ModelSizeResponse{ID: id, SizeBytes: size})
+}
+
+const (
+	defaultGraphLimit = 500
+	maxGraphLimit     = 10000
+)
+
+// graphMarkovModelHandler returns the most frequent transitions of a stored
+// model as nodes and weighted edges
+func (app *App) graphMarkovModelHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the model ID from the URL
+	vars := mux.Vars(r)
+	id, err := parseModelID(vars["id"])
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Invalid model ID: "+err.Error())
+		return
+	}
+
+	limit, err := queryInt(r, "limit", defaultGraphLimit, 1, maxGraphLimit)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	model, err := app.store.GetMarkovChainModel(id)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusNotFound, "Failed to retrieve model: "+err.Error())
+		return
+	}
+
+	chain, err := train.LoadModel([]byte(model.ModelData))
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to load model: "+err.Error())
+		return
+	}
+
+	graph, err := train.Graph(chain, limit)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to build graph: "+err.Error())
+		return
+	}
+
+	routes.WriteJSON(w, http.StatusOK, graph)
+}
+
+// importMarkovModelHandler saves a previously exported model
+func (app *App) importMarkovModelHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Failed to read request body: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	if len(body) == 0 {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Request body cannot be empty")
+		return
+	}
+
+	// Make sure the model is usable before storing it, with its delimiters
+	// recorded so it loads like a model trained here
+	chain, err := train.LoadImportedModel(body, app.importedModelSentinels(r))
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Invalid model: "+err.Error())
+		return
+	}
+	data, err := train.SerializeModel(chain)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to serialize model: "+err.Error())
+		return
+	}
+
+	model, err := app.store.SaveMarkovChainModel(data, true)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to save model to database: "+err.Error())
+		return
+	}
+
+	// Clear the cache since we have a new model
+	app.clearModelCache()
+
+	routes.WriteJSON(w, http.StatusCreated, CreateMarkovModelRequest{
+		Success: true,
+		Model:   model,
+	})
+}
+
+// importedModelSentinels returns the delimiters of a model imported by r: the
+// start_token and end_token query parameters, else the configured ones
+func (app *App) importedModelSentinels(r *http.Request) train.Sentinels {
+	sentinels := train.DefaultSentinels
+	if app.importSentinels.Start != "" {
+		sentinels.Start = app.importSentinels.Start
+	}
+	if app.importSentinels.End != "" {
+		sentinels.End = app.importSentinels.End
+	}
+	if start := r.URL.Query().Get("start_token"); start != "" {
+		sentinels.Start = start
+	}
+	if end := r.URL.Query().Get("end_token"); end != "" {
+		sentinels.End = end
+	}
+	return sentinels
+}
+
+// pruneMarkovModelHandler drops rare transitions from a stored model to shrink it
+func (app *App) pruneMarkovModelHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the model ID from the URL
+	vars := mux.Vars(r)
+	id, err := parseModelID(vars["id"])
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Invalid model ID: "+err.Error())
+		return
+	}
+
+	// Transitions seen fewer than min_count times are dropped
+	minCount, err := queryInt(r, "min_count", 2, 1, math.MaxInt)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	existingModel, err := app.store.GetMarkovChainModel(id)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusNotFound, "Failed to retrieve model: "+err.Error())
+		return
+	}
+
+	chain, err := train.LoadModel([]byte(existingModel.ModelData))
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to load existing model: "+err.Error())
+		return
+	}
+
+	pruned, err := train.PruneModel(chain, minCount)
+	if errors.Is(err, train.ErrUnsupportedOrder) {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Failed to prune model: "+err.Error())
+		return
+	}
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to prune model: "+err.Error())
+		return
+	}
+
+	modelData, err := train.SerializeModel(pruned)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to serialize pruned model: "+err.Error())
+		return
+	}
+
+	updatedModel, err := app.store.UpdateMarkovChainModel(id, modelData)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to update model in database: "+err.Error())
+		return
+	}
+	log.Printf("Pruned model %d with min_count %d: %d -> %d bytes", id, minCount, len(existingModel.ModelData), len(modelData))
+
+	// Clear the cache since the model was updated
+	app.clearModelCache()
+
+	routes.WriteJSON(w, http.StatusOK, CreateMarkovModelRequest{
+		Success: true,
+		Model:   updatedModel,
+	})
+}
+
+// modelOptionsHandler returns the generation options a stored model uses
+func (app *App) modelOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the model ID from the URL
+	vars := mux.Vars(r)
+	id, err := parseModelID(vars["id"])
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Invalid model ID: "+err.Error())
+		return
+	}
+
+	existingModel, err := app.store.GetMarkovChainModel(id)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusNotFound, "Failed to retrieve model: "+err.Error())
+		return
+	}
+
+	chain, err := train.LoadModel([]byte(existingModel.ModelData))
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to load existing model: "+err.Error())
+		return
+	}
+
+	routes.WriteJSON(w, http.StatusOK, chain.DefaultOptions())
+}
+
+// updateModelOptionsHandler saves generation options with a stored model. The
+// JSON body only needs the fields that change; the rest keep the values the
+// model uses now.
+func (app *App) updateModelOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the model ID from the URL
+	vars := mux.Vars(r)
+	id, err := parseModelID(vars["id"])
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Invalid model ID: "+err.Error())
+		return
+	}
+
+	existingModel, err := app.store.GetMarkovChainModel(id)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusNotFound, "Failed to retrieve model: "+err.Error())
+		return
+	}
+
+	chain, err := train.LoadModel([]byte(existingModel.ModelData))
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to load existing model: "+err.Error())
+		return
+	}
+
+	opts := chain.DefaultOptions()
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&opts); err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Invalid options: "+err.Error())
+		return
+	}
+	if opts.RepetitionPenalty < 0 || opts.RepetitionPenalty > 1 {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Invalid options: repetition_penalty must be between 0 and 1")
+		return
+	}
+
+	modelData, err := train.SerializeModel(chain.WithGenerateOptions(opts))
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to serialize updated model: "+err.Error())
+		return
+	}
+
+	updatedModel, err := app.store.UpdateMarkovChainModel(id, modelData)
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to update model in database: "+err.Error())
+		return
+	}
+
+	// Clear the cache since the model was updated
+	app.clearModelCache()
+
+	routes.WriteJSON(w, http.StatusOK, CreateMarkovModelRequest{
+		Success: true,
+		Model:   updatedModel,
+	})
+}
+
+func (app *App) generatePageStreamHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the {id} from the url
+	vars := mux.Vars(r)
+	seed, err := parsePostSeed(vars["id"])
+	if err != nil {
+		log.Printf("Invalid ID in URL %s: %v", r.URL.Path, err)
+		http.Error(w, "Invalid ID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Send slugless or outdated /post/{seed} links to the canonical
+	// /post/{seed}-{slug}
+	if app.redirectToCanonicalPost(w, r, vars["id"], seed, "") {
+		return
+	}
+
+	author, err := requestedAuthor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The representation depends on Accept, so caches must key on it too.
+	// Add rather than Set so other negotiated dimensions can be appended.
+	w.Header().Add("Vary", "Accept")
+
+	// HTML is streamed; the other representations are written in one go
+	contentType := negotiateContentType(r, postContentTypes)
+	if contentType != contentTypeHTML {
+		app.renderPost(w, r, seed, startPhrase(r), author, contentType)
+		return
+	}
+
+	streamPage(w, r, seed, author, app)
+}
+
+// maxStartLength caps the runes of the ?start= phrase a reader can steer a story with
+const maxStartLength = 200
+
+// startPhrase returns the ?start= phrase the story body should begin with
+func startPhrase(r *http.Request) string {
+	start := strings.TrimSpace(r.URL.Query().Get("start"))
+	if utf8.RuneCountInString(start) > maxStartLength {
+		start = string([]rune(start)[:maxStartLength])
+	}
+	return start
+}
+
+// requestedAuthor returns the author named by ?author= in their canonical
+// spelling, or "" when the post keeps its own author. Names that aren't in
+// the author list are an error.
+func requestedAuthor(r *http.Request) (string, error) {
+	name := strings.TrimSpace(r.URL.Query().Get("author"))
+	if name == "" {
+		return "", nil
+	}
+	author, ok := train.AuthorBySlug(train.AuthorSlug(name))
+	if !ok {
+		return "", fmt.Errorf("Unknown author: %s", name)
+	}
+	return author, nil
+}
+
+// postOptions returns the chain's options for a post whose body starts from
+// start and that is credited to author, when they aren't empty
+func postOptions(chain train.MarkovChain, start, author string) train.GenerateOptions {
+	opts := chain.DefaultOptions()
+	opts.Prefix = start
+	opts.Author = author
+	return opts
+}
+
+// parseModelID reads a model id from the URL. Ids start at 1, so anything
+// else is rejected before it reaches the database.
+func parseModelID(idStr string) (int, error) {
+	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		response := CreateMarkovModelRequest{
-			Success: false,
-			Error:   "Failed to read request body: " + err.Error(),
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(response)
-		return
+		return 0, err
 	}
-	defer r.Body.Close()
-
-	// Check if body is empty
-	if len(body) == 0 {
-		response := CreateMarkovModelRequest{
-			Success: false,
-			Error:   "Request body cannot be empty",
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(response)
-		return
+	if id < 1 {
+		return 0, fmt.Errorf("must be a positive integer")
 	}
+	return id, nil
+}
 
-	// Convert body to string for processing
-	additionalText := string(body)
+// parsePostSeed reads the seed from a post id like 123-this-is-a-post-title
+func parsePostSeed(id string) (int64, error) {
+	idStr := strings.SplitN(id, "-", 2)[0]
+	// it should support parsing int64
+	return strconv.ParseInt(idStr, 10, 64)
+}
 
-	// Get the existing model from the database
-	existingModel, err := app.store.GetMarkovChainModel(id)
-	if err != nil {
-		response := CreateMarkovModelRequest{
-			Success: false,
-			Error:   "Failed to retrieve model: " + err.Error(),
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(response)
-		return
+// rerollSeed returns the seed after seed, wrapping back to 0 so rerolled
+// seeds stay non-negative
+func rerollSeed(seed int64) int64 {
+	if seed == math.MaxInt64 {
+		return 0
 	}
+	return seed + 1
+}
 
-	// Load the existing model from JSON data
-	chain, err := train.LoadModel([]byte(existingModel.ModelData))
-	if err != nil {
-		response := CreateMarkovModelRequest{
-			Success: false,
-			Error:   "Failed to load existing model: " + err.Error(),
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(response)
+// rerollHandler redirects to the post after this one, for readers who want a
+// fresh story. The query string, such as ?start=, is kept.
+func (app *App) rerollHandler(w http.ResponseWriter, r *http.Request) {
+	seed, err := parsePostSeed(mux.Vars(r)["id"])
+	if err != nil || seed < 0 {
+		http.Error(w, "Invalid ID: must be a non-negative integer", http.StatusBadRequest)
 		return
 	}
 
-	// Add the additional text to the existing model
-	err = train.AddTextToModel(chain, additionalText)
-	if err != nil {
-		response := CreateMarkovModelRequest{
-			Success: false,
-			Error:   "Failed to add text to model: " + err.Error(),
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(response)
-		return
+	next := rerollSeed(seed)
+	target := sitePath(fmt.Sprintf("/post/%d", next))
+	if link, err := app.postLink(next); err == nil {
+		target = sitePath(link.Url)
 	}
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
 
-	// Serialize the updated model to JSON
-	modelData, err := train.SerializeModel(chain)
+// relatedJSONHandler returns only the related links of a post, for clients
+// that load more related stories without re-rendering the page
+func (app *App) relatedJSONHandler(w http.ResponseWriter, r *http.Request) {
+	seed, err := parsePostSeed(mux.Vars(r)["id"])
 	if err != nil {
-		response := CreateMarkovModelRequest{
-			Success: false,
-			Error:   "Failed to serialize updated model: " + err.Error(),
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(response)
+		routes.WriteJSONError(w, http.StatusBadRequest, "Invalid ID: "+err.Error())
 		return
 	}
 
-	// Update the model in the database
-	updatedModel, err := app.store.UpdateMarkovChainModel(id, modelData)
+	// The links follow the body, so they depend on ?start= and ?author= as
+	// they do on the post page
+	author, err := requestedAuthor(r)
 	if err != nil {
-		response := CreateMarkovModelRequest{
-			Success: false,
-			Error:   "Failed to update model in database: " + err.Error(),
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(response)
+		routes.WriteJSONError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Clear the cache since the model was updated
-	app.clearModelCache()
-
-	// Return success response
-	response := CreateMarkovModelRequest{
-		Success: true,
-		Model:   updatedModel,
-	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
-}
-
-func (app *App) generatePageStreamHandler(w http.ResponseWriter, r *http.Request) {
-	// Get the {id} from the url
-	vars := mux.Vars(r)
-	// example 123-this-is-a-post-title
-	idStr := strings.SplitN(vars["id"], "-", 2)[0]
-	// it should support parsing int64
-	seed, err := strconv.ParseInt(idStr, 10, 64)
+	story, err := app.generatePage(seed, startPhrase(r), author)
 	if err != nil {
-		log.Printf("Invalid ID in URL %s: %v", r.URL.Path, err)
-		http.Error(w, "Invalid ID: "+err.Error(), http.StatusBadRequest)
+		routes.WriteJSONError(w, generationErrorStatus(err), err.Error())
 		return
 	}
 
-	streamPage(w, r, seed, app)
+	routes.WriteJSON(w, http.StatusOK, RelatedResponse{
+		Seed:  seed,
+		Links: newPostLinks(story.Links),
+	})
 }
 
 // Helper function to truncate strings for meta descriptions
@@ -617,9 +2156,108 @@ func truncateString(s string, maxLen int) string {
 	return truncated + "..."
 }
 
-// Helper function to get the full URL for canonical and Open Graph tags
-func getFullURL(r *http.Request) string {
-	host := os.Getenv("PUBLIC_HOST")
+// truncateAtSentence cuts s back to the last complete sentence within maxLen,
+// falling back to truncateString when the first sentence is already too long
+func truncateAtSentence(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	end := 0
+	offset := 0
+	for _, word := range strings.Fields(s) {
+		start := offset + strings.Index(s[offset:], word)
+		offset = start + len(word)
+		if offset > maxLen {
+			break
+		}
+		if train.EndsSentence(word) {
+			end = offset
+		}
+	}
+	if end == 0 {
+		return truncateString(s, maxLen)
+	}
+	return s[:end]
+}
+
+// excerpt shortens post content for cards, ending on a sentence when
+// EXCERPT_SENTENCES is enabled
+func (app *App) excerpt(content string) string {
+	return app.excerptOfLength(content, app.excerptLength)
+}
+
+// excerptOfLength is excerpt with a maximum length other than EXCERPT_LENGTH
+func (app *App) excerptOfLength(content string, maxLen int) string {
+	if app.sentenceExcerpts {
+		return truncateAtSentence(content, maxLen)
+	}
+	return truncateString(content, maxLen)
+}
+
+// defaultThemeColor is the site's accent color
+const defaultThemeColor = "#007cba"
+
+// themeColor returns the accent color of the post for seed, which is the
+// site's unless THEME_COLORS is enabled
+func (app *App) themeColor(seed int64) string {
+	if app.seedThemes {
+		return train.ThemeColorForSeed(seed)
+	}
+	return defaultThemeColor
+}
+
+// metaDescriptionLength is the longest meta description search results show
+const metaDescriptionLength = 160
+
+// socialDescriptionLength is the longest description in Open Graph, Twitter
+// card and structured data
+const socialDescriptionLength = 200
+
+// metaDescription shortens post content for the meta description tag, ending
+// on a sentence unless META_DESCRIPTION_SENTENCES is disabled
+func (app *App) metaDescription(content string) string {
+	return app.descriptionOfLength(content, metaDescriptionLength)
+}
+
+// socialDescription is metaDescription for the longer descriptions of link
+// previews and structured data
+func (app *App) socialDescription(content string) string {
+	return app.descriptionOfLength(content, socialDescriptionLength)
+}
+
+// descriptionOfLength is metaDescription with a maximum length of maxLen
+func (app *App) descriptionOfLength(content string, maxLen int) string {
+	if app.sentenceDescriptions {
+		return truncateAtSentence(content, maxLen)
+	}
+	return truncateString(content, maxLen)
+}
+
+// basePath is the BASE_PATH setting, see config.Config.BasePath
+var basePath string
+
+// sitePath prefixes an internal path such as "/post/1" with basePath
+func sitePath(path string) string {
+	return basePath + path
+}
+
+// languageName is the content of the language meta tag
+func (app *App) languageName() string {
+	if app.siteLang == "en" {
+		return "English"
+	}
+	return app.siteLang
+}
+
+// fullURL returns the URL of the request for canonical and Open Graph tags
+func (app *App) fullURL(r *http.Request) string {
+	return app.baseURL(r) + strings.TrimPrefix(r.URL.Path, basePath)
+}
+
+// baseURL returns the scheme, host and BASE_PATH of the site, preferring
+// PUBLIC_HOST
+func (app *App) baseURL(r *http.Request) string {
+	host := app.publicHost
 	if host == "" {
 
 		scheme := "http"
@@ -628,42 +2266,52 @@ func getFullURL(r *http.Request) string {
 		}
 		host = scheme + "://" + r.Host
 	}
-
-	return host + r.URL.Path
+	return host + basePath
 }
 
-func streamPage(w http.ResponseWriter, r *http.Request, seedInput int64, app *App) {
-	// Set headers for streaming
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-
-	// Initialize random seed for jitter
-	prng := rand.New(rand.NewSource(time.Now().UnixNano()))
-
+func streamPage(w http.ResponseWriter, r *http.Request, seedInput int64, author string, app *App) {
 	// Get the latest model using cache
-	model, err := app.getLatestModel()
+	chain, err := app.loadLatestChain()
 	if err != nil {
-		http.Error(w, "Failed to retrieve model: "+err.Error(), http.StatusInternalServerError)
+		app.writeErrorPage(w, http.StatusServiceUnavailable, "Stories are temporarily unavailable. Please try again soon.")
 		return
 	}
 
-	// Load the model from JSON data
-	chain, err := train.LoadModel([]byte(model.ModelData))
+	// Generate story with the seed
+	story, err := train.GeneratePageWithOptions(seedInput, chain, postOptions(chain, startPhrase(r), author))
 	if err != nil {
-		http.Error(w, "Failed to load model: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Failed to generate page for seed %d: %v", seedInput, err)
+		app.writeErrorPage(w, generationErrorStatus(err), "Something went wrong while writing this story.")
 		return
 	}
+	train.ObserveStoryWords(story.Content)
 
-	// Generate story with the seed
-	story, err := train.GeneratePage(seedInput, chain)
-	if err != nil {
-		http.Error(w, "Failed to generate page: "+err.Error(), http.StatusInternalServerError)
-		return
+	streamStory(w, r, story, app, app.pauserFor(r))
+}
+
+// streamStory writes the HTML page of a generated story, sleeping with pause
+// between words and characters
+func streamStory(w http.ResponseWriter, r *http.Request, story train.GeneratedPage, app *App, pause func(ctx context.Context, d time.Duration) bool) {
+	// Set headers for streaming
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	// A streamed page is flushed after every word, and flushing a gzip stream
+	// that often can make the page larger than sending it uncompressed
+	if app.streaming {
+		routes.DisableCompression(w)
 	}
 
-	words := strings.Fields(story.Content)
-	wordDelay := 50 * time.Millisecond
+	// Bound the whole stream so a huge story can't hold the connection forever
+	ctx, cancel := context.WithTimeout(r.Context(), app.streamTimeout)
+	defer cancel()
+
+	// Initialize random seed for jitter
+	prng := app.newJitterPRNG()
+
+	seedInput := story.Link.Seed
+	themeColor := app.themeColor(seedInput)
+
+	wordDelay := streamWordDelay
 
 	linkWordDelay := wordDelay
 
@@ -675,31 +2323,51 @@ func streamPage(w http.ResponseWriter, r *http.Request, seedInput int64, app *Ap
 		return baseDelay + time.Duration(jitter)
 	}
 
+	// Structured data is marshaled rather than concatenated so generated text can't break out of the JSON
+	articleLD := ArticleLD{
+		Context:       "https://schema.org",
+		Type:          "Article",
+		Headline:      story.Link.Title,
+		Description:   app.socialDescription(story.Content),
+		Image:         app.fullURL(r) + "/og-image.jpg",
+		Author:        Person{Type: "Person", Name: story.Author, URL: app.baseURL(r) + "/author/" + train.AuthorSlug(story.Author)},
+		Publisher:     siteOrganization(app.fullURL(r)),
+		DatePublished: story.LastUpdated.Format("2006-01-02T15:04:05Z07:00"),
+		DateModified:  story.LastUpdated.Format("2006-01-02T15:04:05Z07:00"),
+		MainEntityOfPage: WebPage{
+			Type: "WebPage",
+			ID:   app.fullURL(r),
+		},
+		WordCount:      len(strings.Fields(story.Content)),
+		ArticleSection: "Fiction",
+		Keywords:       "story, fiction, narrative, creative writing, " + story.Author,
+	}
+
 	// Send the HTML header and styles first
 	headerHTML := `<!DOCTYPE html>
-<html lang="en">
+<html lang="` + html.EscapeString(app.siteLang) + `">
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <title>` + html.EscapeString(story.Link.Title) + `</title>
     
     <!-- SEO Meta Tags -->
-    <meta name="description" content="` + html.EscapeString(truncateString(story.Content, 160)) + `">
+    <meta name="description" content="` + html.EscapeString(app.metaDescription(story.Content)) + `">
     <meta name="keywords" content="story, fiction, narrative, creative writing, ` + html.EscapeString(story.Author) + `">
     <meta name="author" content="` + html.EscapeString(story.Author) + `">
     <meta name="robots" content="index, follow">
-    <meta name="language" content="English">
+    <meta name="language" content="` + html.EscapeString(app.languageName()) + `">
     <meta name="revisit-after" content="7 days">
     <meta name="distribution" content="global">
     <meta name="rating" content="general">
     
     <!-- Open Graph / Facebook -->
     <meta property="og:type" content="article">
-    <meta property="og:url" content="` + html.EscapeString(getFullURL(r)) + `">
+    <meta property="og:url" content="` + html.EscapeString(app.fullURL(r)) + `">
     <meta property="og:title" content="` + html.EscapeString(story.Link.Title) + `">
-    <meta property="og:description" content="` + html.EscapeString(truncateString(story.Content, 200)) + `">
+    <meta property="og:description" content="` + html.EscapeString(app.socialDescription(story.Content)) + `">
     <meta property="og:site_name" content="Endless Stories">
-    <meta property="og:locale" content="en_US">
+    <meta property="og:locale" content="` + html.EscapeString(app.siteLocale) + `">
     <meta property="article:author" content="` + html.EscapeString(story.Author) + `">
     <meta property="article:published_time" content="` + story.LastUpdated.Format("2006-01-02T15:04:05Z07:00") + `">
     <meta property="article:modified_time" content="` + story.LastUpdated.Format("2006-01-02T15:04:05Z07:00") + `">
@@ -707,58 +2375,35 @@ func streamPage(w http.ResponseWriter, r *http.Request, seedInput int64, app *Ap
     <!-- Twitter -->
     <meta name="twitter:card" content="summary_large_image">
     <meta name="twitter:title" content="` + html.EscapeString(story.Link.Title) + `">
-    <meta name="twitter:description" content="` + html.EscapeString(truncateString(story.Content, 200)) + `">
+    <meta name="twitter:description" content="` + html.EscapeString(app.socialDescription(story.Content)) + `">
     <meta name="twitter:site" content="@endlessstories">
     <meta name="twitter:creator" content="` + html.EscapeString(story.Author) + `">
     
     <!-- Canonical URL -->
-    <link rel="canonical" href="` + html.EscapeString(getFullURL(r)) + `">
+    <link rel="canonical" href="` + html.EscapeString(app.fullURL(r)) + `">
+    <link rel="amphtml" href="` + html.EscapeString(app.baseURL(r)+story.Link.Url+"/amp") + `">
     
     <!-- Favicon -->
-    <link rel="icon" type="image/x-icon" href="/favicon.ico">
-    <link rel="apple-touch-icon" sizes="180x180" href="/apple-touch-icon.png">
+    <link rel="icon" type="image/x-icon" href="` + html.EscapeString(sitePath("/favicon.ico")) + `">
+    <link rel="apple-touch-icon" sizes="180x180" href="` + html.EscapeString(sitePath("/apple-touch-icon.png")) + `">
     
     <!-- Structured Data (JSON-LD) -->
     <script type="application/ld+json">
-    {
-        "@context": "https://schema.org",
-        "@type": "Article",
-        "headline": "` + html.EscapeString(story.Link.Title) + `",
-        "description": "` + html.EscapeString(truncateString(story.Content, 200)) + `",
-        "image": "` + html.EscapeString(getFullURL(r)) + `/og-image.jpg",
-        "author": {
-            "@type": "Person",
-            "name": "` + html.EscapeString(story.Author) + `"
-        },
-        "publisher": {
-            "@type": "Organization",
-            "name": "Endless Stories",
-            "logo": {
-                "@type": "ImageObject",
-                "url": "` + html.EscapeString(getFullURL(r)) + `/logo.png"
-            }
-        },
-        "datePublished": "` + story.LastUpdated.Format("2006-01-02T15:04:05Z07:00") + `",
-        "dateModified": "` + story.LastUpdated.Format("2006-01-02T15:04:05Z07:00") + `",
-        "mainEntityOfPage": {
-            "@type": "WebPage",
-            "@id": "` + html.EscapeString(getFullURL(r)) + `"
-        },
-        "wordCount": ` + strconv.Itoa(len(strings.Fields(story.Content))) + `,
-        "articleSection": "Fiction",
-        "keywords": "story, fiction, narrative, creative writing, ` + html.EscapeString(story.Author) + `"
-    }
+    ` + jsonLDScript(articleLD) + `
     </script>
-	` + statsHtml + `
+	` + app.analyticsHtml + `
     
     <!-- Additional SEO Meta Tags -->
-    <meta name="theme-color" content="#007cba">
-    <meta name="msapplication-TileColor" content="#007cba">
+    <meta name="theme-color" content="` + themeColor + `">
+    <meta name="msapplication-TileColor" content="` + themeColor + `">
     <meta name="apple-mobile-web-app-capable" content="yes">
     <meta name="apple-mobile-web-app-status-bar-style" content="default">
     <meta name="apple-mobile-web-app-title" content="Endless Stories">
     
     <style>
+        :root {
+            --accent: ` + themeColor + `;
+        }
         body {
             font-family: Arial, sans-serif;
             max-width: 800px;
@@ -770,7 +2415,7 @@ func streamPage(w http.ResponseWriter, r *http.Request, seedInput int64, app *Ap
             background-color: #f9f9f9;
             padding: 20px;
             border-radius: 8px;
-            border-left: 4px solid #007cba;
+            border-left: 4px solid var(--accent);
             margin: 20px 0;
         }
         .title {
@@ -778,7 +2423,7 @@ func streamPage(w http.ResponseWriter, r *http.Request, seedInput int64, app *Ap
             font-size: 2em;
             text-align: center;
             margin-bottom: 10px;
-            border-bottom: 2px solid #007cba;
+            border-bottom: 2px solid var(--accent);
             padding-bottom: 10px;
         }
         .last-updated {
@@ -790,11 +2435,15 @@ func streamPage(w http.ResponseWriter, r *http.Request, seedInput int64, app *Ap
         }
         .author {
             text-align: center;
-            color: #007cba;
+            color: var(--accent);
             font-size: 1em;
             font-weight: bold;
             margin-bottom: 20px;
         }
+        .author a {
+            color: inherit;
+            text-decoration: none;
+        }
         .content {
             font-size: 16px;
             color: #333;
@@ -818,17 +2467,17 @@ func streamPage(w http.ResponseWriter, r *http.Request, seedInput int64, app *Ap
             margin: 10px 0;
         }
         .links-list a {
-            color: #007cba;
+            color: var(--accent);
             text-decoration: none;
             font-size: 16px;
             padding: 8px 12px;
-            border: 1px solid #007cba;
+            border: 1px solid var(--accent);
             border-radius: 4px;
             display: inline-block;
             transition: background-color 0.3s, color 0.3s;
         }
         .links-list a:hover {
-            background-color: #007cba;
+            background-color: var(--accent);
             color: white;
         }
         
@@ -839,7 +2488,7 @@ func streamPage(w http.ResponseWriter, r *http.Request, seedInput int64, app *Ap
             color: #666;
         }
         .breadcrumb a {
-            color: #007cba;
+            color: var(--accent);
             text-decoration: none;
         }
         .breadcrumb a:hover {
@@ -859,7 +2508,7 @@ func streamPage(w http.ResponseWriter, r *http.Request, seedInput int64, app *Ap
 <body>
     <!-- Breadcrumb navigation for SEO -->
     <nav class="breadcrumb" aria-label="Breadcrumb">
-        <a href="/">Home</a> &gt; 
+        <a href="` + html.EscapeString(sitePath("/")) + `">Home</a> &gt; 
         <span aria-current="page">` + html.EscapeString(story.Link.Title) + `</span>
     </nav>
     
@@ -869,33 +2518,70 @@ func streamPage(w http.ResponseWriter, r *http.Request, seedInput int64, app *Ap
 	w.Write([]byte(headerHTML))
 	w.(http.Flusher).Flush()
 
+	// fits reports whether chunk can follow within maxResponseBytes, closing
+	// the page early when it can't
+	fits := func(chunk string) bool {
+		if app.fitsResponse(w, len(chunk), len(streamCloseHTML)) {
+			return true
+		}
+		abortStream(w, seedInput)
+		return false
+	}
+
 	// Stream the title character by character with jitter
 	for _, char := range story.Link.Title {
-		w.Write([]byte(html.EscapeString(string(char))))
+		chunk := html.EscapeString(string(char))
+		if !fits(chunk) {
+			return
+		}
+		w.Write([]byte(chunk))
 		w.(http.Flusher).Flush()
-		time.Sleep(addJitter(wordDelay / 3)) // Faster for individual characters
+		if !pause(ctx, addJitter(wordDelay/3)) { // Faster for individual characters
+			abortStream(w, seedInput)
+			return
+		}
 	}
 
 	// Send the title closing and metadata
 	metadataHTML := `</h1>
         <div class="last-updated" itemprop="dateModified" content="` + story.LastUpdated.Format("2006-01-02T15:04:05Z07:00") + `">Last updated: ` + story.LastUpdated.Format("January 2, 2006 at 3:04 PM") + `</div>
         <div class="author" itemprop="author" itemscope itemtype="https://schema.org/Person">
-            <span itemprop="name">` + html.EscapeString(story.Author) + `</span>
+            <a href="` + html.EscapeString(sitePath("/author/"+train.AuthorSlug(story.Author))) + `" itemprop="url"><span itemprop="name">` + html.EscapeString(story.Author) + `</span></a>
         </div>
         <div class="content" itemprop="articleBody">`
 
+	if !fits(metadataHTML) {
+		return
+	}
 	w.Write([]byte(metadataHTML))
 	w.(http.Flusher).Flush()
 
-	// Split content into words and stream them
-	for i, word := range words {
-		// Add space before word (except for first word)
-		if i > 0 {
-			w.Write([]byte(" "))
+	// Stream each paragraph word by word
+	for _, paragraph := range story.Paragraphs {
+		if !fits("<p>") {
+			return
 		}
-		w.Write([]byte(html.EscapeString(word)))
-		w.(http.Flusher).Flush()
-		time.Sleep(addJitter(wordDelay))
+		w.Write([]byte("<p>"))
+		for i, word := range strings.Fields(paragraph) {
+			chunk := html.EscapeString(word)
+			// Add space before word (except for first word)
+			if i > 0 {
+				chunk = " " + chunk
+			}
+			if !fits(chunk) {
+				return
+			}
+			w.Write([]byte(chunk))
+			w.(http.Flusher).Flush()
+			if !pause(ctx, addJitter(wordPace(word, wordDelay, app.typingCurve))) {
+				abortStream(w, seedInput)
+				return
+			}
+		}
+		if !fits("</p>") {
+			return
+		}
+		w.Write([]byte("</p>"))
 	}
 
 	// Send the content closing and links section opening
@@ -904,24 +2590,41 @@ func streamPage(w http.ResponseWriter, r *http.Request, seedInput int64, app *Ap
             <h2 class="links-title">Related Stories</h2>
             <ul class="links-list" role="list">`
 
+	if !fits(linksStart) {
+		return
+	}
 	w.Write([]byte(linksStart))
 	w.(http.Flusher).Flush()
 
 	// Stream links one by one with word-by-word streaming
 	for _, link := range story.Links {
 		// Start the list item and link opening
-		w.Write([]byte(`
-                <li role="listitem"><a href="` + html.EscapeString(link.Url) + `">`))
+		linkStart := `
+                <li role="listitem"><a href="` + html.EscapeString(sitePath(link.Url)) + `">`
+		if !fits(linkStart) {
+			return
+		}
+		w.Write([]byte(linkStart))
 		w.(http.Flusher).Flush()
 
 		// Stream the link title character by character
 		for _, char := range link.Title {
-			w.Write([]byte(html.EscapeString(string(char))))
+			chunk := html.EscapeString(string(char))
+			if !fits(chunk) {
+				return
+			}
+			w.Write([]byte(chunk))
 			w.(http.Flusher).Flush()
-			time.Sleep(addJitter(linkWordDelay / 3)) // Faster for individual characters
+			if !pause(ctx, addJitter(linkWordDelay/3)) { // Faster for individual characters
+				abortStream(w, seedInput)
+				return
+			}
 		}
 
 		// Close the link and list item
+		if !fits(`</a></li>`) {
+			return
+		}
 		w.Write([]byte(`</a></li>`))
 		w.(http.Flusher).Flush()
 	}
@@ -934,28 +2637,212 @@ func streamPage(w http.ResponseWriter, r *http.Request, seedInput int64, app *Ap
 </body>
 </html>`
 
+	// The footer closes the page itself, so it needs no room reserved
+	if !app.fitsResponse(w, len(footerHTML), 0) {
+		abortStream(w, seedInput)
+		return
+	}
 	w.Write([]byte(footerHTML))
 	w.(http.Flusher).Flush()
 }
 
+// averageWordLength is the word length that gets exactly the base delay on the typing curve
+const averageWordLength = 5
+
+// streamWordDelay is the base pause after each streamed word; characters of
+// titles are streamed three times as fast
+const streamWordDelay = 50 * time.Millisecond
+
+// streamDelay returns the total pause streamStory adds to story before
+// jitter, which averages out to zero
+func streamDelay(story train.GeneratedPage, typingCurve bool) time.Duration {
+	delay := time.Duration(utf8.RuneCountInString(story.Link.Title)) * (streamWordDelay / 3)
+	for _, paragraph := range story.Paragraphs {
+		for _, word := range strings.Fields(paragraph) {
+			delay += wordPace(word, streamWordDelay, typingCurve)
+		}
+	}
+	for _, link := range story.Links {
+		delay += time.Duration(utf8.RuneCountInString(link.Title)) * (streamWordDelay / 3)
+	}
+	return delay
+}
+
+// wordPace returns how long to wait after streaming word. With the typing
+// curve the delay scales with word length and pauses after punctuation.
+func wordPace(word string, base time.Duration, curve bool) time.Duration {
+	if !curve {
+		return base
+	}
+	delay := base * time.Duration(utf8.RuneCountInString(word)) / averageWordLength
+	if delay < base/2 {
+		delay = base / 2
+	}
+	switch {
+	case train.EndsSentence(word):
+		delay += base * 3
+	case strings.HasSuffix(word, ",") || strings.HasSuffix(word, ";") || strings.HasSuffix(word, ":"):
+		delay += base
+	}
+	return delay
+}
+
+// notFoundHandler serves the error page for unknown routes
+func (app *App) notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	app.writeErrorPage(w, http.StatusNotFound, "That page doesn't exist, but there are plenty of other stories.")
+}
+
+// writeErrorPage renders a small, reader-friendly error page
+func (app *App) writeErrorPage(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(status)
+	w.Write([]byte(`<!DOCTYPE html>
+<html lang="` + html.EscapeString(app.siteLang) + `">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="robots" content="noindex">
+    <title>Endless Stories</title>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            max-width: 800px;
+            margin: 0 auto;
+            padding: 20px;
+            line-height: 1.6;
+            text-align: center;
+            color: #333;
+        }
+        a {
+            color: #007cba;
+        }
+    </style>
+</head>
+<body>
+    <h1>Endless Stories</h1>
+    <p>` + html.EscapeString(message) + `</p>
+    <p><a href="` + html.EscapeString(sitePath("/")) + `">Back to the home page</a></p>
+</body>
+</html>`))
+}
+
+// abortStream closes the document when a stream is cut short. Open elements
+// inside the article are closed implicitly by the browser.
+func abortStream(w http.ResponseWriter, seed int64) {
+	log.Printf("Stream for seed %d stopped early", seed)
+	w.Write([]byte(streamCloseHTML))
+	w.(http.Flusher).Flush()
+}
+
+// streamCloseHTML closes a post page that stopped streaming early
+const streamCloseHTML = `
+    </article>
+</body>
+</html>`
+
+// fitsResponse reports whether n more bytes, plus reserve bytes to close the
+// document, keep the response within maxResponseBytes
+func (app *App) fitsResponse(w http.ResponseWriter, n, reserve int) bool {
+	if app.maxResponseBytes <= 0 {
+		return true
+	}
+	written, ok := routes.BytesWritten(w)
+	if !ok {
+		return true
+	}
+	return written+int64(n+reserve) <= app.maxResponseBytes
+}
+
+// HealthResponse identifies the running build
+type HealthResponse struct {
+	Status        string `json:"status"`
+	Version       string `json:"version"`
+	Commit        string `json:"commit"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+}
+
 func (app *App) healthHandler(w http.ResponseWriter, r *http.Request) {
+	routes.WriteJSON(w, http.StatusOK, HealthResponse{
+		Status:        "ok",
+		Version:       version.Version,
+		Commit:        version.Commit,
+		UptimeSeconds: int64(version.Uptime().Seconds()),
+	})
+}
+
+// readyHandler reports whether the latest model can actually generate a story
+func (app *App) readyHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.checkReady(); err != nil {
+		http.Error(w, "Not ready: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
 }
 
+// checkReady loads the latest model and generates a single story from it,
+// reusing the previous result for readyCacheTTL
+func (app *App) checkReady() error {
+	app.readyMu.Lock()
+	defer app.readyMu.Unlock()
+
+	if !app.readyCheckedAt.IsZero() && time.Since(app.readyCheckedAt) < readyCacheTTL {
+		return app.readyErr
+	}
+
+	app.readyErr = app.generateProbe()
+	app.readyCheckedAt = time.Now()
+	if app.readyErr != nil {
+		log.Printf("Readiness check failed: %v", app.readyErr)
+	}
+	return app.readyErr
+}
+
+func (app *App) generateProbe() error {
+	model, err := app.getLatestModel()
+	if err != nil {
+		return err
+	}
+
+	chain, err := train.LoadModel([]byte(model.ModelData))
+	if err != nil {
+		return fmt.Errorf("failed to load model %d: %w", model.ID, err)
+	}
+
+	if app.minVocabulary > 0 {
+		stats, err := train.Stats(chain)
+		if err != nil {
+			return fmt.Errorf("failed to inspect model %d: %w", model.ID, err)
+		}
+		if stats.Vocabulary < app.minVocabulary {
+			return fmt.Errorf("model %d knows %d words, fewer than the required %d", model.ID, stats.Vocabulary, app.minVocabulary)
+		}
+	}
+
+	story, err := train.GenerateStoryBasic(chain)
+	if err != nil {
+		return fmt.Errorf("model %d failed to generate: %w", model.ID, err)
+	}
+	if strings.TrimSpace(story) == "" {
+		return fmt.Errorf("model %d generated empty output", model.ID)
+	}
+	return nil
+}
+
 func (app *App) sitemapHandler(w http.ResponseWriter, r *http.Request) {
 	// Get the base URL
 	scheme := "http"
 	if r.TLS != nil {
 		scheme = "https"
 	}
-	baseURL := scheme + "://" + r.Host
+	baseURL := html.EscapeString(scheme + "://" + r.Host + basePath)
 
 	// Set content type for XML
 	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
 
 	// Get the latest model to generate some example posts for sitemap
-	model, err := app.getLatestModel()
+	model, chain, err := app.latestModel()
 	if err != nil {
 		// If no model available, just return homepage
 		sitemapXML := `<?xml version="1.0" encoding="UTF-8"?>
@@ -971,25 +2858,19 @@ func (app *App) sitemapHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Load the model and generate some example posts
-	chain, err := train.LoadModel([]byte(model.ModelData))
-	if err != nil {
-		// If model loading fails, just return homepage
-		sitemapXML := `<?xml version="1.0" encoding="UTF-8"?>
-<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
-    <url>
-        <loc>` + baseURL + `/</loc>
-        <lastmod>` + time.Now().Format("2006-01-02") + `</lastmod>
-        <changefreq>daily</changefreq>
-        <priority>1.0</priority>
-    </url>
-</urlset>`
-		w.Write([]byte(sitemapXML))
-		return
+	// The sitemap's posts only change with the model, so it was last
+	// modified when the current model was created
+	createdAt, ok := modelCreatedAt(model)
+	if ok {
+		w.Header().Set("Last-Modified", createdAt.Format(http.TimeFormat))
+		if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !createdAt.Truncate(time.Second).After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
 	}
 
 	// Generate 20 example posts for sitemap
-	posts, err := train.GenerateHomePagePosts(chain, 20)
+	posts, err := train.GenerateSitemapPosts(chain, 20)
 	if err != nil {
 		// If post generation fails, just return homepage
 		sitemapXML := `<?xml version="1.0" encoding="UTF-8"?>
@@ -1005,49 +2886,87 @@ func (app *App) sitemapHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Every lastmod is the model's creation date, so the document matches
+	// its Last-Modified header. The home page's daily changes are left to
+	// its changefreq.
+	modelDate := time.Now().Format("2006-01-02")
+	if ok {
+		modelDate = createdAt.Format("2006-01-02")
+	}
+
 	// Generate sitemap XML with homepage and posts
 	sitemapXML := `<?xml version="1.0" encoding="UTF-8"?>
 <urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
     <url>
         <loc>` + baseURL + `/</loc>
-        <lastmod>` + time.Now().Format("2006-01-02") + `</lastmod>
+        <lastmod>` + html.EscapeString(modelDate) + `</lastmod>
         <changefreq>daily</changefreq>
         <priority>1.0</priority>
     </url>`
 
-	// Add post URLs
+	// Add post URLs. A post only changes when the model does.
 	for _, post := range posts {
 		sitemapXML += `
     <url>
         <loc>` + baseURL + html.EscapeString(post.Link.Url) + `</loc>
-        <lastmod>` + post.LastUpdated.Format("2006-01-02") + `</lastmod>
+        <lastmod>` + html.EscapeString(modelDate) + `</lastmod>
         <changefreq>monthly</changefreq>
         <priority>0.8</priority>
     </url>`
 	}
 
+	// The trending page changes with the model, like the posts
+	sitemapXML += `
+    <url>
+        <loc>` + baseURL + `/trending</loc>
+        <lastmod>` + html.EscapeString(modelDate) + `</lastmod>
+        <changefreq>monthly</changefreq>
+        <priority>0.7</priority>
+    </url>`
+
+	// Add author profile URLs
+	for _, author := range train.Authors() {
+		sitemapXML += `
+    <url>
+        <loc>` + baseURL + `/author/` + html.EscapeString(train.AuthorSlug(author)) + `</loc>
+        <changefreq>weekly</changefreq>
+        <priority>0.5</priority>
+    </url>`
+	}
+
 	sitemapXML += `
 </urlset>`
 
 	w.Write([]byte(sitemapXML))
 }
 
+// modelCreatedAt parses the time model was saved, reporting false when the
+// database gave a time in an unknown format
+func modelCreatedAt(model *store.MarkovChainModel) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339, time.DateTime} {
+		if t, err := time.Parse(layout, model.CreatedAt); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
 func (app *App) robotsHandler(w http.ResponseWriter, r *http.Request) {
 	// Get the base URL
 	scheme := "http"
 	if r.TLS != nil {
 		scheme = "https"
 	}
-	baseURL := scheme + "://" + r.Host
+	baseURL := scheme + "://" + r.Host + basePath
 
 	// Set content type for text
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 
 	// Generate robots.txt content
 	robotsTxt := `User-agent: *
-Allow: /
-Disallow: /api/
-Disallow: /health
+Allow: ` + sitePath("/") + `
+Disallow: ` + sitePath("/api/") + `
+Disallow: ` + sitePath("/health") + `
 
 User-agent: AI2Bot
 User-agent: Ai2Bot-Dolma
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/abigpotostew/endless/store"
+	"github.com/abigpotostew/endless/train"
+	"github.com/gorilla/mux"
+)
+
+func TestOnlyServedPostsCountStoryWords(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	link, err := app.postLink(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := link.Url[len("/post/"):]
+
+	serve := func(handler http.HandlerFunc, accept string) int64 {
+		t.Helper()
+		before := train.StoryWordCounts().Count
+		req := httptest.NewRequest("GET", link.Url, nil)
+		req.Header.Set("Accept", accept)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+		}
+		return train.StoryWordCounts().Count - before
+	}
+
+	if n := serve(app.generatePageStreamHandler, "text/plain"); n != 1 {
+		t.Errorf("serving a post recorded %d stories, want 1", n)
+	}
+	if n := serve(app.relatedJSONHandler, "application/json"); n != 0 {
+		t.Errorf("serving related links recorded %d stories, want none", n)
+	}
+}
+
+func TestMetricsCountsStoredModelsAndPosts(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	if _, err := app.store.SavePost(store.Post{Seed: 1, Title: "A story", Content: "It was written."}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	app.metricsHandler(rec, httptest.NewRequest("GET", "/api/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	var metrics MetricsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &metrics); err != nil {
+		t.Fatal(err)
+	}
+	if metrics.Models != 1 || metrics.Posts != 1 {
+		t.Errorf("got %d models and %d posts, want 1 of each", metrics.Models, metrics.Posts)
+	}
+}
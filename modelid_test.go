@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/abigpotostew/endless/store"
+	"github.com/gorilla/mux"
+)
+
+// unreachableStore fails the test on any store call; its embedded PostStore
+// is nil, so every method panics
+type unreachableStore struct {
+	store.PostStore
+}
+
+func TestNonPositiveModelIDs(t *testing.T) {
+	app := &App{store: unreachableStore{}}
+	handlers := []struct {
+		method  string
+		path    string
+		handler http.HandlerFunc
+	}{
+		{"PUT", "/api/train/%s", app.updateMarkovModelHandler},
+		{"GET", "/api/train/%s/export", app.exportMarkovModelHandler},
+		{"GET", "/api/train/%s/size", app.modelSizeHandler},
+		{"GET", "/api/train/%s/graph", app.graphMarkovModelHandler},
+		{"POST", "/api/train/%s/prune", app.pruneMarkovModelHandler},
+		{"GET", "/api/train/%s/options", app.modelOptionsHandler},
+		{"PUT", "/api/train/%s/options", app.updateModelOptionsHandler},
+		{"GET", "/api/train/jobs/%s", app.trainingJobHandler},
+	}
+	for _, h := range handlers {
+		for _, id := range []string{"0", "-5"} {
+			target := strings.Replace(h.path, "%s", id, 1)
+			t.Run(h.method+" "+target, func(t *testing.T) {
+				defer func() {
+					if err := recover(); err != nil {
+						t.Fatalf("the store was called: %v", err)
+					}
+				}()
+				req := httptest.NewRequest(h.method, target, strings.NewReader("The cat sat."))
+				req = mux.SetURLVars(req, map[string]string{"id": id})
+				rec := httptest.NewRecorder()
+				h.handler(rec, req)
+				if rec.Code != http.StatusBadRequest {
+					t.Errorf("got status %d, want 400", rec.Code)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestModelSize(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	router := mux.NewRouter()
+	app.registerRoutes(router)
+	model, err := app.getLatestModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "http://localhost/api/train/"+strconv.Itoa(model.ID)+"/size", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	var got ModelSizeResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := (ModelSizeResponse{ID: model.ID, SizeBytes: int64(len(model.ModelData))}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"http://localhost/api/train/1000/size", http.StatusNotFound},
+		{"http://localhost/api/train/x/size", http.StatusBadRequest},
+		{"http://example.com/api/train/" + strconv.Itoa(model.ID) + "/size", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", tt.target, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s got status %d, want %d", tt.target, rec.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/abigpotostew/endless/routes"
+	"github.com/abigpotostew/endless/train"
+)
+
+// Content types a post can be rendered as
+const (
+	contentTypeHTML     = "text/html"
+	contentTypeJSON     = "application/json"
+	contentTypePlain    = "text/plain"
+	contentTypeMarkdown = "text/markdown"
+)
+
+var postContentTypes = []string{contentTypeHTML, contentTypeJSON, contentTypePlain, contentTypeMarkdown}
+
+// negotiateContentType picks the offered type the Accept header prefers most,
+// defaulting to the first offer when nothing matches
+func negotiateContentType(r *http.Request, offers []string) string {
+	best := offers[0]
+	bestQ := -1.0
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		if mediaType == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if found && strings.TrimSpace(key) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q <= bestQ || q <= 0 {
+			continue
+		}
+		for _, offer := range offers {
+			if mediaType == offer || mediaType == "*/*" || (strings.HasSuffix(mediaType, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(mediaType, "*"))) {
+				best = offer
+				bestQ = q
+				break
+			}
+		}
+	}
+	return best
+}
+
+type PostLinkResponse struct {
+	Title string `json:"title"`
+	Url   string `json:"url"`
+}
+
+type PostResponse struct {
+	Title       string             `json:"title"`
+	Url         string             `json:"url"`
+	Seed        int64              `json:"seed"`
+	Author      string             `json:"author"`
+	LastUpdated string             `json:"last_updated"`
+	Content     string             `json:"content"`
+	Paragraphs  []string           `json:"paragraphs"`
+	Links       []PostLinkResponse `json:"links"`
+}
+
+// RelatedResponse lists the related links of a post
+type RelatedResponse struct {
+	Seed  int64              `json:"seed"`
+	Links []PostLinkResponse `json:"links"`
+}
+
+func newPostLinks(pageLinks []train.PageLink) []PostLinkResponse {
+	links := make([]PostLinkResponse, len(pageLinks))
+	for i, link := range pageLinks {
+		links[i] = PostLinkResponse{Title: link.Title, Url: sitePath(link.Url)}
+	}
+	return links
+}
+
+func newPostResponse(story train.GeneratedPage) PostResponse {
+	return PostResponse{
+		Title:       story.Link.Title,
+		Url:         sitePath(story.Link.Url),
+		Seed:        story.Link.Seed,
+		Author:      story.Author,
+		LastUpdated: story.LastUpdated.Format("2006-01-02T15:04:05Z07:00"),
+		Content:     story.Content,
+		Paragraphs:  story.Paragraphs,
+		Links:       newPostLinks(story.Links),
+	}
+}
+
+// generatePage loads the latest model and generates the page for seed, with
+// its body starting from the optional start phrase and credited to the
+// optional author
+func (app *App) generatePage(seed int64, start, author string) (train.GeneratedPage, error) {
+	chain, err := app.loadLatestChain()
+	if err != nil {
+		return train.GeneratedPage{}, err
+	}
+
+	story, err := train.GeneratePageWithOptions(seed, chain, postOptions(chain, start, author))
+	if err != nil {
+		return train.GeneratedPage{}, fmt.Errorf("failed to generate page: %w", err)
+	}
+	return story, nil
+}
+
+// postLink loads the latest model and returns the link to the page for seed,
+// for redirects that only need its url
+func (app *App) postLink(seed int64) (train.PageLink, error) {
+	chain, err := app.loadLatestChain()
+	if err != nil {
+		return train.PageLink{}, err
+	}
+	return train.PostLink(seed, chain)
+}
+
+// redirectToCanonicalPost sends a request for the post with id, whose seed
+// is seed, to the post's canonical URL followed by suffix when id lacks the
+// post's current slug or has another one, keeping the query. Search engines
+// then see a single URL per story. The slug changes whenever the model does,
+// so the redirect must not be cached as permanent. It reports whether it
+// redirected; when the model can't be loaded it leaves the error to the
+// handler.
+func (app *App) redirectToCanonicalPost(w http.ResponseWriter, r *http.Request, id string, seed int64, suffix string) bool {
+	link, err := app.postLink(seed)
+	if err != nil || link.Url == "/post/"+id {
+		return false
+	}
+	target := sitePath(link.Url + suffix)
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+	return true
+}
+
+// renderPost writes a post in a non-streamed format
+func (app *App) renderPost(w http.ResponseWriter, r *http.Request, seed int64, start, author string, contentType string) {
+	story, err := app.generatePage(seed, start, author)
+	if err != nil {
+		status := generationErrorStatus(err)
+		if contentType == contentTypeJSON {
+			routes.WriteJSONError(w, status, err.Error())
+			return
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	train.ObserveStoryWords(story.Content)
+
+	switch contentType {
+	case contentTypeJSON:
+		routes.WriteJSON(w, http.StatusOK, newPostResponse(story))
+	case contentTypeMarkdown:
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		serveText(w, r, renderPostMarkdown(story))
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		serveText(w, r, renderPostText(story))
+	}
+}
+
+func renderPostText(story train.GeneratedPage) string {
+	var b strings.Builder
+	b.WriteString(story.Link.Title + "\n\n")
+	b.WriteString("By " + story.Author + ", " + story.LastUpdated.Format("January 2, 2006") + "\n\n")
+	for _, paragraph := range story.Paragraphs {
+		b.WriteString(paragraph + "\n\n")
+	}
+	if len(story.Links) > 0 {
+		b.WriteString("Related Stories\n")
+		for _, link := range story.Links {
+			b.WriteString("- " + link.Title + " (" + sitePath(link.Url) + ")\n")
+		}
+	}
+	return b.String()
+}
+
+func renderPostMarkdown(story train.GeneratedPage) string {
+	var b strings.Builder
+	b.WriteString("# " + markdownEscape(story.Link.Title) + "\n\n")
+	b.WriteString("*By " + markdownEscape(story.Author) + ", " + story.LastUpdated.Format("January 2, 2006") + "*\n\n")
+	for _, paragraph := range story.Paragraphs {
+		b.WriteString(markdownEscape(paragraph) + "\n\n")
+	}
+	if len(story.Links) > 0 {
+		b.WriteString("## Related Stories\n\n")
+		for _, link := range story.Links {
+			b.WriteString("- [" + markdownEscape(link.Title) + "](" + sitePath(link.Url) + ")\n")
+		}
+	}
+	return b.String()
+}
+
+// markdownEscape backslash-escapes characters that markdown would treat as inline formatting
+func markdownEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("\\`*_[]<>", r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abigpotostew/endless/routes"
+	"github.com/gorilla/mux"
+)
+
+func TestPostContentNegotiation(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	app.streamTimeout = time.Minute
+	story, err := app.generatePage(3, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		accept      string
+		contentType string
+		// body checks the shape of the representation
+		body func(body string) bool
+	}{
+		{"", "text/html; charset=utf-8", func(body string) bool {
+			return strings.HasPrefix(body, "<!DOCTYPE html>") && strings.Contains(body, "</html>")
+		}},
+		{"text/html,application/xhtml+xml,*/*;q=0.8", "text/html; charset=utf-8", func(body string) bool {
+			return strings.HasPrefix(body, "<!DOCTYPE html>")
+		}},
+		{"application/json", "application/json", func(body string) bool {
+			var post PostResponse
+			return json.Unmarshal([]byte(body), &post) == nil && post.Title == story.Link.Title && post.Content == story.Content
+		}},
+		{"text/plain", "text/plain; charset=utf-8", func(body string) bool {
+			return strings.HasPrefix(body, story.Link.Title+"\n\nBy "+story.Author) && !strings.Contains(body, "<")
+		}},
+		{"text/markdown", "text/markdown; charset=utf-8", func(body string) bool {
+			return strings.HasPrefix(body, "# ") && strings.Contains(body, "*By "+story.Author)
+		}},
+		{"text/plain;q=0.5, text/markdown;q=0.9", "text/markdown; charset=utf-8", func(body string) bool {
+			return strings.HasPrefix(body, "# ")
+		}},
+		{"image/png", "text/html; charset=utf-8", func(body string) bool {
+			return strings.HasPrefix(body, "<!DOCTYPE html>")
+		}},
+	}
+	for _, tt := range tests {
+		rec := getPost(app, story.Link.Url, tt.accept)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Accept %q got status %d", tt.accept, rec.Code)
+		}
+		if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("Accept %q got Content-Type %q, want %q", tt.accept, got, tt.contentType)
+		}
+		if !tt.body(rec.Body.String()) {
+			t.Errorf("Accept %q got an unexpected body:\n%.300s", tt.accept, rec.Body)
+		}
+	}
+}
+
+func TestPostVary(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	app.streamTimeout = time.Minute
+	story, err := app.generatePage(3, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strings.TrimPrefix(story.Link.Url, "/post/")
+	gzipped := routes.GzipMiddleware(gzip.DefaultCompression, 0)(http.HandlerFunc(app.generatePageStreamHandler))
+
+	for _, accept := range []string{"", "text/html", "application/json", "text/plain", "text/markdown"} {
+		rec := getPost(app, story.Link.Url, accept)
+		if got := rec.Header().Values("Vary"); !slices.Equal(got, []string{"Accept"}) {
+			t.Errorf("Accept %q got Vary %q, want Accept", accept, got)
+		}
+
+		// Caches must key on the encoding too, whether or not this
+		// client asked for gzip
+		for _, encoding := range []string{"", "gzip"} {
+			req := httptest.NewRequest("GET", story.Link.Url, nil)
+			req.Header.Set("Accept", accept)
+			req.Header.Set("Accept-Encoding", encoding)
+			rec := httptest.NewRecorder()
+			gzipped.ServeHTTP(rec, mux.SetURLVars(req, map[string]string{"id": id}))
+			got := rec.Header().Values("Vary")
+			if !slices.Contains(got, "Accept") || !slices.Contains(got, "Accept-Encoding") {
+				t.Errorf("Accept %q, Accept-Encoding %q got Vary %q, want Accept and Accept-Encoding", accept, encoding, got)
+			}
+		}
+	}
+}
+
+func TestPostStartPhrase(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	story, err := app.generatePage(3, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// getContent returns the body of the post generated for ?start=
+	getContent := func(start string) string {
+		rec := getPost(app, story.Link.Url+"?start="+url.QueryEscape(start), "application/json")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("start %q got status %d", start, rec.Code)
+		}
+		var post PostResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &post); err != nil {
+			t.Fatal(err)
+		}
+		return post.Content
+	}
+	for _, start := range []string{"A bird", "Suddenly the dog", "  My   "} {
+		if got := getContent(start); !strings.HasPrefix(got, strings.Join(strings.Fields(start), " ")+" ") {
+			t.Errorf("start %q generated %q", start, got)
+		}
+	}
+	// A phrase the model can't continue leaves the post as it was
+	for _, start := range []string{"Unicorns", "The unicorn"} {
+		if got := getContent(start); got != story.Content {
+			t.Errorf("start %q generated %q, want %q", start, got, story.Content)
+		}
+	}
+}
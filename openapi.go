@@ -0,0 +1,83 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// openAPIDocument is the hand-maintained OpenAPI 3 description of the JSON
+// API. Update openapi.json alongside any handler whose parameters or
+// response shape change.
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// openAPIHandler serves openapi.json
+func (app *App) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument)
+}
+
+// checkOpenAPIPaths logs the /api/ and /post/ routes of r that openapi.json
+// doesn't describe, so a new endpoint without documentation shows up at
+// startup
+func checkOpenAPIPaths(r *mux.Router) {
+	missing, err := undocumentedPaths(r)
+	if err != nil {
+		log.Printf("openapi.json is invalid: %v", err)
+		return
+	}
+	for _, path := range missing {
+		log.Printf("Route %s is missing from openapi.json", path)
+	}
+}
+
+// openAPIPaths returns the paths openapi.json describes
+func openAPIPaths() (map[string]json.RawMessage, error) {
+	var doc struct {
+		Paths map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPIDocument, &doc); err != nil {
+		return nil, err
+	}
+	return doc.Paths, nil
+}
+
+// apiPaths returns the path templates of the /api/ and /post/ routes of r,
+// without basePath
+func apiPaths(r *mux.Router) []string {
+	var paths []string
+	r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		path = strings.TrimPrefix(path, basePath)
+		if strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/post/") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths
+}
+
+// undocumentedPaths returns the /api/ and /post/ routes of r missing from
+// openapi.json
+func undocumentedPaths(r *mux.Router) ([]string, error) {
+	documented, err := openAPIPaths()
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, path := range apiPaths(r) {
+		if _, ok := documented[path]; !ok {
+			missing = append(missing, path)
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestOpenAPIDocumentCoversRoutes(t *testing.T) {
+	app := &App{}
+	rec := httptest.NewRecorder()
+	app.openAPIHandler(rec, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("got Content-Type %q", got)
+	}
+	var doc struct {
+		OpenAPI string                     `json:"openapi"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("the document isn't JSON: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("got OpenAPI version %q, want 3.x", doc.OpenAPI)
+	}
+
+	router := mux.NewRouter()
+	app.registerRoutes(router)
+	routed := map[string]bool{}
+	for _, path := range apiPaths(router) {
+		routed[path] = true
+		if _, ok := doc.Paths[path]; !ok {
+			t.Errorf("route %s is missing from openapi.json", path)
+		}
+	}
+	for path := range doc.Paths {
+		if !routed[path] {
+			t.Errorf("openapi.json describes %s, which isn't routed", path)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/abigpotostew/endless/train"
+	"github.com/gorilla/mux"
+)
+
+func TestModelOptionsApplied(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	model, err := app.getLatestModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page, err := app.generatePage(1, "", ""); err != nil || len(page.Links) == 0 {
+		t.Fatalf("with the default options the page has no links: %v", err)
+	}
+	id := strconv.Itoa(model.ID)
+	vars := map[string]string{"id": id}
+
+	body := `{"min_sentences": 3, "max_sentences": 3, "min_links": 0, "max_links": 0}`
+	rec := httptest.NewRecorder()
+	app.updateModelOptionsHandler(rec, mux.SetURLVars(httptest.NewRequest("PUT", "/api/train/"+id+"/options", strings.NewReader(body)), vars))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	app.modelOptionsHandler(rec, mux.SetURLVars(httptest.NewRequest("GET", "/api/train/"+id+"/options", nil), vars))
+	var opts train.GenerateOptions
+	if err := json.Unmarshal(rec.Body.Bytes(), &opts); err != nil {
+		t.Fatal(err)
+	}
+	if opts.MinSentences != 3 || opts.MaxSentences != 3 || opts.MaxLinks != 0 {
+		t.Errorf("the stored options are %+v", opts)
+	}
+
+	// Every page the model writes follows its options
+	for seed := int64(0); seed < 10; seed++ {
+		page, err := app.generatePage(seed, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sentences := strings.Count(page.Content, "."); sentences != 3 {
+			t.Errorf("seed %d has %d sentences, want 3: %q", seed, sentences, page.Content)
+		}
+		if len(page.Links) != 0 {
+			t.Errorf("seed %d has %d links, want none", seed, len(page.Links))
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWordPace(t *testing.T) {
+	base := 50 * time.Millisecond
+	tests := []struct {
+		word  string
+		curve bool
+		want  time.Duration
+	}{
+		{word: "a", want: base},
+		{word: "extraordinary", want: base},
+		{word: "ended.", want: base},
+		{word: "a", curve: true, want: base / 2},
+		{word: "house", curve: true, want: base},
+		{word: "lighthouse", curve: true, want: 2 * base},
+		{word: "lighthouse,", curve: true, want: base*11/5 + base},
+		{word: "hous.", curve: true, want: base + base*3},
+		{word: "hous?", curve: true, want: base + base*3},
+	}
+	for _, tt := range tests {
+		if got := wordPace(tt.word, base, tt.curve); got != tt.want {
+			t.Errorf("wordPace(%q, curve %v) = %s, want %s", tt.word, tt.curve, got, tt.want)
+		}
+	}
+}
+
+func TestWordPaceScalesWithLength(t *testing.T) {
+	base := 50 * time.Millisecond
+	short := wordPace("quill", base, true)
+	for _, word := range []string{"quillquill", "quillquillquill"} {
+		want := short * time.Duration(len(word)/len("quill"))
+		if got := wordPace(word, base, true); got != want {
+			t.Errorf("%q waits %s, want %s for %d times the letters of %q", word, got, want, len(word)/len("quill"), "quill")
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"html"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/abigpotostew/endless/routes"
+	"github.com/abigpotostew/endless/store"
+	"github.com/abigpotostew/endless/train"
+	"github.com/gorilla/mux"
+)
+
+// searchResultCount is how many published posts a search lists
+const searchResultCount = 20
+
+// publishPostHandler saves the post for a seed as the current model writes
+// it. Published posts keep that text when the model changes, and /search
+// finds them. Publishing a seed again replaces its post.
+func (app *App) publishPostHandler(w http.ResponseWriter, r *http.Request) {
+	seed, err := parsePostSeed(mux.Vars(r)["id"])
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusBadRequest, "Invalid ID: "+err.Error())
+		return
+	}
+
+	story, err := app.generatePage(seed, "", "")
+	if err != nil {
+		routes.WriteJSONError(w, generationErrorStatus(err), err.Error())
+		return
+	}
+
+	post, err := app.store.SavePost(store.Post{
+		Seed:    seed,
+		Url:     story.Link.Url,
+		Title:   story.Link.Title,
+		Author:  story.Author,
+		Content: story.Content,
+	})
+	if err != nil {
+		routes.WriteJSONError(w, http.StatusInternalServerError, "Failed to save post: "+err.Error())
+		return
+	}
+
+	routes.WriteJSON(w, http.StatusCreated, post)
+}
+
+// homePosts returns count posts for the home page. With HOME_PUBLISHED_POSTS
+// set they are the newest published posts, up to that many, and otherwise or
+// while none are published they are generated from chain for today.
+func (app *App) homePosts(chain train.MarkovChain, count int) ([]train.GeneratedPage, error) {
+	if app.homePublishedPosts > 0 {
+		published, err := app.store.GetAllPosts(min(count, app.homePublishedPosts), 0)
+		if err != nil {
+			// The generated posts are a better home page than an error
+			log.Printf("Failed to load published posts: %v", err)
+		}
+		if len(published) > 0 {
+			posts := make([]train.GeneratedPage, len(published))
+			for i, post := range published {
+				posts[i] = publishedPage(post)
+			}
+			return posts, nil
+		}
+	}
+	return train.GenerateHomePagePosts(chain, count)
+}
+
+// publishedPage returns the page of a published post, dated when it was
+// published
+func publishedPage(post store.Post) train.GeneratedPage {
+	published, err := time.Parse(time.RFC3339, post.CreatedAt)
+	if err != nil {
+		log.Printf("Post %d has an unreadable date %q: %v", post.ID, post.CreatedAt, err)
+	}
+	return train.GeneratedPage{
+		Link:        train.PageLink{Url: post.Url, Title: post.Title, Seed: post.Seed},
+		Content:     post.Content,
+		Author:      post.Author,
+		LastUpdated: published,
+	}
+}
+
+// searchHandler lists the published posts matching ?q=
+func (app *App) searchHandler(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	var resultsHTML strings.Builder
+	if query != "" {
+		posts, err := app.store.SearchPosts(query, searchResultCount)
+		if err != nil {
+			log.Printf("Failed to search posts for %q: %v", query, err)
+			app.writeErrorPage(w, http.StatusInternalServerError, "Something went wrong while searching the stories.")
+			return
+		}
+		if len(posts) == 0 {
+			resultsHTML.WriteString(`
+        <p>No published stories match your search.</p>`)
+		} else {
+			resultsHTML.WriteString(`
+        <ol class="posts-list">`)
+			for _, post := range posts {
+				resultsHTML.WriteString(`
+            <li>
+                <a href="` + html.EscapeString(sitePath(post.Url)) + `">` + html.EscapeString(post.Title) + `</a>
+                <span class="author">by ` + html.EscapeString(post.Author) + `</span>
+                <p>` + html.EscapeString(app.excerpt(post.Content)) + `</p>
+            </li>`)
+			}
+			resultsHTML.WriteString(`
+        </ol>`)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write([]byte(`<!DOCTYPE html>
+<html lang="` + html.EscapeString(app.siteLang) + `">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="robots" content="noindex">
+    <title>Search - Endless Stories</title>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            max-width: 800px;
+            margin: 0 auto;
+            padding: 20px;
+            line-height: 1.6;
+            color: #333;
+        }
+        a {
+            color: ` + defaultThemeColor + `;
+        }
+        .posts-list {
+            padding-left: 1.5em;
+        }
+        .posts-list li {
+            margin-bottom: 20px;
+        }
+        .posts-list a {
+            font-weight: bold;
+            text-decoration: none;
+        }
+        .posts-list .author {
+            color: #888;
+            font-size: 0.9em;
+        }
+        .posts-list p {
+            margin: 5px 0 0 0;
+            color: #666;
+        }
+    </style>
+</head>
+<body>
+    <nav><a href="` + html.EscapeString(sitePath("/")) + `">Endless Stories</a></nav>
+    <main>
+        <h1>Search</h1>
+        <form action="` + html.EscapeString(sitePath("/search")) + `" method="get" role="search">
+            <input type="search" name="q" value="` + html.EscapeString(query) + `" aria-label="Search published stories">
+            <button type="submit">Search</button>
+        </form>` + resultsHTML.String() + `
+    </main>
+</body>
+</html>`))
+}
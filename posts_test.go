@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/abigpotostew/endless/store"
+	"github.com/gorilla/mux"
+)
+
+func TestPublishedPostsAreSearchable(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	link, err := app.postLink(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/api/posts/42", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "42"})
+	rec := httptest.NewRecorder()
+	app.publishPostHandler(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("publishing returned %d: %s", rec.Code, rec.Body)
+	}
+	var post store.Post
+	if err := json.Unmarshal(rec.Body.Bytes(), &post); err != nil {
+		t.Fatal(err)
+	}
+	if post.Seed != 42 || post.Url != link.Url || post.Title != link.Title || post.Content == "" {
+		t.Errorf("published %+v for %+v", post, link)
+	}
+
+	search := func(query string) string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		app.searchHandler(rec, httptest.NewRequest("GET", "/search?q="+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("search returned %d: %s", rec.Code, rec.Body)
+		}
+		return rec.Body.String()
+	}
+	word := strings.Fields(post.Content)[0]
+	if body := search(word); !strings.Contains(body, `href="`+html.EscapeString(post.Url)+`"`) {
+		t.Errorf("searching for %q doesn't link to the post:\n%s", word, body)
+	}
+	if body := search("zebra"); strings.Contains(body, post.Url) || !strings.Contains(body, "No published stories match") {
+		t.Errorf("searching for a missing word:\n%s", body)
+	}
+}
+
+func TestPublishPostInvalidSeed(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	req := httptest.NewRequest("POST", "/api/posts/-5", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "-5"})
+	rec := httptest.NewRecorder()
+	app.publishPostHandler(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("got status %d, want 400", rec.Code)
+	}
+	if count, err := app.store.CountPosts(); err != nil || count != 0 {
+		t.Errorf("%d posts saved, %v", count, err)
+	}
+}
+
+func TestHomePagePublishedPosts(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	app.homePublishedPosts = 2
+
+	home := func() string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		app.homeHandler(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("home page returned %d: %s", rec.Code, rec.Body)
+		}
+		return rec.Body.String()
+	}
+	cards := func(body string) int {
+		return strings.Count(body, `class="post-card"`)
+	}
+
+	// Until something is published the grid is generated as usual
+	if n := cards(home()); n != defaultHomePostCount {
+		t.Errorf("the home page has %d cards before publishing, want %d", n, defaultHomePostCount)
+	}
+
+	published := []store.Post{
+		{Seed: 1, Url: "/post/1-first", Title: "First published", Author: "Arlo Mills", Content: "The first post."},
+		{Seed: 2, Url: "/post/2-second", Title: "Second published", Author: "Arlo Mills", Content: "The second post."},
+		{Seed: 3, Url: "/post/3-third", Title: "Third published", Author: "Arlo Mills", Content: "The third post."},
+	}
+	for _, post := range published {
+		if _, err := app.store.SavePost(post); err != nil {
+			t.Fatal(err)
+		}
+	}
+	body := home()
+	if n := cards(body); n != app.homePublishedPosts {
+		t.Errorf("the home page has %d cards, want the %d newest published posts", n, app.homePublishedPosts)
+	}
+	for _, post := range published[1:] {
+		if !strings.Contains(body, `href="`+post.Url+`" class="post-card"`) {
+			t.Errorf("the home page doesn't show %q", post.Title)
+		}
+	}
+	if strings.Contains(body, published[0].Url) {
+		t.Error("the home page shows more published posts than HOME_PUBLISHED_POSTS")
+	}
+
+	rec := httptest.NewRecorder()
+	app.homeJSONHandler(rec, httptest.NewRequest("GET", "/api/home?count=1", nil))
+	var posts []HomePost
+	if err := json.Unmarshal(rec.Body.Bytes(), &posts); err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 || posts[0].Url != published[2].Url {
+		t.Errorf("/api/home?count=1 returned %+v, want the newest published post", posts)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abigpotostew/endless/train"
+)
+
+func TestPreviewRendersWithoutSaving(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	app.streamTimeout = time.Minute
+	before, err := app.store.CountMarkovChainModels()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corpus := "The lighthouse keeper climbed the stairs. The lamp burned all night. A ship passed the rocks safely."
+	chain, err := train.BuildModel(corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := train.GeneratePage(7, chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	app.previewHandler(rec, httptest.NewRequest("POST", "/preview?seed=7", strings.NewReader(corpus)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	page := rec.Body.String()
+	if !strings.Contains(page, "<title>"+html.EscapeString(want.Link.Title)) {
+		t.Errorf("the page has no title %q", want.Link.Title)
+	}
+	for _, word := range strings.Fields(want.Content) {
+		if !strings.Contains(page, html.EscapeString(word)) {
+			t.Errorf("the page is missing the content word %q", word)
+		}
+	}
+
+	after, err := app.store.CountMarkovChainModels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after != before {
+		t.Errorf("the preview stored %d models", after-before)
+	}
+}
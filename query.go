@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// queryInt reads the integer query parameter name, clamped to [low, high] so a
+// huge value can't make a handler do unbounded work. A missing parameter gives
+// def; one that isn't an integer is an error the handler answers with a 400.
+func queryInt(r *http.Request, name string, def, low, high int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s: must be an integer", name)
+	}
+	return clamp(n, low, high), nil
+}
+
+// clamp limits n to [low, high]
+func clamp(n, low, high int) int {
+	return max(low, min(n, high))
+}
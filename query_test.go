@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueryInt(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    int
+		wantErr bool
+	}{
+		{query: "", want: 5},
+		{query: "n=3", want: 3},
+		{query: "n=0", want: 1},
+		{query: "n=-7", want: 1},
+		{query: "n=1000000", want: 10},
+		{query: "n=99999999999999999999", wantErr: true},
+		{query: "n=three", wantErr: true},
+		{query: "n=2.5", wantErr: true},
+		{query: "other=3", want: 5},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/?"+tt.query, nil)
+		got, err := queryInt(req, "n", 5, 1, 10)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v, want error %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("%q: got %d, want %d", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// serveText writes body, answering Range and If-Range requests so download
+// managers can resume a large export or fetch part of it. The Content-Type
+// must already be set. The ETag is a hash of body, so a resumed download
+// restarts from scratch if the content changed in between.
+func serveText(w http.ResponseWriter, r *http.Request, body string) {
+	hash := fnv.New64a()
+	hash.Write([]byte(body))
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, hash.Sum64()))
+	w.Header().Set("Accept-Ranges", "bytes")
+	http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/abigpotostew/endless/routes"
+)
+
+func TestServeTextRanges(t *testing.T) {
+	const body = "The cat sat on the mat. The dog ran home."
+	etag := func() string {
+		rec := httptest.NewRecorder()
+		serveText(rec, httptest.NewRequest("GET", "/", nil), body)
+		return rec.Header().Get("ETag")
+	}()
+
+	tests := []struct {
+		name       string
+		header     map[string]string
+		wantStatus int
+		wantBody   string
+		wantRange  string
+	}{
+		{name: "whole body", wantStatus: http.StatusOK, wantBody: body},
+		{name: "byte range", header: map[string]string{"Range": "bytes=4-10"}, wantStatus: http.StatusPartialContent, wantBody: body[4:11], wantRange: "bytes 4-10/41"},
+		{name: "suffix range", header: map[string]string{"Range": "bytes=-5"}, wantStatus: http.StatusPartialContent, wantBody: body[len(body)-5:], wantRange: "bytes 36-40/41"},
+		{name: "matching If-Range", header: map[string]string{"Range": "bytes=0-2", "If-Range": etag}, wantStatus: http.StatusPartialContent, wantBody: "The", wantRange: "bytes 0-2/41"},
+		{name: "stale If-Range", header: map[string]string{"Range": "bytes=0-2", "If-Range": `"stale"`}, wantStatus: http.StatusOK, wantBody: body},
+		{name: "unsatisfiable", header: map[string]string{"Range": "bytes=100-200"}, wantStatus: http.StatusRequestedRangeNotSatisfiable, wantRange: "bytes */41"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			serveText(rec, req, body)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+			if got := rec.Header().Get("Content-Range"); got != tt.wantRange {
+				t.Errorf("Content-Range %q, want %q", got, tt.wantRange)
+			}
+		})
+	}
+}
+
+func TestServeTextIsNotGzipped(t *testing.T) {
+	body := strings.Repeat("endless ", 1000)
+	handler := routes.GzipMiddleware(6, 10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		serveText(w, r, body)
+	}))
+	for _, rangeHeader := range []string{"", "bytes=0-99"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if enc := rec.Header().Get("Content-Encoding"); enc != "" {
+			t.Errorf("Range %q: Content-Encoding %q with strong ETag %s", rangeHeader, enc, rec.Header().Get("ETag"))
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abigpotostew/endless/store"
+	"github.com/abigpotostew/endless/train"
+)
+
+// getReady asks the readiness handler whether app is ready
+func getReady(app *App) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	app.readyHandler(rec, httptest.NewRequest("GET", "/ready", nil))
+	return rec
+}
+
+// wantNotReady fails t unless app reports 503 with a reason containing reason
+func wantNotReady(t *testing.T, app *App, reason string) {
+	t.Helper()
+	rec := getReady(app)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got status %d, want 503", rec.Code)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, reason) {
+		t.Errorf("got %q, want a reason containing %q", body, reason)
+	}
+}
+
+func TestReadyCorruptModel(t *testing.T) {
+	postStore, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { postStore.Close() })
+	if _, err := postStore.SaveMarkovChainModel([]byte("not a model"), true); err != nil {
+		t.Fatal(err)
+	}
+	app := &App{store: postStore}
+	wantNotReady(t, app, "could be loaded")
+
+	// The failure is reused until it expires, even once a good model exists
+	chain, err := train.BuildModel(testCorpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := train.SerializeModel(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := postStore.SaveMarkovChainModel(data, true); err != nil {
+		t.Fatal(err)
+	}
+	app.clearModelCache()
+	wantNotReady(t, app, "could be loaded")
+
+	app.readyCheckedAt = time.Time{}
+	if rec := getReady(app); rec.Code != http.StatusOK {
+		t.Errorf("got status %d after the cached result expired: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestReadyTinyModel(t *testing.T) {
+	app := newTestApp(t, "The cat sat.")
+	app.minVocabulary = 50
+	wantNotReady(t, app, "fewer than the required 50")
+
+	// The result is cached, so lowering the bar shows only once it expires
+	app.minVocabulary = 2
+	wantNotReady(t, app, "fewer than the required 50")
+	app.readyCheckedAt = time.Time{}
+	if rec := getReady(app); rec.Code != http.StatusOK {
+		t.Errorf("got status %d with a low enough minimum: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/abigpotostew/endless/train"
+	"github.com/gorilla/mux"
+)
+
+func TestNonCanonicalPostRedirect(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	chain, err := app.loadLatestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := train.GeneratePage(42, chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Link.Slug == "" {
+		t.Fatal("seed 42 has no slug to redirect to")
+	}
+
+	tests := []struct {
+		name    string
+		id      string
+		target  string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{name: "post", id: "42", target: "/post/42?start=The", handler: app.generatePageStreamHandler, want: page.Link.Url + "?start=The"},
+		{name: "amp", id: "42", target: "/post/42/amp", handler: app.ampHandler, want: page.Link.Url + "/amp"},
+		{name: "post with another slug", id: "42-old-title", target: "/post/42-old-title", handler: app.generatePageStreamHandler, want: page.Link.Url},
+		{name: "amp with another slug", id: "42-old-title", target: "/post/42-old-title/amp", handler: app.ampHandler, want: page.Link.Url + "/amp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.target, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+			rec := httptest.NewRecorder()
+			tt.handler(rec, req)
+
+			// Both redirects use the same status, since the slug changes with the model
+			if rec.Code != http.StatusFound {
+				t.Errorf("got status %d, want 302", rec.Code)
+			}
+			if got := rec.Header().Get("Location"); got != tt.want {
+				t.Errorf("redirected to %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRerollSeed(t *testing.T) {
+	tests := []struct {
+		seed int64
+		want int64
+	}{
+		{0, 1},
+		{42, 43},
+		{math.MaxInt64, 0},
+	}
+	for _, tt := range tests {
+		if got := rerollSeed(tt.seed); got != tt.want || rerollSeed(tt.seed) != got {
+			t.Errorf("rerollSeed(%d) = %d, want %d", tt.seed, got, tt.want)
+		}
+	}
+}
+
+func TestRerollRedirect(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	chain, err := app.loadLatestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := train.PostLink(43, chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id     string
+		target string
+		status int
+		want   string
+	}{
+		{id: "42-some-title", target: "/post/42-some-title/reroll", status: http.StatusFound, want: next.Url},
+		{id: "42", target: "/post/42/reroll?start=The", status: http.StatusFound, want: next.Url + "?start=The"},
+		{id: "-1", target: "/post/-1/reroll", status: http.StatusBadRequest},
+		{id: "nope", target: "/post/nope/reroll", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		// The same post always rerolls to the same one
+		for i := 0; i < 2; i++ {
+			req := mux.SetURLVars(httptest.NewRequest("GET", tt.target, nil), map[string]string{"id": tt.id})
+			rec := httptest.NewRecorder()
+			app.rerollHandler(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("%s got status %d, want %d", tt.target, rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Location"); got != tt.want {
+				t.Errorf("%s redirected to %q, want %q", tt.target, got, tt.want)
+			}
+		}
+	}
+}
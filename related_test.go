@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/abigpotostew/endless/train"
+	"github.com/gorilla/mux"
+)
+
+// pageLinkPattern matches a related link in the HTML of a post
+var pageLinkPattern = regexp.MustCompile(`<li role="listitem"><a href="([^"]*)">(.*?)</a></li>`)
+
+func TestRelatedJSONMatchesPostPage(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	app.streamTimeout = time.Minute
+	chain, err := app.loadLatestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := train.GeneratePage(42, chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := path.Base(page.Link.Url)
+
+	for _, query := range []string{"", "?start=The", "?author=" + url.QueryEscape(train.Authors()[0])} {
+		t.Run(query, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/post/"+id+query, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": id})
+			rec := httptest.NewRecorder()
+			app.generatePageStreamHandler(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("post page returned %d: %s", rec.Code, rec.Body)
+			}
+			var want []PostLinkResponse
+			for _, m := range pageLinkPattern.FindAllStringSubmatch(rec.Body.String(), -1) {
+				want = append(want, PostLinkResponse{Title: html.UnescapeString(m[2]), Url: html.UnescapeString(m[1])})
+			}
+
+			req = httptest.NewRequest("GET", "/post/"+id+"/related.json"+query, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": id})
+			rec = httptest.NewRecorder()
+			app.relatedJSONHandler(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("related links returned %d: %s", rec.Code, rec.Body)
+			}
+			var related RelatedResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &related); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(want) == 0 || len(related.Links) != len(want) {
+				t.Fatalf("got links %v, the page has %v", related.Links, want)
+			}
+			for i := range want {
+				if related.Links[i] != want[i] {
+					t.Errorf("link %d is %v, the page has %v", i, related.Links[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRelatedJSONUnknownAuthor(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	req := httptest.NewRequest("GET", "/post/42/related.json?author=Nobody+Atall", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "42"})
+	rec := httptest.NewRecorder()
+	app.relatedJSONHandler(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("got status %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abigpotostew/endless/routes"
+	"github.com/gorilla/mux"
+)
+
+func TestMaxResponseBytes(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	app.streamTimeout = time.Minute
+	story, err := app.generatePage(3, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strings.TrimPrefix(story.Link.Url, "/post/")
+	tests := []struct {
+		name    string
+		target  string
+		handler http.HandlerFunc
+		closing string
+		// header ends the part of the page written before any limit applies
+		header string
+	}{
+		{name: "post", target: story.Link.Url, handler: func(w http.ResponseWriter, r *http.Request) {
+			app.generatePageStreamHandler(w, mux.SetURLVars(r, map[string]string{"id": id}))
+		}, closing: streamCloseHTML, header: `<h1 class="title" itemprop="headline">`},
+		{name: "home", target: "/", handler: app.homeHandler, closing: homeFooterHTML, header: `<div class="posts-grid">`},
+	}
+	for _, tt := range tests {
+		// get serves the page through the logging middleware, which counts
+		// the bytes written
+		get := func() string {
+			rec := httptest.NewRecorder()
+			routes.LoggingMiddleware(tt.handler).ServeHTTP(rec, httptest.NewRequest("GET", tt.target, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("%s: got status %d", tt.name, rec.Code)
+			}
+			return rec.Body.String()
+		}
+
+		app.maxResponseBytes = 0
+		full := get()
+		headerEnd := strings.Index(full, tt.header) + len(tt.header)
+		for limit := headerEnd + len(tt.closing); limit < len(full); limit += 101 {
+			app.maxResponseBytes = int64(limit)
+			capped := get()
+			if len(capped) > limit {
+				t.Errorf("%s: wrote %d bytes with a cap of %d", tt.name, len(capped), limit)
+			}
+			if len(capped) == len(full) {
+				t.Errorf("%s: the cap of %d bytes didn't shorten the %d byte page", tt.name, limit, len(full))
+			}
+			if !strings.HasSuffix(capped, tt.closing) {
+				t.Errorf("%s: the page capped at %d bytes isn't closed:\n%s", tt.name, limit, capped[max(0, len(capped)-200):])
+			}
+		}
+
+		app.maxResponseBytes = int64(len(full))
+		if got := get(); got != full {
+			t.Errorf("%s: a cap of exactly the page size changed the page", tt.name)
+		}
+	}
+}
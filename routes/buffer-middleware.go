@@ -0,0 +1,58 @@
+package routes
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+)
+
+// bufferedWriter holds the whole response in memory so it can be sent in one
+// write with a Content-Length
+type bufferedWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (bw *bufferedWriter) WriteHeader(code int) {
+	if bw.statusCode == 0 {
+		bw.statusCode = code
+	}
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	if bw.statusCode == 0 {
+		bw.statusCode = http.StatusOK
+	}
+	return bw.body.Write(b)
+}
+
+// Flush is a no-op; everything is sent once the handler returns
+func (bw *bufferedWriter) Flush() {}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (bw *bufferedWriter) Unwrap() http.ResponseWriter {
+	return bw.ResponseWriter
+}
+
+// BytesWritten returns the size of the buffered response body
+func (bw *bufferedWriter) BytesWritten() int64 {
+	return int64(bw.body.Len())
+}
+
+// BufferMiddleware renders each response fully before sending it with a
+// Content-Length, for hosts whose proxies break chunked streaming. Under
+// GzipMiddleware the compressed response keeps a Content-Length too.
+func BufferMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buffered := &bufferedWriter{ResponseWriter: w}
+		next.ServeHTTP(buffered, r)
+
+		if buffered.statusCode == 0 {
+			buffered.statusCode = http.StatusOK
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(buffered.body.Len()))
+		w.WriteHeader(buffered.statusCode)
+		w.Write(buffered.body.Bytes())
+	})
+}
@@ -0,0 +1,91 @@
+package routes
+
+import (
+	"compress/gzip"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBufferMiddlewareContentLength(t *testing.T) {
+	// Varied words so the compressed page outgrows net/http's response
+	// buffer, which would otherwise set a Content-Length by itself
+	var words strings.Builder
+	prng := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		words.WriteString(strconv.Itoa(prng.Int()) + " ")
+	}
+	body := words.String()
+	// The handler flushes after every word, as streamed pages do
+	page := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		for _, word := range strings.SplitAfter(body, " ") {
+			w.Write([]byte(word))
+			w.(http.Flusher).Flush()
+		}
+	})
+	tests := []struct {
+		name           string
+		handler        http.Handler
+		acceptEncoding string
+		wantGzip       bool
+	}{
+		{name: "buffered", handler: BufferMiddleware(page)},
+		{name: "buffered and gzipped", handler: GzipMiddleware(6, 1024)(BufferMiddleware(page)), acceptEncoding: "gzip", wantGzip: true},
+		{name: "buffered without accepting gzip", handler: GzipMiddleware(6, 1024)(BufferMiddleware(page))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			req, err := http.NewRequest("GET", server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			// The default transport would ask for gzip itself and decompress it
+			resp, err := (&http.Transport{DisableCompression: true}).RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			raw, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(resp.TransferEncoding) != 0 {
+				t.Errorf("sent with Transfer-Encoding %v", resp.TransferEncoding)
+			}
+			if resp.ContentLength != int64(len(raw)) {
+				t.Errorf("Content-Length is %d, the body is %d bytes", resp.ContentLength, len(raw))
+			}
+			if got := resp.Header.Get("Content-Encoding") == "gzip"; got != tt.wantGzip {
+				t.Fatalf("gzip encoded %v, want %v", got, tt.wantGzip)
+			}
+
+			decoded := string(raw)
+			if tt.wantGzip {
+				gz, err := gzip.NewReader(strings.NewReader(decoded))
+				if err != nil {
+					t.Fatal(err)
+				}
+				unzipped, err := io.ReadAll(gz)
+				if err != nil {
+					t.Fatal(err)
+				}
+				decoded = string(unzipped)
+			}
+			if decoded != body {
+				t.Error("the body differs from the page")
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package routes
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gzipWriter holds back the first minSize bytes of a response to decide
+// whether compressing it is worthwhile. Once decided, writes go straight
+// through, so streamed pages keep streaming.
+type gzipWriter struct {
+	http.ResponseWriter
+	level      int
+	minSize    int
+	statusCode int
+	pending    []byte
+	decided    bool
+	disabled   bool
+	gz         *gzip.Writer
+	// written counts the uncompressed body bytes the handler has written
+	written int64
+}
+
+func (gw *gzipWriter) WriteHeader(code int) {
+	if gw.decided {
+		gw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if gw.statusCode == 0 {
+		gw.statusCode = code
+	}
+}
+
+func (gw *gzipWriter) Write(b []byte) (int, error) {
+	gw.written += int64(len(b))
+	if !gw.decided {
+		gw.pending = append(gw.pending, b...)
+		if len(gw.pending) < gw.minSize && !gw.disabled {
+			return len(b), nil
+		}
+		if err := gw.decide(!gw.disabled); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	return gw.write(b)
+}
+
+// write sends b on, through the gzip stream once compressing
+func (gw *gzipWriter) write(b []byte) (int, error) {
+	if gw.gz != nil {
+		return gw.gz.Write(b)
+	}
+	return gw.ResponseWriter.Write(b)
+}
+
+// BytesWritten returns the uncompressed size of the body written so far,
+// including bytes still held back, so MAX_RESPONSE_BYTES limits the page
+// rather than what goes over the wire
+func (gw *gzipWriter) BytesWritten() int64 {
+	return gw.written
+}
+
+// compressible reports whether the response may be gzipped: the handler
+// hasn't encoded the body itself, there is a body, and it doesn't serve byte
+// ranges. Ranges and their strong ETag describe the uncompressed body, so a
+// compressed copy would break Range and If-Range requests.
+func (gw *gzipWriter) compressible() bool {
+	header := gw.Header()
+	switch {
+	case header.Get("Content-Encoding") != "", header.Get("Accept-Ranges") == "bytes":
+		return false
+	case gw.statusCode == http.StatusNoContent, gw.statusCode == http.StatusNotModified, gw.statusCode == http.StatusPartialContent:
+		return false
+	}
+	return true
+}
+
+// decide sends the headers, compressing when asked to and the response is
+// compressible, then writes out the held back bytes
+func (gw *gzipWriter) decide(compress bool) error {
+	gw.decided = true
+	header := gw.Header()
+	if compress && gw.compressible() {
+		header.Set("Content-Encoding", "gzip")
+		if header.Get("Content-Length") == strconv.Itoa(len(gw.pending)) {
+			return gw.writeWhole()
+		}
+		header.Del("Content-Length")
+		gz, err := gzip.NewWriterLevel(gw.ResponseWriter, gw.level)
+		if err != nil {
+			return err
+		}
+		gw.gz = gz
+	}
+	if gw.statusCode != 0 {
+		gw.ResponseWriter.WriteHeader(gw.statusCode)
+	}
+
+	pending := gw.pending
+	gw.pending = nil
+	if len(pending) == 0 {
+		return nil
+	}
+	_, err := gw.write(pending)
+	return err
+}
+
+// writeWhole compresses a body that arrived in one piece with its
+// Content-Length, as BufferMiddleware sends it, so the compressed response
+// keeps a Content-Length instead of falling back to chunked encoding
+func (gw *gzipWriter) writeWhole() error {
+	var compressed bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&compressed, gw.level)
+	if err != nil {
+		return err
+	}
+	if _, err := gz.Write(gw.pending); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	gw.pending = nil
+
+	gw.Header().Set("Content-Length", strconv.Itoa(compressed.Len()))
+	if gw.statusCode != 0 {
+		gw.ResponseWriter.WriteHeader(gw.statusCode)
+	}
+	_, err = gw.ResponseWriter.Write(compressed.Bytes())
+	return err
+}
+
+// close sends a response too small to compress, or ends the gzip stream
+func (gw *gzipWriter) close() {
+	if !gw.decided {
+		gw.decide(false)
+	}
+	if gw.gz != nil {
+		gw.gz.Close()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (gw *gzipWriter) Unwrap() http.ResponseWriter {
+	return gw.ResponseWriter
+}
+
+// Flush sends everything written so far. A response flushed before reaching
+// minSize is sent uncompressed.
+func (gw *gzipWriter) Flush() {
+	if !gw.decided {
+		gw.decide(false)
+	}
+	if gw.gz != nil {
+		gw.gz.Flush()
+	}
+	if flusher, ok := gw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// DisableCompression makes the GzipMiddleware writer under w, if any, send
+// the response uncompressed. It must be called before the body is written.
+// Streamed pages use it: flushing a gzip stream after every word ends a
+// deflate block each time, which can make the page larger than plain text.
+func DisableCompression(w http.ResponseWriter) {
+	for {
+		if gw, ok := w.(*gzipWriter); ok {
+			gw.disabled = true
+			return
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = unwrapper.Unwrap()
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip: listed
+// with a non-zero q-value, or covered by a non-zero "*" when not listed
+func acceptsGzip(header string) bool {
+	gzipQ, wildcardQ := -1.0, -1.0
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(strings.TrimSpace(name), "q") {
+				parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil {
+					parsed = 0
+				}
+				q = parsed
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(coding)) {
+		case "gzip", "x-gzip":
+			gzipQ = q
+		case "*":
+			wildcardQ = q
+		}
+	}
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+	return wildcardQ > 0
+}
+
+// GzipMiddleware compresses responses of at least minSize bytes for clients
+// that accept gzip, at the given compress/gzip level
+func GzipMiddleware(level, minSize int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(r.Header.Get("Accept-Encoding")) || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			gw := &gzipWriter{ResponseWriter: w, level: level, minSize: minSize}
+			defer gw.close()
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
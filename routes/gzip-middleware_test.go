@@ -0,0 +1,95 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"GZIP", true},
+		{"deflate, gzip;q=0.5", true},
+		{"gzip;q=0", false},
+		{"gzip; q=0.0", false},
+		{"gzip;q=0, *", false},
+		{"*", true},
+		{"*;q=0", false},
+		{"br, *;q=0.1", true},
+		{"deflate", false},
+		{"x-gzip", true},
+		{"gzip;q=abc", false},
+	}
+	for _, tt := range tests {
+		if got := acceptsGzip(tt.header); got != tt.want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestGzipMiddleware(t *testing.T) {
+	body := strings.Repeat("endless stories ", 200)
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		disable        bool
+		flushes        bool
+		wantGzip       bool
+	}{
+		{name: "compressed", acceptEncoding: "gzip", wantGzip: true},
+		{name: "refused with q=0", acceptEncoding: "gzip;q=0"},
+		{name: "disabled", acceptEncoding: "gzip", disable: true},
+		{name: "disabled and flushed", acceptEncoding: "gzip", disable: true, flushes: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var written int64
+			handler := GzipMiddleware(6, 1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.disable {
+					DisableCompression(w)
+				}
+				for _, word := range strings.SplitAfter(body, " ") {
+					w.Write([]byte(word))
+					if tt.flushes {
+						w.(http.Flusher).Flush()
+					}
+				}
+				written, _ = BytesWritten(w)
+			}))
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Encoding") == "gzip"; got != tt.wantGzip {
+				t.Errorf("gzip encoded %v, want %v", got, tt.wantGzip)
+			}
+			if !tt.wantGzip && rec.Body.String() != body {
+				t.Errorf("uncompressed body differs")
+			}
+			if tt.acceptEncoding == "gzip" && written != int64(len(body)) {
+				t.Errorf("BytesWritten = %d, want the uncompressed %d", written, len(body))
+			}
+		})
+	}
+}
+
+func TestGzipBytesWrittenCountsHeldBackBytes(t *testing.T) {
+	var written int64
+	handler := GzipMiddleware(6, 1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("short"))
+		written, _ = BytesWritten(w)
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if written != 5 {
+		t.Errorf("BytesWritten = %d before the size decision, want 5", written)
+	}
+}
@@ -28,6 +28,31 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return size, err
 }
 
+// Unwrap exposes the underlying writer to http.ResponseController and BytesWritten
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// BytesWritten returns the size of the response body written so far
+func (rw *responseWriter) BytesWritten() int64 {
+	return rw.responseSize
+}
+
+// BytesWritten returns how many body bytes have been written through w. The
+// count is only known when w is, or wraps, the LoggingMiddleware writer.
+func BytesWritten(w http.ResponseWriter) (int64, bool) {
+	for {
+		if counter, ok := w.(interface{ BytesWritten() int64 }); ok {
+			return counter.BytesWritten(), true
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return 0, false
+		}
+		w = unwrapper.Unwrap()
+	}
+}
+
 // Add Flush method to implement http.Flusher interface
 func (rw *responseWriter) Flush() {
 	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
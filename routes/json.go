@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// ErrorResponse is the envelope returned by every JSON API error
+type ErrorResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+// WriteJSON writes v as a JSON response with the given status code
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to encode JSON response: %v", err)
+	}
+}
+
+// WriteJSONError writes a {"success":false,"error":msg} response with the given status code
+func WriteJSONError(w http.ResponseWriter, status int, msg string) {
+	WriteJSON(w, status, ErrorResponse{
+		Success: false,
+		Error:   msg,
+	})
+}
@@ -0,0 +1,61 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+)
+
+// noIndexWriter adds X-Robots-Tag: noindex when the response is an error
+type noIndexWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (nw *noIndexWriter) WriteHeader(code int) {
+	if !nw.wroteHeader && code >= http.StatusBadRequest {
+		nw.Header().Set("X-Robots-Tag", "noindex")
+	}
+	nw.wroteHeader = true
+	nw.ResponseWriter.WriteHeader(code)
+}
+
+func (nw *noIndexWriter) Write(b []byte) (int, error) {
+	nw.wroteHeader = true
+	return nw.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController and BytesWritten
+func (nw *noIndexWriter) Unwrap() http.ResponseWriter {
+	return nw.ResponseWriter
+}
+
+// Add Flush method to implement http.Flusher interface
+func (nw *noIndexWriter) Flush() {
+	if flusher, ok := nw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// isNoIndexPath reports whether path serves machine-facing content that
+// should never appear in search results
+func isNoIndexPath(path string) bool {
+	return strings.HasPrefix(path, "/api/") || path == "/health" || path == "/ready" || path == "/preview"
+}
+
+// NoIndexMiddleware keeps API, health and error responses out of search
+// indexes by sending X-Robots-Tag: noindex
+func NoIndexMiddleware(next http.Handler) http.Handler {
+	return NoIndexUnder("")(next)
+}
+
+// NoIndexUnder returns NoIndexMiddleware for an app served under basePath
+func NoIndexUnder(basePath string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isNoIndexPath(strings.TrimPrefix(r.URL.Path, basePath)) {
+				w.Header().Set("X-Robots-Tag", "noindex")
+			}
+			next.ServeHTTP(&noIndexWriter{ResponseWriter: w}, r)
+		})
+	}
+}
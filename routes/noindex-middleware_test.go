@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNoIndexUnder(t *testing.T) {
+	tests := []struct {
+		basePath string
+		path     string
+		status   int
+		noIndex  bool
+	}{
+		{path: "/", status: http.StatusOK},
+		{path: "/post/1-a-title", status: http.StatusOK},
+		{path: "/post/1-a-title", status: http.StatusNotFound, noIndex: true},
+		{path: "/", status: http.StatusServiceUnavailable, noIndex: true},
+		{path: "/api/home", status: http.StatusOK, noIndex: true},
+		{path: "/api/train", status: http.StatusBadRequest, noIndex: true},
+		{path: "/health", status: http.StatusOK, noIndex: true},
+		{path: "/ready", status: http.StatusOK, noIndex: true},
+		{path: "/preview", status: http.StatusOK, noIndex: true},
+		{path: "/apiary", status: http.StatusOK},
+		{basePath: "/stories", path: "/stories/api/home", status: http.StatusOK, noIndex: true},
+		{basePath: "/stories", path: "/stories/post/1-a-title", status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		handler := NoIndexUnder(tt.basePath)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+		}))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+		if got := rec.Header().Get("X-Robots-Tag") == "noindex"; got != tt.noIndex {
+			t.Errorf("%s%s with status %d: noindex %v, want %v", tt.basePath, tt.path, tt.status, got, tt.noIndex)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<p>hello</p>"))
+	})
+	tests := []struct {
+		name                  string
+		contentSecurityPolicy string
+		referrerPolicy        string
+	}{
+		{name: "both", contentSecurityPolicy: "default-src 'self'", referrerPolicy: "no-referrer"},
+		{name: "no policy", referrerPolicy: "strict-origin-when-cross-origin"},
+		{name: "no referrer policy", contentSecurityPolicy: "default-src 'self'"},
+		{name: "neither"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		SecurityHeaders(tt.contentSecurityPolicy, tt.referrerPolicy)(ok).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		want := map[string]string{
+			"Content-Security-Policy": tt.contentSecurityPolicy,
+			"Referrer-Policy":         tt.referrerPolicy,
+			"X-Content-Type-Options":  "nosniff",
+		}
+		for header, value := range want {
+			if got := rec.Header().Values(header); value == "" && len(got) != 0 || value != "" && (len(got) != 1 || got[0] != value) {
+				t.Errorf("%s: got %s %q, want %q", tt.name, header, got, value)
+			}
+		}
+	}
+}
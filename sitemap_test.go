@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abigpotostew/endless/store"
+	"github.com/abigpotostew/endless/train"
+)
+
+// datedStore reports the current model as created at createdAt
+type datedStore struct {
+	store.PostStore
+	createdAt time.Time
+}
+
+func (s *datedStore) GetCurrentMarkovChainModel() (*store.MarkovChainModel, error) {
+	model, err := s.PostStore.GetCurrentMarkovChainModel()
+	if err != nil {
+		return nil, err
+	}
+	model.CreatedAt = s.createdAt.Format(time.DateTime)
+	return model, nil
+}
+
+func TestSitemapLastModifiedIsModelCreation(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	model, err := app.getLatestModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createdAt, ok := modelCreatedAt(model)
+	if !ok {
+		t.Fatalf("couldn't parse the model's created_at %q", model.CreatedAt)
+	}
+
+	get := func(since string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/sitemap.xml", nil)
+		if since != "" {
+			req.Header.Set("If-Modified-Since", since)
+		}
+		rec := httptest.NewRecorder()
+		app.sitemapHandler(rec, req)
+		return rec
+	}
+
+	rec := get("")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", rec.Code)
+	}
+	lastModified := rec.Header().Get("Last-Modified")
+	if want := createdAt.Format(http.TimeFormat); lastModified != want {
+		t.Errorf("Last-Modified is %q, want %q", lastModified, want)
+	}
+	if want := "<lastmod>" + createdAt.Format("2006-01-02") + "</lastmod>"; !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("sitemap has no %s", want)
+	}
+
+	if rec := get(lastModified); rec.Code != http.StatusNotModified {
+		t.Errorf("If-Modified-Since the model's creation got %d, want 304", rec.Code)
+	}
+	if rec := get(createdAt.Add(-time.Hour).Format(http.TimeFormat)); rec.Code != http.StatusOK {
+		t.Errorf("If-Modified-Since before the model got %d, want 200", rec.Code)
+	}
+}
+
+func TestSitemapNotModifiedUntilRetrained(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	dated := &datedStore{PostStore: app.store, createdAt: time.Date(2024, 5, 1, 23, 30, 0, 0, time.UTC)}
+	app.store = dated
+
+	get := func(since string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/sitemap.xml", nil)
+		if since != "" {
+			req.Header.Set("If-Modified-Since", since)
+		}
+		rec := httptest.NewRecorder()
+		app.sitemapHandler(rec, req)
+		return rec
+	}
+
+	first := get("")
+	if first.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", first.Code)
+	}
+	lastModified := first.Header().Get("Last-Modified")
+	if rec := get(lastModified); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("refetching got status %d with %d bytes, want an empty 304", rec.Code, rec.Body.Len())
+	}
+
+	// A model trained after midnight changes the sitemap
+	dated.createdAt = time.Date(2024, 5, 2, 0, 30, 0, 0, time.UTC)
+	app.clearModelCache()
+	rec := get(lastModified)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("after a retrain the next day got status %d, want 200", rec.Code)
+	}
+	if got := rec.Header().Get("Last-Modified"); got != "Thu, 02 May 2024 00:30:00 GMT" {
+		t.Errorf("Last-Modified is %q after the retrain", got)
+	}
+	if !strings.Contains(rec.Body.String(), "<lastmod>2024-05-02</lastmod>") {
+		t.Error("the sitemap doesn't date its posts to the retrain")
+	}
+}
+
+func TestSitemapListsAuthors(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	old := train.AuthorWeights
+	t.Cleanup(func() { train.AuthorWeights = old })
+	train.AuthorWeights = map[string]int{"Arlo Mills": 0, "Guest Writer": 1}
+
+	rec := httptest.NewRecorder()
+	app.sitemapHandler(rec, httptest.NewRequest("GET", "http://example.com/sitemap.xml", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d", rec.Code)
+	}
+	var sitemap struct {
+		URLs []struct {
+			Loc string `xml:"loc"`
+		} `xml:"url"`
+	}
+	if err := xml.Unmarshal(rec.Body.Bytes(), &sitemap); err != nil {
+		t.Fatalf("the sitemap isn't valid XML: %v", err)
+	}
+	if len(sitemap.URLs) > 50000 {
+		t.Errorf("the sitemap has %d urls, more than a sitemap may list", len(sitemap.URLs))
+	}
+	entries := map[string]int{}
+	authors := 0
+	for _, url := range sitemap.URLs {
+		entries[url.Loc]++
+		if strings.HasPrefix(url.Loc, "http://example.com/author/") {
+			authors++
+		}
+	}
+	for _, author := range train.Authors() {
+		loc := "http://example.com/author/" + train.AuthorSlug(author)
+		if entries[loc] != 1 {
+			t.Errorf("the sitemap lists %s %d times", loc, entries[loc])
+		}
+	}
+	if authors != len(train.Authors()) || entries["http://example.com/author/arlo-mills"] != 0 {
+		t.Errorf("the sitemap lists %d author pages for %d authors", authors, len(train.Authors()))
+	}
+}
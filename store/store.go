@@ -2,14 +2,19 @@ package store
 
 import (
 	"database/sql"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// Post represents a blog post
+// Post represents a blog post. Published posts keep the seed and URL they
+// were generated for.
 type Post struct {
 	ID        int    `json:"id"`
+	Seed      int64  `json:"seed"`
+	Url       string `json:"url"`
 	Title     string `json:"title"`
+	Author    string `json:"author"`
 	Content   string `json:"content"`
 	CreatedAt string `json:"created_at"`
 }
@@ -25,10 +30,28 @@ type MarkovChainModel struct {
 type PostStore interface {
 
 	// Markov Chain Model operations
-	SaveMarkovChainModel(modelData []byte) (*MarkovChainModel, error)
+	SaveMarkovChainModel(modelData []byte, makeCurrent bool) (*MarkovChainModel, error)
 	GetMarkovChainModel(id int) (*MarkovChainModel, error)
 	GetAllMarkovChainModels(limit int) ([]MarkovChainModel, error)
 	UpdateMarkovChainModel(id int, modelData []byte) (*MarkovChainModel, error)
+	GetLatestMarkovChainModelID() (int, error)
+	CountMarkovChainModels() (int, error)
+	GetMarkovChainModelSize(id int) (int64, error)
+	GetCurrentMarkovChainModel() (*MarkovChainModel, error)
+	GetCurrentMarkovChainModelID() (int, error)
+
+	// Post operations
+	SavePost(post Post) (*Post, error)
+	SearchPosts(query string, limit int) ([]Post, error)
+	CountPosts() (int, error)
+	GetAllPosts(limit, offset int) ([]Post, error)
+
+	// Training job operations
+	EnqueueTrainingJob(corpus string, options []byte) (*TrainingJob, error)
+	GetTrainingJob(id int) (*TrainingJob, error)
+	ClaimTrainingJob() (*TrainingJob, error)
+	FinishTrainingJob(id int, modelID int, jobErr error) error
+	RequeueRunningTrainingJobs() error
 
 	// Database lifecycle
 	Close() error
@@ -38,6 +61,9 @@ type PostStore interface {
 // SQLiteStore implements PostStore using SQLite
 type SQLiteStore struct {
 	db *sql.DB
+
+	// keepModels is how many of the newest models survive a save, 0 keeps all
+	keepModels int
 }
 
 // NewSQLiteStore creates a new SQLite store instance
@@ -65,12 +91,51 @@ func (s *SQLiteStore) initDB() error {
     model_data TEXT NOT NULL,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
+CREATE TABLE IF NOT EXISTS current_model (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    model_id INTEGER NOT NULL REFERENCES markov_chain_model(id)
+);
+CREATE TABLE IF NOT EXISTS posts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    seed INTEGER NOT NULL UNIQUE,
+    url TEXT NOT NULL,
+    title TEXT NOT NULL,
+    author TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS training_jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    status TEXT NOT NULL DEFAULT 'queued',
+    corpus TEXT NOT NULL,
+    options TEXT NOT NULL,
+    model_id INTEGER,
+    error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE VIRTUAL TABLE IF NOT EXISTS posts_search USING fts4(title, body);
+CREATE TRIGGER IF NOT EXISTS posts_search_insert AFTER INSERT ON posts BEGIN
+    INSERT INTO posts_search (docid, title, body) VALUES (new.id, new.title, new.content);
+END;
+CREATE TRIGGER IF NOT EXISTS posts_search_update AFTER UPDATE ON posts BEGIN
+    UPDATE posts_search SET title = new.title, body = new.content WHERE docid = old.id;
+END;
+CREATE TRIGGER IF NOT EXISTS posts_search_delete AFTER DELETE ON posts BEGIN
+    DELETE FROM posts_search WHERE docid = old.id;
+END;
 `
 
 	_, err := s.db.Exec(string(schema))
 	return err
 }
 
+// SetModelRetention keeps only the n most recent models, deleting older ones
+// whenever a new model is saved. 0 keeps every model.
+func (s *SQLiteStore) SetModelRetention(n int) {
+	s.keepModels = n
+}
+
 // Close closes the database connection
 func (s *SQLiteStore) Close() error {
 	return s.db.Close()
@@ -81,9 +146,17 @@ func (s *SQLiteStore) Ping() error {
 	return s.db.Ping()
 }
 
-// SaveMarkovChainModel saves a markov chain model to the database
-func (s *SQLiteStore) SaveMarkovChainModel(modelData []byte) (*MarkovChainModel, error) {
-	result, err := s.db.Exec("INSERT INTO markov_chain_model (model_data) VALUES (?)", string(modelData))
+// SaveMarkovChainModel saves a markov chain model to the database. In the same
+// transaction it optionally makes the new model the current one, and trims
+// older models when a retention limit is set.
+func (s *SQLiteStore) SaveMarkovChainModel(modelData []byte, makeCurrent bool) (*MarkovChainModel, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO markov_chain_model (model_data) VALUES (?)", string(modelData))
 	if err != nil {
 		return nil, err
 	}
@@ -93,14 +166,33 @@ func (s *SQLiteStore) SaveMarkovChainModel(modelData []byte) (*MarkovChainModel,
 		return nil, err
 	}
 
+	if makeCurrent {
+		_, err = tx.Exec("INSERT INTO current_model (id, model_id) VALUES (1, ?) ON CONFLICT(id) DO UPDATE SET model_id = excluded.model_id", id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The current model is never trimmed, even when older models replaced it as newest
+	if s.keepModels > 0 {
+		_, err = tx.Exec("DELETE FROM markov_chain_model WHERE id NOT IN (SELECT id FROM markov_chain_model ORDER BY created_at DESC, id DESC LIMIT ?) AND id NOT IN (SELECT model_id FROM current_model)", s.keepModels)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Get the created model
 	var model MarkovChainModel
-	err = s.db.QueryRow("SELECT id, model_data, created_at FROM markov_chain_model WHERE id = ?", id).
+	err = tx.QueryRow("SELECT id, model_data, created_at FROM markov_chain_model WHERE id = ?", id).
 		Scan(&model.ID, &model.ModelData, &model.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
 	return &model, nil
 }
 
@@ -167,3 +259,155 @@ func (s *SQLiteStore) UpdateMarkovChainModel(id int, modelData []byte) (*MarkovC
 
 	return &model, nil
 }
+
+// GetLatestMarkovChainModelID returns the ID of the newest model without
+// loading its data, or 0 when there are no models
+func (s *SQLiteStore) GetLatestMarkovChainModelID() (int, error) {
+	var id int
+	err := s.db.QueryRow("SELECT id FROM markov_chain_model ORDER BY created_at DESC LIMIT 1").Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// CountMarkovChainModels returns how many models are stored without loading them
+func (s *SQLiteStore) CountMarkovChainModels() (int, error) {
+	var count int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM markov_chain_model").Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetMarkovChainModelSize returns the size in bytes of a stored model's data
+// without loading it, or sql.ErrNoRows if there is no such model
+func (s *SQLiteStore) GetMarkovChainModelSize(id int) (int64, error) {
+	// length() counts characters for text, so measure the data as a blob
+	var size int64
+	err := s.db.QueryRow("SELECT length(CAST(model_data AS BLOB)) FROM markov_chain_model WHERE id = ?", id).Scan(&size)
+	if err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// GetCurrentMarkovChainModelID returns the ID of the current model without
+// loading its data. Databases that predate the current pointer fall back to
+// the newest model. It returns 0 when there are no models.
+func (s *SQLiteStore) GetCurrentMarkovChainModelID() (int, error) {
+	var id int
+	err := s.db.QueryRow("SELECT model_id FROM current_model WHERE id = 1").Scan(&id)
+	if err == sql.ErrNoRows {
+		return s.GetLatestMarkovChainModelID()
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetCurrentMarkovChainModel retrieves the current model, returning
+// sql.ErrNoRows when there are no models
+func (s *SQLiteStore) GetCurrentMarkovChainModel() (*MarkovChainModel, error) {
+	id, err := s.GetCurrentMarkovChainModelID()
+	if err != nil {
+		return nil, err
+	}
+	if id == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return s.GetMarkovChainModel(id)
+}
+
+// SavePost saves a post, replacing the post saved earlier for the same seed.
+// Its title and content are indexed for SearchPosts.
+func (s *SQLiteStore) SavePost(post Post) (*Post, error) {
+	_, err := s.db.Exec(`INSERT INTO posts (seed, url, title, author, content) VALUES (?, ?, ?, ?, ?)
+ON CONFLICT(seed) DO UPDATE SET url = excluded.url, title = excluded.title, author = excluded.author, content = excluded.content`,
+		post.Seed, post.Url, post.Title, post.Author, post.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	var saved Post
+	err = s.db.QueryRow("SELECT id, seed, url, title, author, content, created_at FROM posts WHERE seed = ?", post.Seed).
+		Scan(&saved.ID, &saved.Seed, &saved.Url, &saved.Title, &saved.Author, &saved.Content, &saved.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// SearchPosts returns posts whose title or content contains every word of
+// query, newest first
+func (s *SQLiteStore) SearchPosts(query string, limit int) ([]Post, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return []Post{}, nil
+	}
+
+	rows, err := s.db.Query(`SELECT p.id, p.seed, p.url, p.title, p.author, p.content, p.created_at
+FROM posts_search JOIN posts p ON p.id = posts_search.docid
+WHERE posts_search MATCH ?
+ORDER BY p.created_at DESC, p.id DESC LIMIT ?`, match, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var post Post
+		if err := rows.Scan(&post.ID, &post.Seed, &post.Url, &post.Title, &post.Author, &post.Content, &post.CreatedAt); err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// CountPosts returns how many posts are stored without loading them
+func (s *SQLiteStore) CountPosts() (int, error) {
+	var count int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM posts").Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetAllPosts returns up to limit posts, newest first, skipping the newest
+// offset
+func (s *SQLiteStore) GetAllPosts(limit, offset int) ([]Post, error) {
+	rows, err := s.db.Query("SELECT id, seed, url, title, author, content, created_at FROM posts ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var post Post
+		if err := rows.Scan(&post.ID, &post.Seed, &post.Url, &post.Title, &post.Author, &post.Content, &post.CreatedAt); err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// ftsQuery quotes each word of a reader's query so full-text operators and
+// stray punctuation are matched literally
+func ftsQuery(query string) string {
+	terms := []string{}
+	for _, word := range strings.Fields(query) {
+		word = strings.ReplaceAll(word, `"`, "")
+		if word != "" {
+			terms = append(terms, `"`+word+`"`)
+		}
+	}
+	return strings.Join(terms, " ")
+}
@@ -0,0 +1,270 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// newTestStore opens a store on an empty database in a temporary directory
+func newTestStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// wantCount fails t unless count returns want
+func wantCount(t *testing.T, name string, count func() (int, error), want int) {
+	t.Helper()
+	got, err := count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("%s: got %d, want %d", name, got, want)
+	}
+}
+
+func TestCountMarkovChainModels(t *testing.T) {
+	s := newTestStore(t)
+	wantCount(t, "empty", s.CountMarkovChainModels, 0)
+
+	var ids []int
+	for i := 0; i < 3; i++ {
+		model, err := s.SaveMarkovChainModel([]byte(`{}`), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, model.ID)
+	}
+	wantCount(t, "after three saves", s.CountMarkovChainModels, 3)
+
+	if _, err := s.db.Exec("DELETE FROM markov_chain_model WHERE id = ?", ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	wantCount(t, "after a delete", s.CountMarkovChainModels, 2)
+}
+
+func TestCountPosts(t *testing.T) {
+	s := newTestStore(t)
+	wantCount(t, "empty", s.CountPosts, 0)
+
+	var ids []int
+	for i, title := range []string{"One", "Two", "Three"} {
+		post, err := s.SavePost(Post{Seed: int64(i + 1), Title: title, Content: "A story called " + title + "."})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, post.ID)
+	}
+	wantCount(t, "after three saves", s.CountPosts, 3)
+
+	if _, err := s.db.Exec("DELETE FROM posts WHERE id = ?", ids[1]); err != nil {
+		t.Fatal(err)
+	}
+	wantCount(t, "after a delete", s.CountPosts, 2)
+}
+
+func TestSearchPosts(t *testing.T) {
+	s := newTestStore(t)
+	posts := []Post{
+		{Seed: 1, Url: "/post/1-the-cat-sat", Title: "The cat sat", Author: "Arlo Mills", Content: "The cat sat on the mat."},
+		{Seed: 2, Url: "/post/2-a-bird-sang", Title: "A bird sang", Author: "Diana White", Content: "A zebra grazed quietly."},
+	}
+	for _, post := range posts {
+		if _, err := s.SavePost(post); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		seeds []int64
+	}{
+		{query: "zebra", seeds: []int64{2}},
+		{query: "CAT mat", seeds: []int64{1}},
+		{query: "cat zebra", seeds: nil},
+		{query: `"mat" OR`, seeds: nil},
+		{query: "  ", seeds: nil},
+	}
+	for _, tt := range tests {
+		found, err := s.SearchPosts(tt.query, 10)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		var seeds []int64
+		for _, post := range found {
+			seeds = append(seeds, post.Seed)
+		}
+		if len(seeds) != len(tt.seeds) || (len(seeds) > 0 && seeds[0] != tt.seeds[0]) {
+			t.Errorf("%q found seeds %v, want %v", tt.query, seeds, tt.seeds)
+		}
+	}
+
+	// Saving a seed again replaces its post and its index entry
+	if _, err := s.SavePost(Post{Seed: 2, Url: "/post/2-a-dog-ran", Title: "A dog ran", Content: "The dog ran home."}); err != nil {
+		t.Fatal(err)
+	}
+	wantCount(t, "after saving a seed again", s.CountPosts, 2)
+	if found, err := s.SearchPosts("zebra", 10); err != nil || len(found) != 0 {
+		t.Errorf("the replaced text is still found: %v, %v", found, err)
+	}
+	found, err := s.SearchPosts("dog", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].Url != "/post/2-a-dog-ran" {
+		t.Errorf("searching the new text found %v", found)
+	}
+}
+
+func TestGetAllPosts(t *testing.T) {
+	s := newTestStore(t)
+	for seed := int64(1); seed <= 3; seed++ {
+		if _, err := s.SavePost(Post{Seed: seed, Title: "Post", Content: "Text."}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		limit, offset int
+		seeds         []int64
+	}{
+		{limit: 10, offset: 0, seeds: []int64{3, 2, 1}},
+		{limit: 2, offset: 0, seeds: []int64{3, 2}},
+		{limit: 2, offset: 2, seeds: []int64{1}},
+		{limit: 2, offset: 3, seeds: nil},
+	}
+	for _, tt := range tests {
+		posts, err := s.GetAllPosts(tt.limit, tt.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var seeds []int64
+		for _, post := range posts {
+			seeds = append(seeds, post.Seed)
+		}
+		if fmt.Sprint(seeds) != fmt.Sprint(tt.seeds) {
+			t.Errorf("limit %d offset %d: got seeds %v, want %v", tt.limit, tt.offset, seeds, tt.seeds)
+		}
+	}
+}
+
+// currentID returns the ID of the current model
+func currentID(t *testing.T, s *SQLiteStore) int {
+	t.Helper()
+	id, err := s.GetCurrentMarkovChainModelID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestSaveMarkovChainModelCurrent(t *testing.T) {
+	s := newTestStore(t)
+	first, err := s.SaveMarkovChainModel([]byte(`{"n":1}`), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := currentID(t, s); got != first.ID {
+		t.Fatalf("current model is %d, want the saved %d", got, first.ID)
+	}
+
+	// Saving without making it current leaves the pointer alone
+	if _, err := s.SaveMarkovChainModel([]byte(`{"n":2}`), false); err != nil {
+		t.Fatal(err)
+	}
+	if got := currentID(t, s); got != first.ID {
+		t.Errorf("current model moved to %d on a save that isn't current, want %d", got, first.ID)
+	}
+
+	third, err := s.SaveMarkovChainModel([]byte(`{"n":3}`), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := s.GetCurrentMarkovChainModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.ID != third.ID || current.ModelData != `{"n":3}` {
+		t.Errorf("current model is %d %s, want %d", current.ID, current.ModelData, third.ID)
+	}
+
+	// When moving the pointer fails the new model isn't saved either
+	if _, err := s.db.Exec(`CREATE TRIGGER fail_current BEFORE UPDATE ON current_model BEGIN SELECT RAISE(ABORT, 'pointer update failed'); END`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SaveMarkovChainModel([]byte(`{"n":4}`), true); err == nil {
+		t.Fatal("the save succeeded although the pointer couldn't move")
+	}
+	if got := currentID(t, s); got != third.ID {
+		t.Errorf("current model is %d after a failed save, want %d", got, third.ID)
+	}
+	wantCount(t, "after a failed save", s.CountMarkovChainModels, 3)
+}
+
+func TestModelRetention(t *testing.T) {
+	const keep = 2
+	s := newTestStore(t)
+	s.SetModelRetention(keep)
+
+	var ids []int
+	for i := 0; i < keep+2; i++ {
+		model, err := s.SaveMarkovChainModel([]byte(`{}`), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, model.ID)
+	}
+	wantCount(t, "after saving two more than are kept", s.CountMarkovChainModels, keep)
+	for i, id := range ids {
+		_, err := s.GetMarkovChainModel(id)
+		if kept := err == nil; kept != (i >= len(ids)-keep) {
+			t.Errorf("model %d kept %v", id, kept)
+		}
+	}
+
+	// A current model older than the kept ones survives the trimming
+	current := ids[len(ids)-1]
+	for i := 0; i < keep+2; i++ {
+		if _, err := s.SaveMarkovChainModel([]byte(`{}`), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.GetMarkovChainModel(current); err != nil {
+		t.Errorf("the current model %d was deleted: %v", current, err)
+	}
+	wantCount(t, "with an older current model", s.CountMarkovChainModels, keep+1)
+}
+
+func TestGetMarkovChainModelSize(t *testing.T) {
+	s := newTestStore(t)
+	// Multibyte text makes the size in bytes differ from the length in characters
+	for _, data := range []string{`{}`, `{"word":"café ☕"}`} {
+		model, err := s.SaveMarkovChainModel([]byte(data), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, err := s.GetMarkovChainModelSize(model.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored, err := s.GetMarkovChainModel(model.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != int64(len(stored.ModelData)) || size != int64(len(data)) {
+			t.Errorf("model %d is %d bytes, want the stored %d", model.ID, size, len(stored.ModelData))
+		}
+	}
+
+	if _, err := s.GetMarkovChainModelSize(1000); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("a missing model got error %v, want %v", err, sql.ErrNoRows)
+	}
+}
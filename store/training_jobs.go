@@ -0,0 +1,103 @@
+package store
+
+import "database/sql"
+
+// Training job statuses, in the order a job moves through them
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// TrainingJob is a corpus waiting to be trained, or the outcome of training
+// it. Corpus and Options are only loaded for the worker that claims the job.
+type TrainingJob struct {
+	ID        int    `json:"id"`
+	Status    string `json:"status"`
+	Corpus    string `json:"-"`
+	Options   string `json:"-"`
+	ModelID   *int   `json:"model_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// EnqueueTrainingJob saves corpus and its serialized training options as a
+// queued job
+func (s *SQLiteStore) EnqueueTrainingJob(corpus string, options []byte) (*TrainingJob, error) {
+	result, err := s.db.Exec("INSERT INTO training_jobs (corpus, options) VALUES (?, ?)", corpus, string(options))
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return s.GetTrainingJob(int(id))
+}
+
+// GetTrainingJob retrieves a training job's status by ID, without its corpus
+func (s *SQLiteStore) GetTrainingJob(id int) (*TrainingJob, error) {
+	var job TrainingJob
+	var modelID sql.NullInt64
+	err := s.db.QueryRow("SELECT id, status, model_id, error, created_at, updated_at FROM training_jobs WHERE id = ?", id).
+		Scan(&job.ID, &job.Status, &modelID, &job.Error, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if modelID.Valid {
+		model := int(modelID.Int64)
+		job.ModelID = &model
+	}
+	return &job, nil
+}
+
+// ClaimTrainingJob marks the oldest queued job as running and returns it with
+// its corpus and options. It returns nil when no job is queued.
+func (s *SQLiteStore) ClaimTrainingJob() (*TrainingJob, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var job TrainingJob
+	err = tx.QueryRow("SELECT id, corpus, options, created_at FROM training_jobs WHERE status = ? ORDER BY id LIMIT 1", JobQueued).
+		Scan(&job.ID, &job.Corpus, &job.Options, &job.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("UPDATE training_jobs SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", JobRunning, job.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	job.Status = JobRunning
+	return &job, nil
+}
+
+// FinishTrainingJob records the model a job produced, or jobErr when it
+// failed, and drops its corpus, which is no longer needed
+func (s *SQLiteStore) FinishTrainingJob(id int, modelID int, jobErr error) error {
+	if jobErr != nil {
+		_, err := s.db.Exec("UPDATE training_jobs SET status = ?, error = ?, corpus = '', updated_at = CURRENT_TIMESTAMP WHERE id = ?", JobFailed, jobErr.Error(), id)
+		return err
+	}
+	_, err := s.db.Exec("UPDATE training_jobs SET status = ?, model_id = ?, corpus = '', updated_at = CURRENT_TIMESTAMP WHERE id = ?", JobDone, modelID, id)
+	return err
+}
+
+// RequeueRunningTrainingJobs puts jobs that were running when the process
+// stopped back in the queue
+func (s *SQLiteStore) RequeueRunningTrainingJobs() error {
+	_, err := s.db.Exec("UPDATE training_jobs SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE status = ?", JobQueued, JobRunning)
+	return err
+}
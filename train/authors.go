@@ -0,0 +1,155 @@
+package train
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var authors = []string{
+	"Arlo Mills",
+	"Joe Goetz",
+	"Billy Goetz",
+	"Marybeth Trott",
+	"Charlie Davis",
+	"Diana White",
+	"Ethan Young",
+}
+
+// AuthorWeights makes some authors more likely than others. Authors missing
+// from the map have weight 1, a weight of 0 retires an author, and names not
+// in the built-in list are added. Nil credits every author equally.
+var AuthorWeights map[string]int
+
+// weightedAuthor is an author and their share of posts
+type weightedAuthor struct {
+	name   string
+	weight int
+}
+
+// authorPool returns the authors that can be credited with posts, in a stable
+// order, with their weights
+func authorPool() []weightedAuthor {
+	pool := []weightedAuthor{}
+	for _, author := range authors {
+		weight, ok := AuthorWeights[author]
+		if !ok {
+			weight = 1
+		}
+		if weight > 0 {
+			pool = append(pool, weightedAuthor{name: author, weight: weight})
+		}
+	}
+	extra := []string{}
+	for author, weight := range AuthorWeights {
+		if weight > 0 && !slices.Contains(authors, author) {
+			extra = append(extra, author)
+		}
+	}
+	sort.Strings(extra)
+	for _, author := range extra {
+		pool = append(pool, weightedAuthor{name: author, weight: AuthorWeights[author]})
+	}
+	return pool
+}
+
+// Authors returns the names of every author that can be credited with posts
+func Authors() []string {
+	names := []string{}
+	for _, author := range authorPool() {
+		names = append(names, author.name)
+	}
+	return names
+}
+
+// AuthorSlug returns the url slug of an author's profile
+func AuthorSlug(author string) string {
+	return Slugify(author)
+}
+
+// AuthorBySlug returns the author whose profile slug is slug
+func AuthorBySlug(slug string) (string, bool) {
+	for _, author := range authorPool() {
+		if AuthorSlug(author.name) == slug {
+			return author.name, true
+		}
+	}
+	return "", false
+}
+
+// authorSeed derives a non-negative seed from an author's name
+func authorSeed(author string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(author))
+	return int64(hash.Sum64() & math.MaxInt64)
+}
+
+// GenerateAuthorBio generates sentences of biography for author. The same
+// author and model always give the same bio.
+func GenerateAuthorBio(chain MarkovChain, author string, sentences int) (string, error) {
+	prng := rand.New(rand.NewSource(authorSeed(author)))
+	bio := []string{}
+	for i := 0; i < sentences; i++ {
+		sentence, err := GenerateStoryFromPrng(prng, chain)
+		if err != nil {
+			return "", err
+		}
+		bio = append(bio, sentence)
+	}
+	return strings.Join(bio, " "), nil
+}
+
+// AuthorForSeed returns the author credited with the post for seed, chosen
+// in proportion to AuthorWeights. It depends only on the seed and the weights,
+// so the author of any post can be found without generating it.
+func AuthorForSeed(seed int64) string {
+	if AuthorWeights == nil {
+		return authors[mixSeed(seed)%uint64(len(authors))]
+	}
+	pool := authorPool()
+	total := 0
+	for _, author := range pool {
+		total += author.weight
+	}
+	if total == 0 {
+		return authors[mixSeed(seed)%uint64(len(authors))]
+	}
+	pick := int(mixSeed(seed) % uint64(total))
+	for _, author := range pool {
+		pick -= author.weight
+		if pick < 0 {
+			return author.name
+		}
+	}
+	return pool[len(pool)-1].name
+}
+
+// mixSeed scrambles seed with the splitmix64 finalizer so neighbouring seeds
+// don't pick neighbouring authors
+func mixSeed(seed int64) uint64 {
+	z := uint64(seed) + 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// authorScanLimit bounds how many seeds SeedsForAuthor tries per seed wanted
+const authorScanLimit = 50
+
+// SeedsForAuthor returns up to n seeds whose posts AuthorForSeed credits to
+// author, scanning from a seed derived from the author's name so the list is
+// stable
+func SeedsForAuthor(author string, n int) []int64 {
+	seeds := []int64{}
+	seed := authorSeed(author)
+	for tries := 0; len(seeds) < n && tries < n*authorScanLimit; tries++ {
+		if AuthorForSeed(seed) == author {
+			seeds = append(seeds, seed)
+		}
+		seed = (seed + 1) & math.MaxInt64
+	}
+	return seeds
+}
@@ -0,0 +1,112 @@
+package train
+
+import (
+	"slices"
+	"testing"
+)
+
+// withAuthorWeights sets AuthorWeights for the rest of the test
+func withAuthorWeights(t *testing.T, weights map[string]int) {
+	t.Helper()
+	old := AuthorWeights
+	AuthorWeights = weights
+	t.Cleanup(func() { AuthorWeights = old })
+}
+
+func TestAuthorForSeedIsStable(t *testing.T) {
+	withAuthorWeights(t, nil)
+	// Changing these moves every existing post to another author
+	tests := []struct {
+		seed int64
+		want string
+	}{
+		{0, "Billy Goetz"},
+		{1, "Billy Goetz"},
+		{2, "Charlie Davis"},
+		{42, "Diana White"},
+		{1 << 62, "Charlie Davis"},
+	}
+	for _, tt := range tests {
+		if got := AuthorForSeed(tt.seed); got != tt.want {
+			t.Errorf("AuthorForSeed(%d) = %q, want %q", tt.seed, got, tt.want)
+		}
+	}
+
+	chain, err := BuildModel("The cat sat. The dog ran.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seed := int64(0); seed < 20; seed++ {
+		page, err := GeneratePage(seed, chain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Author != AuthorForSeed(seed) {
+			t.Errorf("post %d is by %q, but AuthorForSeed gives %q", seed, page.Author, AuthorForSeed(seed))
+		}
+	}
+}
+
+func TestSeedsForAuthor(t *testing.T) {
+	withAuthorWeights(t, map[string]int{"Arlo Mills": 0, "Guest Writer": 2})
+	for _, author := range Authors() {
+		seeds := SeedsForAuthor(author, 10)
+		if len(seeds) != 10 {
+			t.Errorf("%s has %d seeds, want 10", author, len(seeds))
+		}
+		for i, seed := range seeds {
+			if seed < 0 || AuthorForSeed(seed) != author || slices.Contains(seeds[:i], seed) {
+				t.Errorf("%s got seed %d, which is by %s", author, seed, AuthorForSeed(seed))
+			}
+		}
+		if again := SeedsForAuthor(author, 10); !slices.Equal(again, seeds) {
+			t.Errorf("%s got seeds %v, then %v", author, seeds, again)
+		}
+		if fewer := SeedsForAuthor(author, 3); !slices.Equal(fewer, seeds[:3]) {
+			t.Errorf("%s got first seeds %v, want %v", author, fewer, seeds[:3])
+		}
+	}
+	// Retired and unknown authors have no posts
+	for _, author := range []string{"Arlo Mills", "Nobody"} {
+		if seeds := SeedsForAuthor(author, 5); len(seeds) != 0 {
+			t.Errorf("%s got seeds %v", author, seeds)
+		}
+	}
+}
+
+func TestAuthorWeights(t *testing.T) {
+	const seeds = 70000
+	tests := []struct {
+		name    string
+		weights map[string]int
+		// want is each author's expected share of posts
+		want map[string]float64
+	}{
+		{name: "uniform", want: map[string]float64{
+			"Arlo Mills": 1.0 / 7, "Joe Goetz": 1.0 / 7, "Billy Goetz": 1.0 / 7, "Marybeth Trott": 1.0 / 7,
+			"Charlie Davis": 1.0 / 7, "Diana White": 1.0 / 7, "Ethan Young": 1.0 / 7,
+		}},
+		{name: "featured and retired", weights: map[string]int{"Arlo Mills": 4, "Joe Goetz": 0, "Billy Goetz": 0, "Guest Writer": 2}, want: map[string]float64{
+			"Arlo Mills": 4.0 / 10, "Marybeth Trott": 1.0 / 10, "Charlie Davis": 1.0 / 10,
+			"Diana White": 1.0 / 10, "Ethan Young": 1.0 / 10, "Guest Writer": 2.0 / 10,
+		}},
+	}
+	for _, tt := range tests {
+		withAuthorWeights(t, tt.weights)
+		counts := map[string]int{}
+		for seed := int64(0); seed < seeds; seed++ {
+			counts[AuthorForSeed(seed)]++
+		}
+		for author, count := range counts {
+			if _, ok := tt.want[author]; !ok {
+				t.Errorf("%s: %s wrote %d posts", tt.name, author, count)
+			}
+		}
+		for author, share := range tt.want {
+			// Within 10% of the expected share
+			if got := float64(counts[author]) / seeds; got < share*0.9 || got > share*1.1 {
+				t.Errorf("%s: %s wrote %.3f of posts, want %.3f", tt.name, author, got, share)
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package train
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mb-14/gomarkov"
+)
+
+// ChainBackend is the transition model behind a MarkovChain. The default is a
+// gomarkov chain; other generators can be plugged in with NewMarkovChain.
+// Backends that also implement json.Marshaler can be saved with SerializeModel.
+type ChainBackend interface {
+	// Add records the transitions of one tokenized sentence
+	Add(tokens []string)
+
+	// Generate draws the token following current from a shared random source
+	Generate(current gomarkov.NGram) (string, error)
+
+	// GenerateDeterministic draws the token following current using prng
+	GenerateDeterministic(current gomarkov.NGram, prng gomarkov.PRNG) (string, error)
+
+	// Order is the number of tokens each state is keyed on
+	Order() int
+}
+
+// gomarkovBackend adapts *gomarkov.Chain to ChainBackend
+type gomarkovBackend struct {
+	chain *gomarkov.Chain
+}
+
+func newGomarkovBackend(order int) gomarkovBackend {
+	return gomarkovBackend{chain: gomarkov.NewChain(order)}
+}
+
+func (b gomarkovBackend) Add(tokens []string) {
+	b.chain.Add(tokens)
+}
+
+func (b gomarkovBackend) Generate(current gomarkov.NGram) (string, error) {
+	return b.chain.Generate(current)
+}
+
+func (b gomarkovBackend) GenerateDeterministic(current gomarkov.NGram, prng gomarkov.PRNG) (string, error) {
+	return b.chain.GenerateDeterministic(current, prng)
+}
+
+func (b gomarkovBackend) Order() int {
+	return b.chain.Order
+}
+
+func (b gomarkovBackend) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.chain)
+}
+
+// unmarshalGomarkovBackend loads a serialized gomarkov chain
+func unmarshalGomarkovBackend(data []byte) (gomarkovBackend, error) {
+	var chain gomarkov.Chain
+	if err := json.Unmarshal(data, &chain); err != nil {
+		return gomarkovBackend{}, err
+	}
+	return gomarkovBackend{chain: &chain}, nil
+}
+
+// marshalBackend serializes a backend that implements json.Marshaler
+func marshalBackend(backend ChainBackend) ([]byte, error) {
+	marshaler, ok := backend.(json.Marshaler)
+	if !ok {
+		return nil, fmt.Errorf("model backend %T can't be serialized", backend)
+	}
+	return marshaler.MarshalJSON()
+}
+
+// NewMarkovChain wraps backend in a MarkovChain using the default sentinels.
+// titles, which may be nil, is used for post titles instead of backend.
+func NewMarkovChain(backend, titles ChainBackend, opts TrainOptions) MarkovChain {
+	return MarkovChain{
+		chain:      backend,
+		titles:     titles,
+		sentinels:  DefaultSentinels,
+		lowercase:  opts.Lowercase,
+		paragraphs: opts.Paragraphs,
+		headings:   opts.StripHeadings,
+		normalize:  opts.Normalize,
+	}
+}
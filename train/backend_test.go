@@ -0,0 +1,73 @@
+package train
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/mb-14/gomarkov"
+)
+
+// scriptedBackend is a ChainBackend that only knows the last sentence it was
+// given, and continues it word for word
+type scriptedBackend struct {
+	next map[string]string
+}
+
+func (b scriptedBackend) Add(tokens []string) {
+	clear(b.next)
+	current := DefaultSentinels.Start
+	for _, token := range append(tokens, DefaultSentinels.End) {
+		b.next[current] = token
+		current = token
+	}
+}
+
+func (b scriptedBackend) Generate(current gomarkov.NGram) (string, error) {
+	return b.GenerateDeterministic(current, rand.New(rand.NewSource(1)))
+}
+
+func (b scriptedBackend) GenerateDeterministic(current gomarkov.NGram, prng gomarkov.PRNG) (string, error) {
+	next, ok := b.next[current[len(current)-1]]
+	if !ok {
+		return "", errors.New("unknown state")
+	}
+	return next, nil
+}
+
+func (b scriptedBackend) Order() int {
+	return 1
+}
+
+func TestCustomBackend(t *testing.T) {
+	backend := scriptedBackend{next: map[string]string{}}
+	titles := scriptedBackend{next: map[string]string{}}
+	chain := NewMarkovChain(backend, titles, TrainOptions{Titles: true})
+	if err := AddTextToModel(chain, "Ignored title. Also ignored.\n\nThe only title. The only sentence."); err != nil {
+		t.Fatal(err)
+	}
+
+	for seed := int64(1); seed <= 5; seed++ {
+		page, err := GeneratePage(seed, chain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Link.Title != "The only title." || !strings.HasPrefix(page.Link.Url, "/post/") {
+			t.Errorf("seed %d got link %+v", seed, page.Link)
+		}
+		for _, sentence := range splitSentences(strings.Fields(page.Content)) {
+			if got := strings.Join(sentence, " "); got != "The only sentence." {
+				t.Errorf("seed %d generated %q", seed, got)
+			}
+		}
+	}
+	if got, err := GenerateStoryFromPrefix(rand.New(rand.NewSource(1)), chain, "The only"); err != nil || got != "The only sentence." {
+		t.Errorf("GenerateStoryFromPrefix got %q, %v", got, err)
+	}
+
+	// Only backends that marshal themselves can be saved
+	if _, err := SerializeModel(chain); err == nil {
+		t.Error("a backend without MarshalJSON was serialized")
+	}
+}
@@ -0,0 +1,49 @@
+package train
+
+import (
+	"errors"
+	"math/rand"
+
+	"github.com/mb-14/gomarkov"
+)
+
+var (
+	// ErrEmptyModel means the model has no sentences to generate from
+	ErrEmptyModel = errors.New("model is empty")
+
+	// ErrCorruptModel means serialized model data could not be loaded
+	ErrCorruptModel = errors.New("model is corrupt")
+
+	// ErrUnsupportedModelFormat means a model was saved in a format newer
+	// than this build can read
+	ErrUnsupportedModelFormat = errors.New("model format is not supported")
+
+	// ErrUnsupportedOrder means an operation was given a chain of an order
+	// it can't handle, such as pruning an imported higher order chain
+	ErrUnsupportedOrder = errors.New("chain order is not supported")
+
+	// ErrGenerationCapExceeded means a generation ran past MaxStoryTokens
+	// without reaching the end token
+	ErrGenerationCapExceeded = errors.New("generation exceeded token cap")
+
+	// ErrDeadEndState means generation reached a state with no way to continue
+	ErrDeadEndState = errors.New("generation reached a dead-end state")
+)
+
+// guardedPRNG keeps gomarkov from panicking on states without transitions,
+// where it asks for a random number in an empty range
+type guardedPRNG struct {
+	prng gomarkov.PRNG
+}
+
+func (g guardedPRNG) Intn(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	return g.prng.Intn(n)
+}
+
+// globalPRNG draws from the math/rand package source
+type globalPRNG struct{}
+
+func (globalPRNG) Intn(n int) int { return rand.Intn(n) }
@@ -0,0 +1,86 @@
+package train
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/mb-14/gomarkov"
+)
+
+// deadEndModel returns a model whose only sentence runs into a state with no
+// transitions, since gomarkov ends it with its own end token rather than the
+// model's
+func deadEndModel(t *testing.T) MarkovChain {
+	t.Helper()
+	sentinels := Sentinels{Start: "<s>", End: "</s>"}
+	chain := gomarkov.NewChain(1)
+	chain.Add([]string{sentinels.Start, "Stuck."})
+	chain.Add([]string{"Elsewhere.", sentinels.End})
+	data, err := json.Marshal(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := LoadImportedModel(data, sentinels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model
+}
+
+func TestGenerationErrors(t *testing.T) {
+	empty, err := BuildModel("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	greedy := GenerateOptions{Generator: GreedyGenerator{}}
+	tests := []struct {
+		name  string
+		chain MarkovChain
+		opts  GenerateOptions
+		want  error
+	}{
+		{name: "empty model", chain: empty, want: ErrEmptyModel},
+		{name: "empty model, greedy", chain: empty, opts: greedy, want: ErrEmptyModel},
+		{name: "dead end", chain: deadEndModel(t), want: ErrDeadEndState},
+		{name: "dead end, greedy", chain: deadEndModel(t), opts: greedy, want: ErrDeadEndState},
+		{name: "runaway", chain: runawayModel(t, "Hi"), want: ErrGenerationCapExceeded},
+	}
+	for _, tt := range tests {
+		_, err := GeneratePageWithOptions(1, tt.chain, tt.opts)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+		for _, other := range []error{ErrEmptyModel, ErrDeadEndState, ErrGenerationCapExceeded, ErrCorruptModel} {
+			if other != tt.want && errors.Is(err, other) {
+				t.Errorf("%s: %v is also %v", tt.name, err, other)
+			}
+		}
+	}
+}
+
+func TestLoadModelErrors(t *testing.T) {
+	empty, err := BuildModel("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	emptyData, err := SerializeModel(empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data string
+		want error
+	}{
+		{name: "empty", data: string(emptyData), want: ErrEmptyModel},
+		{name: "not json", data: "not json", want: ErrCorruptModel},
+		{name: "no chain", data: `{"format":1}`, want: ErrCorruptModel},
+		{name: "future format", data: `{"format":999999,"chain":{}}`, want: ErrUnsupportedModelFormat},
+	}
+	for _, tt := range tests {
+		if _, err := LoadModel([]byte(tt.data)); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
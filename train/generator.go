@@ -0,0 +1,171 @@
+package train
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/mb-14/gomarkov"
+)
+
+// Generator draws one sentence from a chain. Implementations decide how each
+// next token is chosen; opts supplies the Prefix and MaxSentenceWords.
+type Generator interface {
+	Generate(chain MarkovChain, prng *rand.Rand, opts GenerateOptions) (string, error)
+}
+
+// tokenPicker chooses the token that follows tokens. The built-in generators
+// implement it to drive the shared sentence loop in continueTokens.
+type tokenPicker interface {
+	pickToken(chain MarkovChain, tokens []string, prng gomarkov.PRNG) (string, error)
+}
+
+// MarkovGenerator samples each next token in proportion to how often it
+// followed the current state in the corpus. It is the default.
+type MarkovGenerator struct{}
+
+func (g MarkovGenerator) Generate(chain MarkovChain, prng *rand.Rand, opts GenerateOptions) (string, error) {
+	tokens, err := generateTokensFromPrefix(g, prng, chain, chain.Sentinels(), opts.Prefix, nil, opts.MaxSentenceWords)
+	if err != nil {
+		return "", err
+	}
+	return chain.finishSentence(tokens), nil
+}
+
+func (MarkovGenerator) pickToken(chain MarkovChain, tokens []string, prng gomarkov.PRNG) (string, error) {
+	return chain.nextToken(tokens, prng)
+}
+
+// GreedySentenceWords caps GreedyGenerator sentences when MaxSentenceWords
+// doesn't. A chain whose most frequent tokens form a cycle would otherwise
+// never reach the end token.
+var GreedySentenceWords = 30
+
+// GreedyGenerator always picks the most frequent next token, preferring the
+// one seen first on ties. Its output ignores prng, so every sentence from a
+// state is the same. Sentences are cut at MaxSentenceWords, or at
+// GreedySentenceWords when that is unset.
+type GreedyGenerator struct{}
+
+func (g GreedyGenerator) Generate(chain MarkovChain, prng *rand.Rand, opts GenerateOptions) (string, error) {
+	maxWords := opts.MaxSentenceWords
+	if maxWords <= 0 {
+		maxWords = GreedySentenceWords
+	}
+	tokens, err := generateTokensFromPrefix(g, prng, chain, chain.Sentinels(), opts.Prefix, nil, maxWords)
+	if err != nil {
+		return "", err
+	}
+	return chain.finishSentence(tokens), nil
+}
+
+func (GreedyGenerator) pickToken(chain MarkovChain, tokens []string, prng gomarkov.PRNG) (string, error) {
+	return chain.mostLikelyToken(tokens)
+}
+
+// DefaultGenerator is the Generator for page bodies unless
+// GenerateOptions.Generator overrides it. Titles are always drawn by
+// MarkovGenerator.
+var DefaultGenerator Generator = MarkovGenerator{}
+
+// GeneratorByName returns the Generator called name: "markov" or "greedy"
+func GeneratorByName(name string) (Generator, error) {
+	switch strings.ToLower(name) {
+	case "markov":
+		return MarkovGenerator{}, nil
+	case "greedy":
+		return GreedyGenerator{}, nil
+	}
+	return nil, fmt.Errorf("unknown generator %q: want markov or greedy", name)
+}
+
+// mostLikelyToken is pickToken for GreedyGenerator
+func (m MarkovChain) mostLikelyToken(tokens []string) (string, error) {
+	if m.bigrams != nil {
+		previous := m.Sentinels().Start
+		if len(tokens) > 1 {
+			previous = tokens[len(tokens)-2]
+		}
+		if table, err := greedyTable(m.bigrams); err == nil {
+			if next := table[previous+"_"+tokens[len(tokens)-1]]; next != "" {
+				return next, nil
+			}
+		}
+	}
+
+	table, err := greedyTable(m.chain)
+	if err != nil {
+		return "", err
+	}
+	current := tokens[len(tokens)-1]
+	next, ok := table[current]
+	if !ok || next == "" {
+		if current == m.Sentinels().Start {
+			return "", countFailure(fmt.Errorf("%w: no transitions from %q", ErrEmptyModel, current))
+		}
+		return "", countFailure(fmt.Errorf("%w: no transitions from %q", ErrDeadEndState, current))
+	}
+	return next, nil
+}
+
+// maxGreedyTables bounds the cache of greedy tables, which gains an entry per
+// chain backend generated from and is cleared when full
+const maxGreedyTables = 8
+
+var greedyTables = struct {
+	sync.Mutex
+	tables map[ChainBackend]map[string]string
+}{tables: map[ChainBackend]map[string]string{}}
+
+// greedyTable maps each state of backend, keyed as gomarkov keys them, to its
+// most frequent next token
+func greedyTable(backend ChainBackend) (map[string]string, error) {
+	cacheable := reflect.TypeOf(backend).Comparable()
+	if cacheable {
+		greedyTables.Lock()
+		table, ok := greedyTables.tables[backend]
+		greedyTables.Unlock()
+		if ok {
+			return table, nil
+		}
+	}
+
+	raw, err := marshalBackend(backend)
+	if err != nil {
+		return nil, err
+	}
+	var data chainData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+
+	tokens := make(map[int]string, len(data.SpoolMap))
+	for token, index := range data.SpoolMap {
+		tokens[index] = token
+	}
+	table := make(map[string]string, len(data.FreqMat))
+	for state, transitions := range data.FreqMat {
+		best, bestCount := -1, 0
+		for next, count := range transitions {
+			if count > bestCount || (count == bestCount && next < best) {
+				best, bestCount = next, count
+			}
+		}
+		if best >= 0 {
+			table[tokens[state]] = tokens[best]
+		}
+	}
+
+	if cacheable {
+		greedyTables.Lock()
+		if len(greedyTables.tables) >= maxGreedyTables {
+			clear(greedyTables.tables)
+		}
+		greedyTables.tables[backend] = table
+		greedyTables.Unlock()
+	}
+	return table, nil
+}
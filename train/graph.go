@@ -0,0 +1,81 @@
+package train
+
+import (
+	"sort"
+	"strings"
+)
+
+// GraphEdge is a transition from a state to the next token
+type GraphEdge struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Weight int    `json:"weight"`
+}
+
+// ModelGraph is the transition graph of a model
+type ModelGraph struct {
+	Nodes []string    `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// Graph returns the limit most frequent transitions of the model's body chain,
+// and of its bigram chain when it was trained with backoff, and the states
+// and tokens they connect. States of higher order chains are their tokens
+// joined by spaces.
+func Graph(chain MarkovChain, limit int) (ModelGraph, error) {
+	data, err := exportChain(chain)
+	if err != nil {
+		return ModelGraph{}, err
+	}
+	edges := graphEdges(data)
+	if chain.bigrams != nil {
+		bigrams, err := exportBackend(chain.bigrams)
+		if err != nil {
+			return ModelGraph{}, err
+		}
+		edges = append(edges, graphEdges(bigrams)...)
+	}
+
+	// Heaviest first, with ties broken by name so the result is stable
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Weight != edges[j].Weight {
+			return edges[i].Weight > edges[j].Weight
+		}
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	if limit > 0 && len(edges) > limit {
+		edges = edges[:limit]
+	}
+
+	graph := ModelGraph{Nodes: []string{}, Edges: edges}
+	seen := map[string]bool{}
+	for _, edge := range edges {
+		for _, node := range []string{edge.From, edge.To} {
+			if !seen[node] {
+				seen[node] = true
+				graph.Nodes = append(graph.Nodes, node)
+			}
+		}
+	}
+	return graph, nil
+}
+
+// graphEdges lists every transition of data
+func graphEdges(data chainData) []GraphEdge {
+	tokens := make(map[int]string, len(data.SpoolMap))
+	for token, index := range data.SpoolMap {
+		tokens[index] = token
+	}
+
+	edges := []GraphEdge{}
+	for state, transitions := range data.FreqMat {
+		from := strings.ReplaceAll(tokens[state], "_", " ")
+		for next, count := range transitions {
+			edges = append(edges, GraphEdge{From: from, To: tokens[next], Weight: count})
+		}
+	}
+	return edges
+}
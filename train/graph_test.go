@@ -0,0 +1,62 @@
+package train
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGraph(t *testing.T) {
+	input := "The cat sat. The dog sat."
+	plain, err := BuildModel(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backoff, err := BuildModelWithOptions(input, TrainOptions{Backoff: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		chain MarkovChain
+		limit int
+		nodes int
+		edges int
+	}{
+		// ^ The cat dog sat. $, with The leading to both animals
+		{name: "whole", chain: plain, nodes: 6, edges: 6},
+		{name: "limit above size", chain: plain, limit: 100, nodes: 6, edges: 6},
+		// Only ^ -> The and sat. -> $ happen twice
+		{name: "heaviest", chain: plain, limit: 2, nodes: 4, edges: 2},
+		// The bigram chain adds states for ^ ^, ^ The, The cat, The dog,
+		// cat sat., dog sat. and sat. $, since gomarkov ends each sentence
+		// with one end token per word of the state, and their eight transitions
+		{name: "backoff", chain: backoff, nodes: 13, edges: 14},
+	}
+	for _, tt := range tests {
+		graph, err := Graph(tt.chain, tt.limit)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(graph.Nodes) != tt.nodes || len(graph.Edges) != tt.edges {
+			t.Errorf("%s: got %d nodes and %d edges, want %d and %d: %+v", tt.name, len(graph.Nodes), len(graph.Edges), tt.nodes, tt.edges, graph)
+		}
+		// Every edge connects listed nodes, heaviest first
+		for i, edge := range graph.Edges {
+			if !slices.Contains(graph.Nodes, edge.From) || !slices.Contains(graph.Nodes, edge.To) {
+				t.Errorf("%s: edge %+v connects unlisted nodes", tt.name, edge)
+			}
+			if i > 0 && edge.Weight > graph.Edges[i-1].Weight {
+				t.Errorf("%s: edge %+v follows a lighter one", tt.name, edge)
+			}
+		}
+	}
+
+	graph, err := Graph(plain, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []GraphEdge{{From: "^", To: "The", Weight: 2}, {From: "sat.", To: "$", Weight: 2}}
+	if !slices.Equal(graph.Edges, want) {
+		t.Errorf("got heaviest edges %+v, want %+v", graph.Edges, want)
+	}
+}
@@ -0,0 +1,114 @@
+package train
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+)
+
+// GenerationFailures counts generation failures by cause since the process
+// started
+type GenerationFailures struct {
+	// EmptyModel counts generations from a model without sentences
+	EmptyModel int64 `json:"empty_model"`
+
+	// CapExceeded counts generations that ran past MaxStoryTokens
+	CapExceeded int64 `json:"cap_exceeded"`
+
+	// DeadEnd counts generations that reached a state with no way to continue
+	DeadEnd int64 `json:"dead_end"`
+
+	// Clamped counts generations past MaxStoryTokens that ClampToSentence
+	// trimmed instead of failing
+	Clamped int64 `json:"clamped"`
+}
+
+var failureCounts struct {
+	emptyModel  atomic.Int64
+	capExceeded atomic.Int64
+	deadEnd     atomic.Int64
+	clamped     atomic.Int64
+}
+
+// GenerationFailureCounts returns how often generation has failed or been
+// clamped, for spotting a model that has gone bad
+func GenerationFailureCounts() GenerationFailures {
+	return GenerationFailures{
+		EmptyModel:  failureCounts.emptyModel.Load(),
+		CapExceeded: failureCounts.capExceeded.Load(),
+		DeadEnd:     failureCounts.deadEnd.Load(),
+		Clamped:     failureCounts.clamped.Load(),
+	}
+}
+
+// countFailure records err against its cause and returns it unchanged
+func countFailure(err error) error {
+	switch {
+	case errors.Is(err, ErrEmptyModel):
+		failureCounts.emptyModel.Add(1)
+	case errors.Is(err, ErrGenerationCapExceeded):
+		failureCounts.capExceeded.Add(1)
+	case errors.Is(err, ErrDeadEndState):
+		failureCounts.deadEnd.Add(1)
+	}
+	return err
+}
+
+// storyWordBounds are the upper bounds, inclusive, of the StoryWordCounts
+// buckets. Longer stories fall in a final bucket without a bound.
+var storyWordBounds = [...]int{10, 25, 50, 100, 200, 400, 800}
+
+var storyWords struct {
+	buckets [len(storyWordBounds) + 1]atomic.Int64
+	count   atomic.Int64
+	total   atomic.Int64
+}
+
+// WordCountBucket counts the stories of up to MaxWords words that were too
+// long for the previous bucket. The last bucket has no MaxWords.
+type WordCountBucket struct {
+	MaxWords int   `json:"max_words,omitempty"`
+	Count    int64 `json:"count"`
+}
+
+// WordCountHistogram is the distribution of generated story lengths, in words
+type WordCountHistogram struct {
+	Buckets    []WordCountBucket `json:"buckets"`
+	Count      int64             `json:"count"`
+	TotalWords int64             `json:"total_words"`
+}
+
+// StoryWordCounts returns the distribution of the body lengths of the posts
+// served since the process started, as recorded by ObserveStoryWords, for
+// spotting a retrain that makes stories much shorter or longer
+func StoryWordCounts() WordCountHistogram {
+	histogram := WordCountHistogram{
+		Count:      storyWords.count.Load(),
+		TotalWords: storyWords.total.Load(),
+	}
+	for i := range storyWords.buckets {
+		bucket := WordCountBucket{Count: storyWords.buckets[i].Load()}
+		if i < len(storyWordBounds) {
+			bucket.MaxWords = storyWordBounds[i]
+		}
+		histogram.Buckets = append(histogram.Buckets, bucket)
+	}
+	return histogram
+}
+
+// ObserveStoryWords records the length of a served story body. Pages
+// generated for other reasons, such as related links or readiness checks,
+// aren't recorded.
+func ObserveStoryWords(content string) {
+	words := len(strings.Fields(content))
+	bucket := len(storyWordBounds)
+	for i, bound := range storyWordBounds {
+		if words <= bound {
+			bucket = i
+			break
+		}
+	}
+	storyWords.buckets[bucket].Add(1)
+	storyWords.count.Add(1)
+	storyWords.total.Add(int64(words))
+}
@@ -0,0 +1,74 @@
+package train
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestObserveStoryWords(t *testing.T) {
+	chain, err := BuildModel(pruneCorpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := StoryWordCounts()
+	if _, err := GeneratePage(1, chain); err != nil {
+		t.Fatal(err)
+	}
+	if after := StoryWordCounts(); after.Count != before.Count {
+		t.Errorf("generating a page recorded %d stories, want none", after.Count-before.Count)
+	}
+
+	ObserveStoryWords("one two three")
+	ObserveStoryWords(strings.Repeat("word ", 1000))
+	after := StoryWordCounts()
+	if after.Count != before.Count+2 || after.TotalWords != before.TotalWords+1003 {
+		t.Errorf("got %d stories of %d words, want 2 of 1003 more", after.Count-before.Count, after.TotalWords-before.TotalWords)
+	}
+	first, last := 0, len(after.Buckets)-1
+	if after.Buckets[first].Count != before.Buckets[first].Count+1 {
+		t.Error("a three word story isn't in the first bucket")
+	}
+	if after.Buckets[last].Count != before.Buckets[last].Count+1 {
+		t.Error("a thousand word story isn't in the unbounded bucket")
+	}
+}
+
+func TestGenerationFailureCounts(t *testing.T) {
+	old := ClampToSentence
+	t.Cleanup(func() { ClampToSentence = old })
+	empty, err := BuildModel("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	healthy, err := BuildModel("The cat sat.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		chain MarkovChain
+		clamp bool
+		want  GenerationFailures
+	}{
+		{name: "cap", chain: runawayModel(t, "Hi"), want: GenerationFailures{CapExceeded: 1}},
+		{name: "clamped", chain: runawayModel(t, "Hi", "there."), clamp: true, want: GenerationFailures{Clamped: 1}},
+		{name: "empty", chain: empty, want: GenerationFailures{EmptyModel: 1}},
+		{name: "dead end", chain: deadEndModel(t), want: GenerationFailures{DeadEnd: 1}},
+		{name: "success", chain: healthy, want: GenerationFailures{}},
+	}
+	for _, tt := range tests {
+		ClampToSentence = tt.clamp
+		before := GenerationFailureCounts()
+		GenerateStory(1, tt.chain)
+		after := GenerationFailureCounts()
+		got := GenerationFailures{
+			EmptyModel:  after.EmptyModel - before.EmptyModel,
+			CapExceeded: after.CapExceeded - before.CapExceeded,
+			DeadEnd:     after.DeadEnd - before.DeadEnd,
+			Clamped:     after.Clamped - before.Clamped,
+		}
+		if got != tt.want {
+			t.Errorf("%s: counted %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
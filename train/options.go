@@ -0,0 +1,114 @@
+package train
+
+import (
+	"math/rand"
+	"time"
+)
+
+// GenerateOptions controls how GeneratePageWithOptions builds a page. Start
+// from DefaultGenerateOptions, which reflects the package-level settings, or a
+// model's DefaultOptions, and override the fields you need. The JSON form is
+// what a model saves; Prefix, Author and Generator are chosen per request.
+type GenerateOptions struct {
+	// Prefix starts the page body, see GenerateStoryFromPrefix
+	Prefix string `json:"-"`
+
+	// Author credits the page to this author instead of AuthorForSeed
+	Author string `json:"-"`
+
+	// MinSentences and MaxSentences bound how many sentences the body has
+	MinSentences int `json:"min_sentences"`
+	MaxSentences int `json:"max_sentences"`
+
+	// SentencesPerParagraph starts a new paragraph every N sentences. Zero or
+	// less only breaks where the model recorded a paragraph break.
+	SentencesPerParagraph int `json:"sentences_per_paragraph"`
+
+	// MinLinks and MaxLinks bound how many related links the page has
+	MinLinks int `json:"min_links"`
+	MaxLinks int `json:"max_links"`
+
+	// RelatedByVocabulary biases related link titles toward sharing a word
+	// with the page title
+	RelatedByVocabulary bool `json:"related_by_vocabulary"`
+
+	// LinkTitleMaxWords shortens related link titles to this many words.
+	// Zero or less leaves them whole.
+	LinkTitleMaxWords int `json:"link_title_max_words"`
+
+	// RepetitionPenalty is the chance, from 0 to 1, that a token already used
+	// on the page is redrawn while generating the body
+	RepetitionPenalty float64 `json:"repetition_penalty"`
+
+	// MaxSentenceWords cuts body sentences after this many words. Zero or
+	// less leaves them whole.
+	MaxSentenceWords int `json:"max_sentence_words"`
+
+	// Generator draws the body sentences. Generators other than
+	// MarkovGenerator produce whole sentences, so RepetitionPenalty and
+	// paragraph breaks recorded by the model don't apply to them.
+	Generator Generator `json:"-"`
+
+	// PostDateMaxAge and PostDateMinAge bound how far in the past the post
+	// date falls
+	PostDateMaxAge time.Duration `json:"post_date_max_age"`
+	PostDateMinAge time.Duration `json:"post_date_min_age"`
+}
+
+// DefaultGenerateOptions returns the options GeneratePage uses, taken from the
+// package-level settings
+func DefaultGenerateOptions() GenerateOptions {
+	return GenerateOptions{
+		MinSentences:          1,
+		MaxSentences:          10,
+		SentencesPerParagraph: SentencesPerParagraph,
+		MinLinks:              1,
+		MaxLinks:              3,
+		RelatedByVocabulary:   RelatedByVocabulary,
+		LinkTitleMaxWords:     LinkTitleMaxWords,
+		RepetitionPenalty:     RepetitionPenalty,
+		MaxSentenceWords:      MaxSentenceWords,
+		Generator:             DefaultGenerator,
+		PostDateMaxAge:        PostDateMaxAge,
+		PostDateMinAge:        PostDateMinAge,
+	}
+}
+
+// DefaultOptions returns the options GeneratePage uses for this model: the
+// ones saved with it by WithGenerateOptions, or DefaultGenerateOptions when it
+// has none
+func (m MarkovChain) DefaultOptions() GenerateOptions {
+	if m.options == nil {
+		return DefaultGenerateOptions()
+	}
+	opts := *m.options
+	opts.Generator = DefaultGenerator
+	return opts
+}
+
+// WithGenerateOptions returns the chain with opts saved as its DefaultOptions.
+// They are serialized with the model, except for Prefix, Author and Generator.
+func (m MarkovChain) WithGenerateOptions(opts GenerateOptions) MarkovChain {
+	opts.Prefix = ""
+	opts.Author = ""
+	opts.Generator = nil
+	m.options = &opts
+	return m
+}
+
+// normalized returns opts with its ranges made valid: at least one sentence,
+// no negative link counts, and maximums no smaller than minimums
+func (opts GenerateOptions) normalized() GenerateOptions {
+	opts.MinSentences = max(opts.MinSentences, 1)
+	opts.MaxSentences = max(opts.MaxSentences, opts.MinSentences)
+	opts.MinLinks = max(opts.MinLinks, 0)
+	opts.MaxLinks = max(opts.MaxLinks, opts.MinLinks)
+	opts.PostDateMinAge = max(opts.PostDateMinAge, 0)
+	opts.PostDateMaxAge = max(opts.PostDateMaxAge, opts.PostDateMinAge)
+	return opts
+}
+
+// between draws an int from [low, high]
+func between(prng *rand.Rand, low, high int) int {
+	return prng.Intn(high-low+1) + low
+}
@@ -0,0 +1,116 @@
+package train
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGeneratePageWithOptions(t *testing.T) {
+	chain, err := BuildModel("The cat saw the cat saw the cat saw the dog. The dog ran. I saw the dog. The cat saw a bird. A bird sang. My dog sat.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sentences returns the sentences of each paragraph of page
+	sentences := func(page GeneratedPage) [][][]string {
+		var all [][][]string
+		for _, paragraph := range page.Paragraphs {
+			all = append(all, splitSentences(strings.Fields(paragraph)))
+		}
+		return all
+	}
+	tests := []struct {
+		name  string
+		apply func(opts *GenerateOptions)
+		check func(page GeneratedPage) bool
+	}{
+		{"exact sentences and links", func(opts *GenerateOptions) {
+			opts.MinSentences, opts.MaxSentences = 4, 4
+			opts.SentencesPerParagraph = 0
+			opts.MinLinks, opts.MaxLinks = 2, 2
+		}, func(page GeneratedPage) bool {
+			paragraphs := sentences(page)
+			return len(paragraphs) == 1 && len(paragraphs[0]) == 4 && len(page.Links) == 2
+		}},
+		{"one sentence per paragraph without links", func(opts *GenerateOptions) {
+			opts.MinSentences, opts.MaxSentences = 3, 3
+			opts.SentencesPerParagraph = 1
+			opts.MinLinks, opts.MaxLinks = 0, 0
+		}, func(page GeneratedPage) bool {
+			paragraphs := sentences(page)
+			return len(paragraphs) == 3 && len(paragraphs[0]) == 1 && len(paragraphs[2]) == 1 && len(page.Links) == 0
+		}},
+		{"short sentences from a prefix", func(opts *GenerateOptions) {
+			opts.MaxSentenceWords = 2
+			opts.Prefix = "My"
+		}, func(page GeneratedPage) bool {
+			for _, paragraph := range sentences(page) {
+				for _, sentence := range paragraph {
+					if len(sentence) > 2 {
+						return false
+					}
+				}
+			}
+			return strings.HasPrefix(page.Content, "My ")
+		}},
+		{"author and short link titles", func(opts *GenerateOptions) {
+			opts.Author = Authors()[0]
+			opts.MinLinks, opts.MaxLinks = 3, 3
+			opts.LinkTitleMaxWords = 1
+		}, func(page GeneratedPage) bool {
+			for _, link := range page.Links {
+				if len(strings.Fields(link.Title)) > 1 {
+					return false
+				}
+			}
+			return page.Author == Authors()[0] && len(page.Links) == 3
+		}},
+		{"inverted ranges", func(opts *GenerateOptions) {
+			opts.MinSentences, opts.MaxSentences = 2, 0
+			opts.MinLinks, opts.MaxLinks = 1, -1
+		}, func(page GeneratedPage) bool {
+			count := 0
+			for _, paragraph := range sentences(page) {
+				count += len(paragraph)
+			}
+			return count == 2 && len(page.Links) == 1
+		}},
+	}
+	for _, tt := range tests {
+		for seed := int64(1); seed <= 10; seed++ {
+			opts := chain.DefaultOptions()
+			tt.apply(&opts)
+			page, err := GeneratePageWithOptions(seed, chain, opts)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if !tt.check(page) {
+				t.Errorf("%s: seed %d generated %+v", tt.name, seed, page)
+			}
+		}
+	}
+}
+
+func TestGeneratePageUsesDefaultOptions(t *testing.T) {
+	chain, err := BuildModel("The cat saw the dog. The dog ran. I saw the dog. A bird sang. My dog sat.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultGenerateOptions()
+	opts.MinSentences, opts.MaxSentences = 5, 5
+	saved := chain.WithGenerateOptions(opts)
+	for seed := int64(1); seed <= 5; seed++ {
+		for _, chain := range []MarkovChain{chain, saved} {
+			page, err := GeneratePage(seed, chain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := GeneratePageWithOptions(seed, chain, chain.DefaultOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Content != want.Content || page.Link != want.Link || len(page.Links) != len(want.Links) {
+				t.Errorf("seed %d: GeneratePage got %+v, want %+v", seed, page, want)
+			}
+		}
+	}
+}
@@ -2,41 +2,83 @@ package train
 
 import (
 	"fmt"
+	"hash/fnv"
 	"math"
 	"math/rand"
+	"slices"
 	"strings"
 	"time"
 	"unicode"
+	"unicode/utf8"
 )
 
+// SentencesPerParagraph controls how generated sentences are grouped into
+// paragraphs. Zero or less keeps all sentences in a single paragraph.
+var SentencesPerParagraph = 0
+
 type GeneratedPage struct {
 	Link        PageLink
 	Content     string
+	Paragraphs  []string
 	Links       []PageLink
 	LastUpdated time.Time
 	Author      string
 }
 
+// GeneratePage generates the page for seed with the chain's DefaultOptions
 func GeneratePage(seed int64, chain MarkovChain) (GeneratedPage, error) {
+	return GeneratePageWithOptions(seed, chain, chain.DefaultOptions())
+}
+
+// GeneratePageFromPrefix generates the page for seed with its body starting
+// from prefix, see GenerateStoryFromPrefix. An empty prefix gives GeneratePage.
+func GeneratePageFromPrefix(seed int64, chain MarkovChain, prefix string) (GeneratedPage, error) {
+	opts := chain.DefaultOptions()
+	opts.Prefix = prefix
+	return GeneratePageWithOptions(seed, chain, opts)
+}
+
+// PostLink returns the link to the page for seed, the same as its
+// GeneratedPage's Link, without generating the rest of the page
+func PostLink(seed int64, chain MarkovChain) (PageLink, error) {
+	return createLinkFromSeed(seed, rand.New(rand.NewSource(seed)), chain)
+}
+
+// GeneratePageWithOptions generates the page for seed, shaped by opts
+func GeneratePageWithOptions(seed int64, chain MarkovChain, opts GenerateOptions) (GeneratedPage, error) {
+	opts = opts.normalized()
 	prng := rand.New(rand.NewSource(seed))
 	thisLink, err := createLinkFromSeed(seed, prng, chain)
 	if err != nil {
 		return GeneratedPage{}, err
 	}
-	content, err := createParagraph(prng, chain)
+	// Link titles must match the pages they point to, so only the body avoids
+	// repeating tokens already used on the page
+	var history *tokenHistory
+	if opts.RepetitionPenalty > 0 {
+		history = newTokenHistory(opts.RepetitionPenalty)
+		history.add(strings.Fields(thisLink.Title))
+	}
+	paragraphs, err := createParagraphs(prng, chain, opts, history)
 	if err != nil {
 		return GeneratedPage{}, err
 	}
-	links, err := createLinks(prng, chain)
+	links, err := createLinks(prng, chain, thisLink, opts)
 	if err != nil {
 		return GeneratedPage{}, err
 	}
-	lastUpdated := generateRandomDate(prng)
-	author := authors[prng.Intn(len(authors))]
+	lastUpdated := generateRandomDate(prng, opts.PostDateMaxAge, opts.PostDateMinAge)
+	author := AuthorForSeed(seed)
+	if opts.Author != "" {
+		author = opts.Author
+	}
+
+	content := strings.Join(paragraphs, " ")
 
 	page := GeneratedPage{
 		Link:        thisLink,
 		Content:     content,
+		Paragraphs:  paragraphs,
 		Links:       links,
 		LastUpdated: lastUpdated,
 		Author:      author,
@@ -44,20 +86,56 @@ func GeneratePage(seed int64, chain MarkovChain) (GeneratedPage, error) {
 	return page, nil
 }
 
-func createParagraph(prng *rand.Rand, chain MarkovChain) (string, error) {
-	sentenceCount := prng.Intn(10) + 1
+// createParagraphs generates the page body, starting a new paragraph every
+// opts.SentencesPerParagraph sentences and wherever the model recorded a
+// paragraph break. Tokens in history are down-weighted. The first sentence
+// starts from opts.Prefix when it is not empty.
+func createParagraphs(prng *rand.Rand, chain MarkovChain, opts GenerateOptions, history *tokenHistory) ([]string, error) {
+	sentenceCount := between(prng, opts.MinSentences, opts.MaxSentences)
+	perParagraph := opts.SentencesPerParagraph
+	prefix := opts.Prefix
+	paragraphs := []string{}
 	var paragraph strings.Builder
+	sentencesInParagraph := 0
 	for i := 0; i < sentenceCount; i++ {
-		sentence, err := GenerateStoryFromPrng(prng, chain)
+		tokens, err := bodySentence(prng, chain, opts, prefix, history)
+		prefix = ""
 		if err != nil {
-			return "", err
+			return nil, err
+		}
+		if perParagraph > 0 && sentencesInParagraph == perParagraph {
+			paragraphs = append(paragraphs, paragraph.String())
+			paragraph.Reset()
+			sentencesInParagraph = 0
 		}
-		if i > 0 {
+		if paragraph.Len() > 0 {
 			paragraph.WriteString(" ")
 		}
-		paragraph.WriteString(sentence)
+		paragraph.WriteString(chain.finishSentence(tokens))
+		sentencesInParagraph++
+		if endsParagraph(tokens) && i < sentenceCount-1 {
+			paragraphs = append(paragraphs, paragraph.String())
+			paragraph.Reset()
+			sentencesInParagraph = 0
+		}
 	}
-	return paragraph.String(), nil
+	paragraphs = append(paragraphs, paragraph.String())
+	return paragraphs, nil
+}
+
+// bodySentence returns the tokens of the next body sentence, drawn by
+// opts.Generator
+func bodySentence(prng *rand.Rand, chain MarkovChain, opts GenerateOptions, prefix string, history *tokenHistory) ([]string, error) {
+	if _, ok := opts.Generator.(MarkovGenerator); ok || opts.Generator == nil {
+		return generateTokensFromPrefix(MarkovGenerator{}, prng, chain, chain.Sentinels(), prefix, history, opts.MaxSentenceWords)
+	}
+	sentenceOpts := opts
+	sentenceOpts.Prefix = prefix
+	sentence, err := opts.Generator.Generate(chain, prng, sentenceOpts)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(sentence), nil
 }
 
 func createNewLink(prngOld *rand.Rand, chain MarkovChain) (PageLink, error) {
@@ -67,16 +145,36 @@ func createNewLink(prngOld *rand.Rand, chain MarkovChain) (PageLink, error) {
 }
 
 func createLinkFromSeed(seed int64, prng *rand.Rand, chain MarkovChain) (PageLink, error) {
-	title, err := GenerateStoryFromPrng(prng, chain)
+	// Titles are always sampled, whatever DefaultGenerator is, so each seed
+	// gets its own title and links match the pages they point to
+	title, err := MarkovGenerator{}.Generate(chain.titleModel(), prng, GenerateOptions{MaxSentenceWords: MaxSentenceWords})
 	if err != nil {
 		return PageLink{}, err
 	}
 
 	//make this url friendly.
 	//replace spaces with dashes
-	//truncate to max 256 characters
-	numChars := int(math.Min(64, float64(len(title))))
-	link := strings.TrimSpace(title[:numChars])
+	//truncate to MaxSlugLength bytes at a word boundary
+	link := Slugify(truncateAtWord(title, MaxSlugLength))
+
+	// A title of only punctuation leaves no slug, so link to the bare seed
+	url := fmt.Sprintf("/post/%d-%s", seed, link)
+	if link == "" {
+		url = fmt.Sprintf("/post/%d", seed)
+	}
+
+	return PageLink{
+		Url:   url,
+		Title: title,
+		Seed:  seed,
+		Slug:  link,
+	}, nil
+}
+
+// Slugify makes text url friendly: lower case letters and numbers separated
+// by single dashes
+func Slugify(text string) string {
+	link := strings.TrimSpace(text)
 	link = strings.ToLower(link)
 	link = strings.ReplaceAll(link, " ", "-")
 	link = strings.ReplaceAll(link, "\n", "-")
@@ -87,79 +185,323 @@ func createLinkFromSeed(seed int64, prng *rand.Rand, chain MarkovChain) (PageLin
 		}
 		return -1
 	}, link)
-	//now remove any duplicate dashes
-	link = strings.ReplaceAll(link, "--", "-")
+	//now remove any duplicate dashes, which can be runs of any length
+	for strings.Contains(link, "--") {
+		link = strings.ReplaceAll(link, "--", "-")
+	}
 	//now remove any leading or trailing dashes
-	link = strings.Trim(link, "-")
-
-	return PageLink{
-		Url:   fmt.Sprintf("/post/%d-%s", seed, link),
-		Title: title,
-		Seed:  seed,
-	}, nil
+	return strings.Trim(link, "-")
 }
 
 type PageLink struct {
 	Url   string
 	Title string
 	Seed  int64
+	Slug  string
 }
 
-func createLinks(prng *rand.Rand, chain MarkovChain) ([]PageLink, error) {
-	linkCount := prng.Intn(3) + 1
+// RelatedByVocabulary biases related link titles toward sharing a word with
+// the page title, so "Related Stories" feel topically connected. It is the
+// default for GenerateOptions.RelatedByVocabulary.
+var RelatedByVocabulary = false
+
+// MaxSlugLength caps the bytes of a title used to build its url slug. Longer
+// titles are cut back to the last whole word that fits.
+var MaxSlugLength = 64
+
+// truncateAtWord cuts s to at most maxBytes, ending at a word boundary when
+// possible. A first word longer than maxBytes is cut on a rune boundary.
+func truncateAtWord(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	if !unicode.IsSpace(rune(s[cut])) {
+		if space := strings.LastIndexFunc(s[:cut], unicode.IsSpace); space > 0 {
+			cut = space
+		}
+	}
+	return s[:cut]
+}
+
+// LinkTitleMaxWords shortens the displayed titles of related links to this
+// many words, ending them with an ellipsis. Zero or less leaves them whole.
+// It is the default for GenerateOptions.LinkTitleMaxWords.
+var LinkTitleMaxWords = 0
+
+// relatedCandidates is how many titles are tried per related link when
+// looking for one that shares a word with the page title
+const relatedCandidates = 8
+
+// createLinks generates the related links for page. Links are unique by slug
+// and never point back at page; a duplicate is regenerated within the page's
+// retry budget and left out once the budget is spent.
+func createLinks(prng *rand.Rand, chain MarkovChain, page PageLink, opts GenerateOptions) ([]PageLink, error) {
+	linkCount := between(prng, opts.MinLinks, opts.MaxLinks)
 	links := []PageLink{}
+	seenSlugs := map[string]bool{page.Slug: true}
+	seenSeeds := map[int64]bool{page.Seed: true}
+	// Slugless links point at the bare seed, so only the seed can repeat
+	duplicate := func(link PageLink) bool {
+		return seenSeeds[link.Seed] || (link.Slug != "" && seenSlugs[link.Slug])
+	}
+	budget := newRetryBudget()
 	for i := 0; i < linkCount; i++ {
-		link, err := createNewLink(prng, chain)
+		link, err := createLink(prng, chain, page.Title, opts)
 		if err != nil {
 			return nil, err
 		}
+		for duplicate(link) && budget.spend() {
+			link, err = createLink(prng, chain, page.Title, opts)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if duplicate(link) {
+			continue
+		}
+		seenSlugs[link.Slug] = true
+		seenSeeds[link.Seed] = true
+		// Only the displayed title is shortened; the slug keeps the full title
+		link.Title = truncateWords(link.Title, opts.LinkTitleMaxWords)
 		links = append(links, link)
 	}
 	return links, nil
 }
 
-// generateRandomDate creates a random date within the last 2 years
-func generateRandomDate(prng *rand.Rand) time.Time {
-	// Generate a random date within the last 2 years
-	now := time.Now()
-	twoYearsAgo := now.AddDate(-2, 0, 0)
+// createLink generates one related link for a page titled title
+func createLink(prng *rand.Rand, chain MarkovChain, title string, opts GenerateOptions) (PageLink, error) {
+	if opts.RelatedByVocabulary {
+		return createRelatedLink(prng, chain, title)
+	}
+	return createNewLink(prng, chain)
+}
+
+// truncateWords cuts text to its first maxWords words, ending it with an
+// ellipsis. Text that already fits, or a maxWords of zero or less, is
+// returned unchanged.
+func truncateWords(text string, maxWords int) string {
+	words := strings.Fields(text)
+	if maxWords <= 0 || len(words) <= maxWords {
+		return text
+	}
+	truncated := strings.Join(words[:maxWords], " ")
+	truncated = strings.TrimRightFunc(truncated, func(r rune) bool {
+		return unicode.IsPunct(r) && r != '"' && r != '\''
+	})
+	return truncated + "…"
+}
+
+// createRelatedLink picks a word from the page title, seeds candidate links
+// from a hash of it, and returns the first candidate whose title shares a word
+// with the page title. The last candidate is used if none do.
+func createRelatedLink(prng *rand.Rand, chain MarkovChain, title string) (PageLink, error) {
+	words := titleWords(title)
+	if len(words) == 0 {
+		return createNewLink(prng, chain)
+	}
+	word := words[prng.Intn(len(words))]
+	hash := fnv.New64a()
+	hash.Write([]byte(word))
+	base := prng.Int63() ^ int64(hash.Sum64()>>1)
+
+	var link PageLink
+	for i := 0; i < relatedCandidates; i++ {
+		// Keep seeds non-negative so they round trip through the url
+		seed := (base + int64(i)) & math.MaxInt64
+		var err error
+		link, err = createLinkFromSeed(seed, rand.New(rand.NewSource(seed)), chain)
+		if err != nil {
+			return PageLink{}, err
+		}
+		if sharesWord(link.Title, words) {
+			return link, nil
+		}
+	}
+	return link, nil
+}
+
+// titleWords returns the lowercased words of a title that are long enough to be
+// meaningful, skipping short function words like "the" and "a"
+func titleWords(title string) []string {
+	words := []string{}
+	for _, field := range strings.Fields(strings.ToLower(title)) {
+		word := strings.TrimFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		if len(word) > 3 {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+func sharesWord(title string, words []string) bool {
+	for _, word := range titleWords(title) {
+		if slices.Contains(words, word) {
+			return true
+		}
+	}
+	return false
+}
 
-	// Generate random seconds since two years ago
-	secondsRange := int64(now.Sub(twoYearsAgo).Seconds())
+// PostDateMaxAge and PostDateMinAge bound how far in the past generated post
+// dates fall. The default window is the last 2 years.
+var (
+	PostDateMaxAge = 2 * 365 * 24 * time.Hour
+	PostDateMinAge = time.Duration(0)
+)
+
+// generateRandomDate creates a random date between maxAge and minAge ago
+func generateRandomDate(prng *rand.Rand, maxAge, minAge time.Duration) time.Time {
+	// Anchor to the start of the day so a seed keeps its date for the whole day
+	now := time.Now().UTC().Truncate(24 * time.Hour)
+	oldest := now.Add(-maxAge)
+	newest := now.Add(-minAge)
+
+	// Generate random seconds within the window
+	secondsRange := int64(newest.Sub(oldest).Seconds())
+	if secondsRange <= 0 {
+		return newest
+	}
 	randomSeconds := prng.Int63n(secondsRange)
 
 	// Add random seconds to the base date
-	randomDate := twoYearsAgo.Add(time.Duration(randomSeconds) * time.Second)
+	randomDate := oldest.Add(time.Duration(randomSeconds) * time.Second)
 
 	return randomDate
 }
 
-var authors = []string{
-	"Arlo Mills",
-	"Joe Goetz",
-	"Billy Goetz",
-	"Marybeth Trott",
-	"Charlie Davis",
-	"Diana White",
-	"Ethan Young",
+// HomePageMinContentLength is the content length in characters that home page
+// posts aim for. Shorter posts are regenerated with a nearby seed within the
+// post's retry budget. Zero disables the check.
+var HomePageMinContentLength = 0
+
+// HomePageMinWords is the word count home page posts aim for, so a small
+// model doesn't fill the grid with one or two word stories. Shorter posts are
+// regenerated like those under HomePageMinContentLength. Zero disables it.
+var HomePageMinWords = 0
+
+// MaxRegenerations is the retry budget shared by every check that regenerates
+// a post, such as the minimum length and unique titles. Once it is spent the
+// best attempt so far is kept, so a tiny model can't loop indefinitely.
+var MaxRegenerations = 5
+
+// retryBudget counts the regenerations left for one post
+type retryBudget struct {
+	left int
+}
+
+func newRetryBudget() *retryBudget {
+	return &retryBudget{left: MaxRegenerations}
+}
+
+// spend uses one regeneration, returning false once the budget is exhausted
+func (b *retryBudget) spend() bool {
+	if b.left <= 0 {
+		return false
+	}
+	b.left--
+	return true
 }
 
 // GenerateHomePagePosts generates multiple posts for the home page grid
 func GenerateHomePagePosts(chain MarkovChain, count int) ([]GeneratedPage, error) {
 	// Use current time as base seed for consistent daily generation
-	baseSeed := time.Now().Unix() / 86400 // Daily seed (changes every day)
+	return generatePosts(chain, dailySeed(time.Now()), count)
+}
+
+// sitemapSeedSpace is the base seed of the sitemap's posts. Daily seeds count
+// days since 1970, so it stays clear of every day's home page.
+const sitemapSeedSpace = 1 << 40
 
+// GenerateSitemapPosts generates the posts listed in the sitemap. Their seeds
+// don't depend on the day, so crawlers see the same URLs until the model
+// changes.
+func GenerateSitemapPosts(chain MarkovChain, count int) ([]GeneratedPage, error) {
+	return generatePosts(chain, sitemapSeedSpace, count)
+}
+
+// generatePosts generates count posts with distinct title slugs from seeds
+// spaced out from baseSeed
+func generatePosts(chain MarkovChain, baseSeed int64, count int) ([]GeneratedPage, error) {
 	posts := make([]GeneratedPage, count)
+	seenSlugs := map[string]bool{}
 	for i := 0; i < count; i++ {
-		// Create a unique seed for each post based on the daily seed
+		// Create a unique seed for each post based on the base seed
 		postSeed := baseSeed + int64(i*1000) // Ensure unique seeds
 
-		post, err := GeneratePage(postSeed, chain)
+		budget := newRetryBudget()
+		post, err := generateWithMinLength(postSeed, chain, budget)
 		if err != nil {
 			return nil, err
 		}
+		// Regenerate posts whose title slug is already on the page, keeping
+		// the last attempt once the budget is spent. A retry whose slug is
+		// also taken never replaces a post that is long enough with a
+		// shorter one.
+		for attempt := 1; seenSlugs[post.Link.Slug] && budget.spend(); attempt++ {
+			retry, err := generateWithMinLength(postSeed+int64(attempt*100), chain, budget)
+			if err != nil {
+				return nil, err
+			}
+			if !seenSlugs[retry.Link.Slug] || !tooShort(retry) || tooShort(post) {
+				post = retry
+			}
+		}
+		seenSlugs[post.Link.Slug] = true
 		posts[i] = post
 	}
 
 	return posts, nil
 }
+
+// dailySeed is the day number the home page is generated from, so it
+// changes every day at midnight UTC
+func dailySeed(now time.Time) int64 {
+	return now.Unix() / 86400
+}
+
+// FeaturedSeed returns the seed of the home page's featured story for the day
+// of now. The daily seed is scrambled rather than offset, so it lands far from
+// the grid's seeds and their retries.
+func FeaturedSeed(now time.Time) int64 {
+	return int64(mixSeed(dailySeed(now)) & math.MaxInt64)
+}
+
+// GenerateFeaturedPost generates today's featured story, regenerating it like
+// the grid posts when it is too short
+func GenerateFeaturedPost(chain MarkovChain) (GeneratedPage, error) {
+	return generateWithMinLength(FeaturedSeed(time.Now()), chain, newRetryBudget())
+}
+
+// generateWithMinLength generates a page, retrying with nearby seeds while the
+// content is shorter than HomePageMinContentLength or HomePageMinWords. The
+// longest attempt is kept once budget is spent.
+func generateWithMinLength(seed int64, chain MarkovChain, budget *retryBudget) (GeneratedPage, error) {
+	best, err := GeneratePage(seed, chain)
+	if err != nil {
+		return GeneratedPage{}, err
+	}
+	for attempt := 1; tooShort(best) && budget.spend(); attempt++ {
+		post, err := GeneratePage(seed+int64(attempt), chain)
+		if err != nil {
+			return GeneratedPage{}, err
+		}
+		if len(post.Content) > len(best.Content) {
+			best = post
+		}
+	}
+	return best, nil
+}
+
+// tooShort reports whether post is under the home page minimum length
+func tooShort(post GeneratedPage) bool {
+	if len(post.Content) < HomePageMinContentLength {
+		return true
+	}
+	return HomePageMinWords > 0 && len(strings.Fields(post.Content)) < HomePageMinWords
+}
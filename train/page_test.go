@@ -0,0 +1,646 @@
+package train
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mb-14/gomarkov"
+)
+
+// withMaxSentenceWords sets MaxSentenceWords for the rest of the test
+func withMaxSentenceWords(t *testing.T, n int) {
+	t.Helper()
+	old := MaxSentenceWords
+	MaxSentenceWords = n
+	t.Cleanup(func() { MaxSentenceWords = old })
+}
+
+func TestMaxSentenceWordsKeepsFirstWord(t *testing.T) {
+	chain, err := BuildModel("Alpha beta gamma delta epsilon zeta eta theta.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		maxWords int
+		prefix   string
+		want     string
+	}{
+		{maxWords: 1, want: "Alpha."},
+		{maxWords: 4, want: "Alpha beta gamma delta."},
+		{maxWords: 0, want: "Alpha beta gamma delta epsilon zeta eta theta."},
+		{maxWords: 3, prefix: "Alpha beta", want: "Alpha beta gamma."},
+		{maxWords: 2, prefix: "gamma", want: "gamma delta."},
+	}
+	for _, tt := range tests {
+		withMaxSentenceWords(t, tt.maxWords)
+		got, err := GenerateStoryFromPrefix(rand.New(rand.NewSource(1)), chain, tt.prefix)
+		if err != nil {
+			t.Fatalf("maxWords %d prefix %q: %v", tt.maxWords, tt.prefix, err)
+		}
+		if got != tt.want {
+			t.Errorf("maxWords %d prefix %q: got %q, want %q", tt.maxWords, tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateStoryFromPrefix(t *testing.T) {
+	withMaxSentenceWords(t, 0)
+	chain, err := BuildModel("Alpha beta gamma. Delta beta epsilon. Zeta eta.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lower, err := BuildModelWithOptions("Alpha beta gamma. Delta beta epsilon. Zeta eta.", TrainOptions{Lowercase: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		chain  MarkovChain
+		prefix string
+		// want is the only sentence the prefix can begin; empty means the
+		// prefix falls back to an ordinary sentence
+		want string
+	}{
+		{name: "known word", chain: chain, prefix: "Zeta", want: "Zeta eta."},
+		{name: "known phrase", chain: chain, prefix: "Once upon a Zeta", want: "Once upon a Zeta eta."},
+		{name: "sentence end", chain: chain, prefix: "gamma.", want: "gamma."},
+		{name: "word without its punctuation", chain: chain, prefix: "gamma"},
+		{name: "folded case", chain: lower, prefix: "ZETA", want: "Zeta eta."},
+		{name: "unknown word", chain: chain, prefix: "Unicorns"},
+		{name: "unknown last word", chain: chain, prefix: "Zeta unicorns"},
+		{name: "case of an unfolded model", chain: chain, prefix: "zeta"},
+		{name: "blank", chain: chain, prefix: "   "},
+	}
+	for _, tt := range tests {
+		for seed := int64(1); seed <= 5; seed++ {
+			got, err := GenerateStoryFromPrefix(rand.New(rand.NewSource(seed)), tt.chain, tt.prefix)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			want := tt.want
+			if want == "" {
+				// An unusable prefix generates the sentence no prefix would
+				if want, err = GenerateStoryFromPrefix(rand.New(rand.NewSource(seed)), tt.chain, ""); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got != want {
+				t.Errorf("%s: prefix %q with seed %d got %q, want %q", tt.name, tt.prefix, seed, got, want)
+			}
+		}
+	}
+}
+
+func TestGreedyGeneratorPages(t *testing.T) {
+	// The most frequent transitions form a cycle: the, cat, saw, the, ...
+	chain, err := BuildModel("The cat saw the cat saw the cat saw the dog. The dog ran. I saw the dog. The cat saw a bird. A bird sang. My dog sat.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	withMaxSentenceWords(t, 0)
+	opts := chain.DefaultOptions()
+	opts.Generator = GreedyGenerator{}
+
+	slugs := map[string]bool{}
+	for seed := int64(1); seed <= 20; seed++ {
+		page, err := GeneratePageWithOptions(seed, chain, opts)
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		for _, paragraph := range page.Paragraphs {
+			for _, sentence := range strings.SplitAfter(paragraph, ".") {
+				if words := len(strings.Fields(sentence)); words > GreedySentenceWords {
+					t.Errorf("seed %d: sentence of %d words exceeds %d", seed, words, GreedySentenceWords)
+				}
+			}
+		}
+		slugs[page.Link.Slug] = true
+	}
+	if len(slugs) < 2 {
+		t.Errorf("greedy pages share one slug across 20 seeds: %v", slugs)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"The Cat Sat.", "the-cat-sat"},
+		{"  Wait... what?  ", "wait-what"},
+		{"Wait - - - what", "wait-what"},
+		{"Café crème, 2 ways!", "café-crème-2-ways"},
+		{"— quoted —", "quoted"},
+		{"?! ...", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Slugify(tt.text); got != tt.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestPunctuationTitleLinksToSeed(t *testing.T) {
+	chain, err := BuildModel("?! ...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seed := int64(1); seed <= 3; seed++ {
+		page, err := GeneratePage(seed, chain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := fmt.Sprintf("/post/%d", seed)
+		if page.Link.Slug != "" || page.Link.Url != want {
+			t.Errorf("seed %d got link %+v, want %s", seed, page.Link, want)
+		}
+	}
+}
+
+func TestPostLinkMatchesPage(t *testing.T) {
+	for _, opts := range []TrainOptions{{}, {Titles: true}} {
+		chain, err := BuildModelWithOptions(pruneCorpus, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for seed := int64(1); seed <= 10; seed++ {
+			link, err := PostLink(seed, chain)
+			if err != nil {
+				t.Fatalf("seed %d: %v", seed, err)
+			}
+			page, err := GeneratePage(seed, chain)
+			if err != nil {
+				t.Fatalf("seed %d: %v", seed, err)
+			}
+			if link != page.Link {
+				t.Errorf("titles %v seed %d: PostLink gave %+v, the page links to %+v", opts.Titles, seed, link, page.Link)
+			}
+		}
+	}
+}
+
+func TestRelatedLinksAreDistinct(t *testing.T) {
+	// Few distinct sentences, so related links often draw the same title
+	chain, err := BuildModel(pruneCorpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, byVocabulary := range []bool{false, true} {
+		opts := chain.DefaultOptions()
+		opts.RelatedByVocabulary = byVocabulary
+		for seed := int64(1); seed <= 50; seed++ {
+			page, err := GeneratePageWithOptions(seed, chain, opts)
+			if err != nil {
+				t.Fatalf("seed %d: %v", seed, err)
+			}
+			seenSlugs := map[string]bool{page.Link.Slug: true}
+			seenSeeds := map[int64]bool{page.Link.Seed: true}
+			for _, link := range page.Links {
+				if seenSeeds[link.Seed] || (link.Slug != "" && seenSlugs[link.Slug]) {
+					t.Errorf("vocabulary %v seed %d: link %q repeats the page or another link", byVocabulary, seed, link.Url)
+				}
+				seenSlugs[link.Slug] = true
+				seenSeeds[link.Seed] = true
+			}
+		}
+	}
+}
+
+func TestFeaturedSeed(t *testing.T) {
+	chain, err := BuildModel("The cat saw the dog. The dog ran home. I saw the dog. The cat saw a bird. A bird sang all day. My dog sat.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, day := range []time.Time{
+		time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC),
+	} {
+		seed := FeaturedSeed(day)
+		if later := FeaturedSeed(day.Add(24*time.Hour - time.Second)); later != seed {
+			t.Errorf("%s: the featured seed changed from %d to %d within the day", day.Format("2006-01-02"), seed, later)
+		}
+		if next := FeaturedSeed(day.Add(24 * time.Hour)); next == seed {
+			t.Errorf("%s: the next day has the same featured seed %d", day.Format("2006-01-02"), seed)
+		}
+
+		featured, err := generateWithMinLength(seed, chain, newRetryBudget())
+		if err != nil {
+			t.Fatal(err)
+		}
+		grid, err := generatePosts(chain, dailySeed(day), 12)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, post := range grid {
+			if post.Link.Seed == seed || post.Link.Seed == featured.Link.Seed {
+				t.Errorf("%s: the grid repeats the featured seed %d", day.Format("2006-01-02"), post.Link.Seed)
+			}
+		}
+	}
+}
+
+func TestSentencesPerParagraph(t *testing.T) {
+	chain, err := BuildModel("The cat saw the dog. The dog ran home. I saw the dog. The cat saw a bird. A bird sang all day. My dog sat.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		perParagraph int
+		want         []int
+	}{
+		{perParagraph: 3, want: []int{3, 3, 1}},
+		{perParagraph: 7, want: []int{7}},
+		{perParagraph: 0, want: []int{7}},
+	}
+	for _, tt := range tests {
+		opts := chain.DefaultOptions()
+		opts.MinSentences, opts.MaxSentences = 7, 7
+		opts.SentencesPerParagraph = tt.perParagraph
+		page, err := GeneratePageWithOptions(1, chain, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		for _, paragraph := range page.Paragraphs {
+			got = append(got, strings.Count(paragraph, "."))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%d per paragraph: got paragraphs of %v sentences, want %v", tt.perParagraph, got, tt.want)
+		}
+		if page.Content != strings.Join(page.Paragraphs, " ") {
+			t.Errorf("%d per paragraph: the content %q isn't the paragraphs joined", tt.perParagraph, page.Content)
+		}
+	}
+}
+
+func TestHomePagePostsHaveDistinctSlugs(t *testing.T) {
+	// A model this small only knows three titles, so some of the grid's seeds
+	// share one
+	chain, err := BuildModel("Cats sat. Dogs ran. Birds sang. Fish swam.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Finding the last free title can take more retries than the default
+	old := MaxRegenerations
+	MaxRegenerations = 50
+	t.Cleanup(func() { MaxRegenerations = old })
+
+	const count = 3
+	baseSeed := int64(20000)
+	firstDraws := map[string]bool{}
+	for i := 0; i < count; i++ {
+		link, err := PostLink(baseSeed+int64(i*1000), chain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		firstDraws[link.Slug] = true
+	}
+	if len(firstDraws) == count {
+		t.Fatal("the first draws don't collide, so the test proves nothing")
+	}
+
+	posts, err := generatePosts(chain, baseSeed, count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// URLs start with the seed, so compare the titles they end with
+	slugs := map[string]bool{}
+	for _, post := range posts {
+		if slugs[post.Link.Slug] {
+			t.Errorf("%s repeats the title of another post", post.Link.Url)
+		}
+		slugs[post.Link.Slug] = true
+	}
+}
+
+func TestPostDateWindow(t *testing.T) {
+	chain, err := BuildModel("The cat saw the dog. The dog ran home. A bird sang all day.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := 24 * time.Hour
+	tests := []struct {
+		name           string
+		maxAge, minAge time.Duration
+	}{
+		{name: "default", maxAge: PostDateMaxAge, minAge: PostDateMinAge},
+		{name: "last week", maxAge: 7 * day},
+		{name: "a month ago", maxAge: 31 * day, minAge: 30 * day},
+	}
+	for _, tt := range tests {
+		opts := chain.DefaultOptions()
+		opts.PostDateMaxAge, opts.PostDateMinAge = tt.maxAge, tt.minAge
+		today := time.Now().UTC().Truncate(day)
+		oldest, newest := today.Add(-tt.maxAge), today.Add(-tt.minAge)
+		for seed := int64(0); seed < 50; seed++ {
+			page, err := GeneratePageWithOptions(seed, chain, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.LastUpdated.Before(oldest) || page.LastUpdated.After(newest) {
+				t.Errorf("%s: seed %d is dated %s, outside %s to %s", tt.name, seed, page.LastUpdated, oldest, newest)
+			}
+			again, err := GeneratePageWithOptions(seed, chain, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !again.LastUpdated.Equal(page.LastUpdated) {
+				t.Errorf("%s: seed %d is dated %s and then %s", tt.name, seed, page.LastUpdated, again.LastUpdated)
+			}
+		}
+	}
+}
+
+func TestTitleModelTitlesAreShort(t *testing.T) {
+	withMaxSentenceWords(t, 0)
+	input := `Night falls. The old keeper climbed the winding stairs to light the great lamp before the storm reached the rocks below the tower.
+
+Cold harbor. The fishing boats rocked against the pier while the keeper counted the lights of the ships waiting far out past the breakwater.
+
+Ships return. Every morning the keeper walked the long road down to the harbor to count the boats that had come safely home in the night.`
+	plain, err := BuildModel(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := BuildModelWithOptions(input, TrainOptions{Titles: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both chains must survive a round trip through the model blob
+	data, err := SerializeModel(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadModel(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain.HasTitleModel() || !chain.HasTitleModel() || !loaded.HasTitleModel() {
+		t.Fatalf("title models: plain %v, built %v, loaded %v", plain.HasTitleModel(), chain.HasTitleModel(), loaded.HasTitleModel())
+	}
+
+	// averageWords returns the mean words per title and per body sentence
+	averageWords := func(chain MarkovChain) (title, body float64) {
+		var titleWords, titles, bodyWords, sentences int
+		for seed := int64(1); seed <= 20; seed++ {
+			page, err := GeneratePage(seed, chain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			titleWords += len(strings.Fields(page.Link.Title))
+			titles++
+			for _, sentence := range splitSentences(strings.Fields(page.Content)) {
+				bodyWords += len(sentence)
+				sentences++
+			}
+		}
+		return float64(titleWords) / float64(titles), float64(bodyWords) / float64(sentences)
+	}
+	for name, chain := range map[string]MarkovChain{"built": chain, "loaded": loaded} {
+		title, body := averageWords(chain)
+		if title > 2 || title >= body/2 {
+			t.Errorf("%s: titles average %.1f words against %.1f per body sentence", name, title, body)
+		}
+	}
+	if title, _ := averageWords(plain); title <= 2 {
+		t.Errorf("titles from the body chain average only %.1f words", title)
+	}
+}
+
+func TestRepetitionPenaltyRepeatsRareTokensLess(t *testing.T) {
+	withMaxSentenceWords(t, 0)
+	chain, err := BuildModel("The cat saw zebras. The cat saw birds. The dog saw birds. The dog saw cats. A dog saw birds. A cat saw cats.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// countRare returns how often the rare token appears across many pages
+	countRare := func(penalty float64) int {
+		opts := chain.DefaultOptions()
+		opts.RepetitionPenalty = penalty
+		count := 0
+		for seed := int64(1); seed <= 50; seed++ {
+			page, err := GeneratePageWithOptions(seed, chain, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			again, err := GeneratePageWithOptions(seed, chain, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.Content != page.Content {
+				t.Fatalf("penalty %v: seed %d generated %q, then %q", penalty, seed, page.Content, again.Content)
+			}
+			count += strings.Count(page.Content, "zebras")
+		}
+		return count
+	}
+	plain, penalized := countRare(0), countRare(1)
+	if plain == 0 || penalized >= plain {
+		t.Errorf("the rare token appeared %d times with the penalty and %d without", penalized, plain)
+	}
+}
+
+func TestTruncateWords(t *testing.T) {
+	tests := []struct {
+		text     string
+		maxWords int
+		want     string
+	}{
+		{"The cat sat on the mat.", 3, "The cat sat…"},
+		{"The cat, the dog, the bird.", 2, "The cat…"},
+		{`He said "stop" twice.`, 3, `He said "stop"…`},
+		{"The cat sat.", 3, "The cat sat."},
+		{"The cat sat on the mat.", 0, "The cat sat on the mat."},
+	}
+	for _, tt := range tests {
+		if got := truncateWords(tt.text, tt.maxWords); got != tt.want {
+			t.Errorf("truncateWords(%q, %d) = %q, want %q", tt.text, tt.maxWords, got, tt.want)
+		}
+	}
+}
+
+func TestLinkTitleMaxWords(t *testing.T) {
+	withMaxSentenceWords(t, 0)
+	chain, err := BuildModel("The quick brown fox jumps over lazy sleeping dogs near quiet rivers. The ancient lighthouse keeper climbed winding stairs every stormy night. Short one.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := chain.DefaultOptions()
+	opts.MinLinks, opts.MaxLinks = 3, 3
+	opts.LinkTitleMaxWords = 4
+	for seed := int64(1); seed <= 10; seed++ {
+		page, err := GeneratePageWithOptions(seed, chain, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if whole, _ := PostLink(seed, chain); page.Link != whole {
+			t.Errorf("seed %d: the page's own title was shortened to %q", seed, page.Link.Title)
+		}
+		for _, link := range page.Links {
+			// The link must still point at the page with the whole title
+			whole, err := PostLink(link.Seed, chain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if link.Url != whole.Url || link.Slug != whole.Slug || len(link.Slug) > MaxSlugLength {
+				t.Errorf("seed %d: link %+v doesn't point at %+v", seed, link, whole)
+			}
+			long := len(strings.Fields(whole.Title)) > opts.LinkTitleMaxWords
+			if words := len(strings.Fields(link.Title)); words > opts.LinkTitleMaxWords || long != strings.HasSuffix(link.Title, "…") {
+				t.Errorf("seed %d: %q is displayed as %q", seed, whole.Title, link.Title)
+			}
+		}
+	}
+}
+
+func TestTruncateAtWord(t *testing.T) {
+	tests := []struct {
+		text     string
+		maxBytes int
+		want     string
+	}{
+		{"The cat sat on the mat.", 100, "The cat sat on the mat."},
+		{"The cat sat on the mat.", 10, "The cat"},
+		{"The cat sat on the mat.", 11, "The cat sat"},
+		{"The cat sat on the mat.", 12, "The cat sat"},
+		// Never inside a multi-byte rune
+		{"Café crème brûlée", 13, "Café crème"},
+		{"Café crème brûlée", 4, "Caf"},
+		// A first word that doesn't fit is cut where it must be
+		{"Supercalifragilistic", 5, "Super"},
+	}
+	for _, tt := range tests {
+		if got := truncateAtWord(tt.text, tt.maxBytes); got != tt.want {
+			t.Errorf("truncateAtWord(%q, %d) = %q, want %q", tt.text, tt.maxBytes, got, tt.want)
+		}
+	}
+}
+
+func TestSlugEndsOnWholeWord(t *testing.T) {
+	withMaxSentenceWords(t, 0)
+	chain, err := BuildModel("The quick brown fox jumps over lazy sleeping dogs near quiet rivers. The ancient lighthouse keeper climbed winding stairs every stormy night.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := MaxSlugLength
+	t.Cleanup(func() { MaxSlugLength = old })
+	for _, limit := range []int{64, 30, 17} {
+		MaxSlugLength = limit
+		for seed := int64(1); seed <= 10; seed++ {
+			link, err := PostLink(seed, chain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			titleWords := strings.Split(Slugify(link.Title), "-")
+			slugWords := strings.Split(link.Slug, "-")
+			if len(link.Slug) > limit || !slices.Equal(slugWords, titleWords[:len(slugWords)]) {
+				t.Errorf("limit %d: %q got slug %q", limit, link.Title, link.Slug)
+			}
+		}
+	}
+}
+
+// titleCounter counts the titles generated from a title chain, which is one
+// per page when pages have no related links
+type titleCounter struct {
+	ChainBackend
+	titles *int
+}
+
+func (c titleCounter) GenerateDeterministic(current gomarkov.NGram, prng gomarkov.PRNG) (string, error) {
+	if current[len(current)-1] == DefaultSentinels.Start {
+		*c.titles++
+	}
+	return c.ChainBackend.GenerateDeterministic(current, prng)
+}
+
+func TestRetryBudgetIsRespected(t *testing.T) {
+	oldRegenerations, oldMinWords := MaxRegenerations, HomePageMinWords
+	t.Cleanup(func() { MaxRegenerations, HomePageMinWords = oldRegenerations, oldMinWords })
+	// One sentence can never make a unique or long enough post
+	HomePageMinWords = 1000
+	pages := 0
+	chain := NewMarkovChain(newGomarkovBackend(1), titleCounter{newGomarkovBackend(1), &pages}, TrainOptions{Titles: true})
+	if err := AddTextToModel(chain, "Hi there."); err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultGenerateOptions()
+	opts.MinLinks, opts.MaxLinks = 0, 0
+	chain = chain.WithGenerateOptions(opts)
+
+	for _, budget := range []int{0, 1, 4} {
+		MaxRegenerations = budget
+		pages = 0
+		post, err := GenerateFeaturedPost(chain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := 1 + budget; pages != want || post.Content == "" {
+			t.Errorf("budget %d: the featured post took %d pages, want %d", budget, pages, want)
+		}
+
+		// The length and uniqueness checks share each post's budget
+		pages = 0
+		posts, err := GenerateHomePagePosts(chain, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := 3 * (1 + budget); pages != want || len(posts) != 3 {
+			t.Errorf("budget %d: %d home page posts took %d pages, want %d", budget, len(posts), pages, want)
+		}
+	}
+}
+
+func TestHomePageMinWords(t *testing.T) {
+	oldRegenerations, oldMinWords := MaxRegenerations, HomePageMinWords
+	t.Cleanup(func() { MaxRegenerations, HomePageMinWords = oldRegenerations, oldMinWords })
+	// Every sentence is one word, so some cards are only a word or two long
+	// and most titles collide, spending the budget on unique slugs too
+	chain := NewMarkovChain(newGomarkovBackend(1), newGomarkovBackend(1), TrainOptions{Titles: true})
+	if err := AddTextToModel(chain, "Hi. Yes. Ok. No."); err != nil {
+		t.Fatal(err)
+	}
+	words := func(post GeneratedPage) int { return len(strings.Fields(post.Content)) }
+	const count, minWords = 20, 4
+
+	posts, err := generatePosts(chain, 0, count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(posts, func(post GeneratedPage) bool { return words(post) < minWords }) {
+		t.Fatalf("no card is under %d words without a minimum, so the test proves nothing", minWords)
+	}
+
+	HomePageMinWords = minWords
+	MaxRegenerations = 20
+	posts, err = generatePosts(chain, 0, count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, post := range posts {
+		if words(post) < minWords {
+			t.Errorf("card %d has %d words, want at least %d: %q", i, words(post), minWords, post.Content)
+		}
+	}
+
+	// An unreachable minimum spends the budget and still fills the grid
+	HomePageMinWords = 1000
+	MaxRegenerations = 2
+	posts, err = generatePosts(chain, 0, count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != count {
+		t.Fatalf("got %d cards, want %d", len(posts), count)
+	}
+	for i, post := range posts {
+		if words(post) == 0 {
+			t.Errorf("card %d is empty once the budget is spent", i)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package train
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// chainData mirrors gomarkov's serialized chain so its transition counts can
+// be inspected and rewritten
+type chainData struct {
+	Order    int                 `json:"int"`
+	SpoolMap map[string]int      `json:"spool_map"`
+	FreqMat  map[int]map[int]int `json:"freq_mat"`
+}
+
+func exportChain(chain MarkovChain) (chainData, error) {
+	return exportBackend(chain.chain)
+}
+
+func exportBackend(backend ChainBackend) (chainData, error) {
+	var data chainData
+	raw, err := marshalBackend(backend)
+	if err != nil {
+		return chainData{}, err
+	}
+	err = json.Unmarshal(raw, &data)
+	return data, err
+}
+
+func importChain(data chainData) (ChainBackend, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalGomarkovBackend(raw)
+}
+
+// PruneModel drops transitions observed fewer than minCount times. A state
+// whose transitions would all be dropped keeps them, so pruning never creates
+// a dead end. Sampling uses the remaining counts, so probabilities are
+// re-normalized implicitly. Tokens no longer reachable are removed.
+//
+// The title chain is pruned the same way. The bigram chain of a backoff model
+// loses its rare transitions and every state or transition that mentions a
+// token the body chain dropped; a bigram state left with no transitions is
+// removed, since generation falls back to the body chain. The body and title
+// chains must be order 1, or ErrUnsupportedOrder is returned.
+func PruneModel(chain MarkovChain, minCount int) (MarkovChain, error) {
+	sentinels := chain.Sentinels()
+	data, err := exportChain(chain)
+	if err != nil {
+		return MarkovChain{}, err
+	}
+	body, err := pruneChainData(data, sentinels, minCount)
+	if err != nil {
+		return MarkovChain{}, err
+	}
+	result := chain
+	if result.chain, err = importChain(body); err != nil {
+		return MarkovChain{}, err
+	}
+
+	if chain.titles != nil {
+		data, err := exportBackend(chain.titles)
+		if err != nil {
+			return MarkovChain{}, err
+		}
+		titles, err := pruneChainData(data, sentinels, minCount)
+		if err != nil {
+			return MarkovChain{}, err
+		}
+		if result.titles, err = importChain(titles); err != nil {
+			return MarkovChain{}, err
+		}
+	}
+
+	if chain.bigrams != nil {
+		data, err := exportBackend(chain.bigrams)
+		if err != nil {
+			return MarkovChain{}, err
+		}
+		if result.bigrams, err = importChain(pruneBigramData(data, body.SpoolMap, minCount)); err != nil {
+			return MarkovChain{}, err
+		}
+	}
+	return result, nil
+}
+
+// pruneChainData is PruneModel for one order 1 chain, whose states are its
+// tokens
+func pruneChainData(data chainData, sentinels Sentinels, minCount int) (chainData, error) {
+	if data.Order != 1 {
+		return chainData{}, fmt.Errorf("%w: can only prune order 1 chains, got order %d", ErrUnsupportedOrder, data.Order)
+	}
+
+	pruned := map[int]map[int]int{}
+	for state, transitions := range data.FreqMat {
+		pruned[state] = keepFrequent(transitions, minCount)
+	}
+
+	// Keep only tokens still reachable from the start token, plus the sentinels
+	used := map[int]bool{}
+	for _, token := range []string{sentinels.Start, sentinels.End} {
+		if index, ok := data.SpoolMap[token]; ok {
+			used[index] = true
+		}
+	}
+	queue := []int{}
+	if start, ok := data.SpoolMap[sentinels.Start]; ok {
+		queue = append(queue, start)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for next := range pruned[state] {
+			if !used[next] {
+				used[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return renumberChainData(data, pruned, used), nil
+}
+
+// pruneBigramData prunes a bigram chain to match a pruned body chain whose
+// tokens are vocabulary: rare transitions go, as do states and transitions
+// that use a token outside vocabulary. States left without transitions are
+// removed.
+func pruneBigramData(data chainData, vocabulary map[string]int, minCount int) chainData {
+	tokens := make(map[int]string, len(data.SpoolMap))
+	for token, index := range data.SpoolMap {
+		tokens[index] = token
+	}
+	known := func(token string) bool {
+		_, ok := vocabulary[token]
+		return ok
+	}
+
+	pruned := map[int]map[int]int{}
+	used := map[int]bool{}
+	for state, transitions := range data.FreqMat {
+		if !knownPair(tokens[state], known) {
+			continue
+		}
+		kept := map[int]int{}
+		for next, count := range keepFrequent(transitions, minCount) {
+			if known(tokens[next]) {
+				kept[next] = count
+			}
+		}
+		if len(kept) == 0 {
+			continue
+		}
+		pruned[state] = kept
+		used[state] = true
+		for next := range kept {
+			used[next] = true
+		}
+	}
+	return renumberChainData(data, pruned, used)
+}
+
+// keepFrequent returns the transitions seen at least minCount times, or all
+// of them when none are
+func keepFrequent(transitions map[int]int, minCount int) map[int]int {
+	kept := map[int]int{}
+	for next, count := range transitions {
+		if count >= minCount {
+			kept[next] = count
+		}
+	}
+	if len(kept) == 0 {
+		return transitions
+	}
+	return kept
+}
+
+// knownPair reports whether key, an order 2 state as gomarkov joins it,
+// splits into two tokens that known accepts. Tokens may contain the
+// separator themselves, so every split is tried.
+func knownPair(key string, known func(string) bool) bool {
+	for i := 0; i < len(key); i++ {
+		if key[i] == '_' && known(key[:i]) && known(key[i+1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// renumberChainData keeps the used entries of data with the transitions in
+// pruned, renumbering them densely so later training can't reuse an index
+func renumberChainData(data chainData, pruned map[int]map[int]int, used map[int]bool) chainData {
+	oldIndexes := make([]int, 0, len(used))
+	for index := range used {
+		oldIndexes = append(oldIndexes, index)
+	}
+	sort.Ints(oldIndexes)
+	renumber := make(map[int]int, len(oldIndexes))
+	for newIndex, oldIndex := range oldIndexes {
+		renumber[oldIndex] = newIndex
+	}
+
+	out := chainData{
+		Order:    data.Order,
+		SpoolMap: map[string]int{},
+		FreqMat:  map[int]map[int]int{},
+	}
+	for token, index := range data.SpoolMap {
+		if newIndex, ok := renumber[index]; ok {
+			out.SpoolMap[token] = newIndex
+		}
+	}
+	for state, transitions := range pruned {
+		newState, ok := renumber[state]
+		if !ok {
+			continue
+		}
+		remapped := map[int]int{}
+		for next, count := range transitions {
+			remapped[renumber[next]] = count
+		}
+		out.FreqMat[newState] = remapped
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+package train
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// pruneCorpus has common words, repeated in every sentence, and rare ones
+// seen once
+const pruneCorpus = "The cat sat on the mat.\n\nThe cat sat on the rug.\n\nThe cat sat on the mat.\n\nA zebra grazed quietly."
+
+// vocabulary returns the tokens of backend's spool
+func vocabulary(t *testing.T, backend ChainBackend) map[string]int {
+	t.Helper()
+	data, err := exportBackend(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data.SpoolMap
+}
+
+func TestPruneModel(t *testing.T) {
+	tests := []struct {
+		name string
+		opts TrainOptions
+	}{
+		{name: "body only"},
+		{name: "titles", opts: TrainOptions{Titles: true}},
+		{name: "backoff", opts: TrainOptions{Backoff: true}},
+		{name: "titles and backoff", opts: TrainOptions{Titles: true, Backoff: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := BuildModelWithOptions(pruneCorpus, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pruned, err := PruneModel(chain, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body := vocabulary(t, pruned.chain)
+			for _, rare := range []string{"zebra", "rug."} {
+				if _, ok := body[rare]; ok {
+					t.Errorf("body chain still has %q", rare)
+				}
+			}
+			if pruned.titles != nil {
+				if _, ok := vocabulary(t, pruned.titles)["zebra"]; ok {
+					t.Errorf("title chain still has %q", "zebra")
+				}
+			}
+			if pruned.bigrams != nil {
+				for key := range vocabulary(t, pruned.bigrams) {
+					if strings.Contains(key, "zebra") || strings.Contains(key, "rug.") {
+						t.Errorf("bigram chain still has %q", key)
+					}
+				}
+				before, _ := Stats(chain)
+				after, _ := Stats(pruned)
+				if after.BigramTransitions >= before.BigramTransitions {
+					t.Errorf("bigram transitions %d -> %d, want fewer", before.BigramTransitions, after.BigramTransitions)
+				}
+			}
+
+			for seed := int64(0); seed < 20; seed++ {
+				if _, err := GeneratePage(seed, pruned); err != nil {
+					t.Fatalf("seed %d: %v", seed, err)
+				}
+			}
+		})
+	}
+}
+
+func TestPruneModelRejectsHigherOrders(t *testing.T) {
+	backend := newGomarkovBackend(2)
+	backend.Add([]string{"the", "cat", "sat"})
+	chain := NewMarkovChain(backend, nil, TrainOptions{})
+	if _, err := PruneModel(chain, 2); !errors.Is(err, ErrUnsupportedOrder) {
+		t.Errorf("got %v, want ErrUnsupportedOrder", err)
+	}
+}
+
+func TestStatsIncludesBigrams(t *testing.T) {
+	tests := []struct {
+		backoff   bool
+		wantOrder int
+	}{
+		{backoff: false, wantOrder: 1},
+		{backoff: true, wantOrder: 2},
+	}
+	for _, tt := range tests {
+		chain, err := BuildModelWithOptions(pruneCorpus, TrainOptions{Backoff: tt.backoff})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats, err := Stats(chain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Order != tt.wantOrder {
+			t.Errorf("backoff %v: order %d, want %d", tt.backoff, stats.Order, tt.wantOrder)
+		}
+		if got := stats.BigramTransitions > 0; got != tt.backoff {
+			t.Errorf("backoff %v: bigram transitions %d", tt.backoff, stats.BigramTransitions)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package train
+
+// ModelStats summarizes the size of a model
+type ModelStats struct {
+	// Order is the number of tokens each state is keyed on
+	Order int `json:"order"`
+
+	// Vocabulary is the number of distinct words, excluding sentinels and
+	// paragraph markers
+	Vocabulary int `json:"vocabulary"`
+
+	// Transitions is the number of distinct state-to-token transitions
+	Transitions int `json:"transitions"`
+
+	// BigramStates and BigramTransitions size the two-word chain of a model
+	// trained with backoff, which Order then reports
+	BigramStates      int `json:"bigram_states,omitempty"`
+	BigramTransitions int `json:"bigram_transitions,omitempty"`
+}
+
+// Stats returns the size of the model's body chain and, for backoff models,
+// its bigram chain
+func Stats(chain MarkovChain) (ModelStats, error) {
+	data, err := exportChain(chain)
+	if err != nil {
+		return ModelStats{}, err
+	}
+
+	sentinels := chain.Sentinels()
+	stats := ModelStats{Order: data.Order}
+	for token := range data.SpoolMap {
+		if token != sentinels.Start && token != sentinels.End && token != ParagraphMarker {
+			stats.Vocabulary++
+		}
+	}
+	for _, transitions := range data.FreqMat {
+		stats.Transitions += len(transitions)
+	}
+
+	if chain.bigrams != nil {
+		bigrams, err := exportBackend(chain.bigrams)
+		if err != nil {
+			return ModelStats{}, err
+		}
+		stats.Order = max(stats.Order, bigrams.Order)
+		stats.BigramStates = len(bigrams.FreqMat)
+		for _, transitions := range bigrams.FreqMat {
+			stats.BigramTransitions += len(transitions)
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,43 @@
+package train
+
+import (
+	"fmt"
+	"math"
+)
+
+// Theme colors share a saturation and lightness, dark enough for white text,
+// so only the hue varies from post to post
+const (
+	themeSaturation = 0.65
+	themeLightness  = 0.38
+)
+
+// ThemeColorForSeed returns the accent color of the post for seed as a hex
+// color like "#2a7bb0". Like AuthorForSeed it depends only on the seed, but
+// it uses the high bits of the mixed seed so a post's color doesn't follow
+// its author.
+func ThemeColorForSeed(seed int64) string {
+	hue := float64((mixSeed(seed)>>32)%360) / 60
+	chroma := (1 - math.Abs(2*themeLightness-1)) * themeSaturation
+	x := chroma * (1 - math.Abs(math.Mod(hue, 2)-1))
+	var r, g, b float64
+	switch int(hue) {
+	case 0:
+		r, g, b = chroma, x, 0
+	case 1:
+		r, g, b = x, chroma, 0
+	case 2:
+		r, g, b = 0, chroma, x
+	case 3:
+		r, g, b = 0, x, chroma
+	case 4:
+		r, g, b = x, 0, chroma
+	default:
+		r, g, b = chroma, 0, x
+	}
+	m := themeLightness - chroma/2
+	channel := func(v float64) int {
+		return int(math.Round((v + m) * 255))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", channel(r), channel(g), channel(b))
+}
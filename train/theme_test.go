@@ -0,0 +1,25 @@
+package train
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestThemeColorForSeed(t *testing.T) {
+	hexColor := regexp.MustCompile(`^#[0-9a-f]{6}$`)
+	colors := map[string]bool{}
+	for seed := int64(0); seed < 100; seed++ {
+		color := ThemeColorForSeed(seed)
+		if !hexColor.MatchString(color) {
+			t.Errorf("seed %d has color %q, want #rrggbb", seed, color)
+		}
+		if again := ThemeColorForSeed(seed); again != color {
+			t.Errorf("seed %d has color %s and then %s", seed, color, again)
+		}
+		colors[color] = true
+	}
+	// Hues are whole degrees, so some neighbouring seeds may share one
+	if len(colors) < 50 {
+		t.Errorf("100 seeds have only %d colors", len(colors))
+	}
+}
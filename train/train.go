@@ -4,20 +4,182 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"regexp"
 	"slices"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/mb-14/gomarkov"
+	"golang.org/x/text/unicode/norm"
 )
 
+// MaxStoryTokens caps how many tokens a single generation may produce before
+// giving up, so a corrupt or degenerate model can't loop forever.
+const MaxStoryTokens = 1000
+
+// ClampToSentence makes a generation that hits MaxStoryTokens return the text
+// up to its last complete sentence instead of an error
+var ClampToSentence = false
+
+// MaxSentenceWords ends a generated sentence after this many words, so a
+// rambling chain can't produce unreadable titles and paragraphs. The cut
+// sentence gets a terminator if it lacks one. Zero disables it.
+var MaxSentenceWords = 0
+
+// SentenceTerminators end a sentence when a word ends with one of them,
+// optionally followed by ClosingPunctuation
+var SentenceTerminators = []string{".", "!", "?", "…", "。", "！", "？"}
+
+// ClosingPunctuation may follow a terminator at the end of a sentence, as in `"Stop."`
+const ClosingPunctuation = `"')]}’”»」』`
+
+// Sentinels are the tokens that mark the start and end of a sentence in a chain
+type Sentinels struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// DefaultSentinels are the tokens gomarkov wraps around every trained sentence
+var DefaultSentinels = Sentinels{Start: gomarkov.StartToken, End: gomarkov.EndToken}
+
+// ParagraphMarker is appended to the last sentence of each paragraph when a
+// model is trained with TrainOptions.Paragraphs
+const ParagraphMarker = "¶"
+
 type MarkovChain struct {
-	chain *gomarkov.Chain
+	chain      ChainBackend
+	titles     ChainBackend
+	bigrams    ChainBackend
+	sentinels  Sentinels
+	lowercase  bool
+	paragraphs bool
+	headings   bool
+	normalize  bool
+	// options are the saved generation defaults, see WithGenerateOptions
+	options *GenerateOptions
+}
+
+// TrainOptions controls how input text is tokenized when building a model
+type TrainOptions struct {
+	// Lowercase folds every token to lower case so "The" and "the" share a
+	// state. Generated sentences are re-capitalized.
+	Lowercase bool
+
+	// Paragraphs treats blank lines as paragraph boundaries and records them
+	// with ParagraphMarker so generated pages can break where the input did
+	Paragraphs bool
+
+	// Titles trains a second chain on the first sentence of each blank-line
+	// separated block, which is used for post titles instead of the body chain
+	Titles bool
+
+	// StripHeadings drops lines matching HeadingPatterns, such as chapter
+	// headings and page numbers, before the text is tokenized
+	StripHeadings bool
+
+	// Backoff trains an order-2 chain alongside the order-1 body chain.
+	// Generation prefers the two-word context and backs off to the last word
+	// alone when that pair was never seen.
+	Backoff bool
+
+	// Normalize applies NFKC and maps curly quotes, dashes and other unicode
+	// punctuation to ASCII, so ebook text doesn't split a word into several
+	// states. Off by default to keep the corpus as written, but recommended.
+	Normalize bool
+}
+
+// romanNumeral matches a well formed roman numeral below 5000. Each
+// alternative starts at a different place value and requires a numeral
+// there, so it never matches an empty string.
+const romanNumeral = `m{1,4}(?:cm|cd|d?c{0,3})(?:xc|xl|l?x{0,3})(?:ix|iv|v?i{0,3})` +
+	`|(?:cm|cd|d|d?c{1,3})(?:xc|xl|l?x{0,3})(?:ix|iv|v?i{0,3})` +
+	`|(?:xc|xl|l|l?x{1,3})(?:ix|iv|v?i{0,3})` +
+	`|ix|iv|v|v?i{1,3}`
+
+// HeadingPatterns match whole lines that TrainOptions.StripHeadings removes
+var HeadingPatterns = []*regexp.Regexp{
+	// Chapter and section headings like "CHAPTER I" or "Part 2: The Return".
+	// The number must be digits or a well formed roman numeral, ending the
+	// line or followed by a colon, so prose like "Part civil war" is kept.
+	regexp.MustCompile(`(?i)^\s*(chapter|part|book|section)\s+([0-9]+|` + romanNumeral + `)\.?\s*(:.*)?$`),
+	// Lines shouting in all caps, e.g. running headers
+	regexp.MustCompile(`^[^\p{Ll}]*\p{Lu}[^\p{Ll}]*$`),
+	// Bare page numbers
+	regexp.MustCompile(`^\s*[0-9]+\s*$`),
+}
+
+// Model formats LoadModel dispatches on. Bump ModelFormat and add a case to
+// LoadModel when a change to modelBlob can't be read by older code.
+const (
+	// legacyModelFormat is a bare gomarkov chain, saved before modelBlob
+	// existed
+	legacyModelFormat = 0
+	// blobModelFormat is modelBlob. Blobs saved before the format field was
+	// added have none, but are this format.
+	blobModelFormat = 1
+
+	// ModelFormat is the format SerializeModel writes
+	ModelFormat = blobModelFormat
+)
+
+// modelBlob is the serialized form of a MarkovChain. Models saved before it
+// existed are a bare gomarkov chain, which LoadModel still accepts.
+type modelBlob struct {
+	Format     int              `json:"format,omitempty"`
+	Chain      json.RawMessage  `json:"chain"`
+	Titles     json.RawMessage  `json:"titles,omitempty"`
+	Bigrams    json.RawMessage  `json:"bigrams,omitempty"`
+	Lowercase  bool             `json:"lowercase,omitempty"`
+	Paragraphs bool             `json:"paragraphs,omitempty"`
+	Headings   bool             `json:"strip_headings,omitempty"`
+	Normalize  bool             `json:"normalize,omitempty"`
+	Generate   *GenerateOptions `json:"generate,omitempty"`
+	// Sentinels are recorded only for models imported with delimiters other
+	// than DefaultSentinels
+	Sentinels *Sentinels `json:"sentinels,omitempty"`
+}
+
+// Sentinels returns the start and end tokens used when generating from the chain
+func (m MarkovChain) Sentinels() Sentinels {
+	if m.sentinels == (Sentinels{}) {
+		return DefaultSentinels
+	}
+	return m.sentinels
+}
+
+// HasTitleModel reports whether the model carries a dedicated title chain
+func (m MarkovChain) HasTitleModel() bool {
+	return m.titles != nil
+}
+
+// titleModel returns the chain titles are generated from: the dedicated
+// title chain when present, otherwise the body chain itself
+func (m MarkovChain) titleModel() MarkovChain {
+	if m.titles == nil {
+		return m
+	}
+	titles := m
+	titles.chain = m.titles
+	titles.bigrams = nil
+	return titles
 }
 
 func BuildModel(input string) (MarkovChain, error) {
-	chain := gomarkov.NewChain(1)
+	return BuildModelWithOptions(input, TrainOptions{})
+}
+
+// BuildModelWithOptions builds a model from input using the given tokenization options
+func BuildModelWithOptions(input string, opts TrainOptions) (MarkovChain, error) {
 	//i should probably split out punctionation, todo
-	chainOut := MarkovChain{chain: chain}
+	var titles ChainBackend
+	if opts.Titles {
+		titles = newGomarkovBackend(1)
+	}
+	chainOut := NewMarkovChain(newGomarkovBackend(1), titles, opts)
+	if opts.Backoff {
+		chainOut.bigrams = newGomarkovBackend(2)
+	}
 	err := AddTextToModel(chainOut, input)
 	if err != nil {
 		return MarkovChain{}, err
@@ -27,65 +189,525 @@ func BuildModel(input string) (MarkovChain, error) {
 
 // AddTextToModel adds additional text to an existing markov chain model
 func AddTextToModel(chain MarkovChain, input string) error {
-	terminatingPunctuation := []string{".", "!", "?"}
+	for _, sentences := range chain.tokenize(input) {
+		if chain.titles != nil && len(sentences) > 0 {
+			chain.titles.Add(sentences[0])
+		}
+		for i, sentence := range sentences {
+			if chain.paragraphs && i == len(sentences)-1 {
+				sentence = append(sentence, ParagraphMarker)
+			}
+			chain.chain.Add(sentence)
+			if chain.bigrams != nil {
+				chain.bigrams.Add(sentence)
+			}
+			fmt.Println(strings.Join(sentence, " "))
+		}
+	}
+
+	return nil
+}
+
+// asciiPunctuation maps the unicode punctuation NFKC leaves alone to ASCII
+var asciiPunctuation = strings.NewReplacer(
+	"‘", "'", "’", "'", "‚", "'", "‛", "'",
+	"“", `"`, "”", `"`, "„", `"`, "‟", `"`, "«", `"`, "»", `"`,
+	"‐", "-", "‑", "-", "‒", "-", "–", "-", "—", "--", "―", "--",
+	"\u00ad", "", "\u200b", "", "\ufeff", "",
+)
+
+// normalizeText applies NFKC, which also turns non-breaking spaces into
+// spaces and "…" into "...", then maps quotes and dashes to ASCII
+func normalizeText(input string) string {
+	return asciiPunctuation.Replace(norm.NFKC.String(input))
+}
+
+// tokenize is tokenizeText with the options the chain was trained with
+func (m MarkovChain) tokenize(input string) [][][]string {
+	return tokenizeText(input, TrainOptions{
+		Lowercase:     m.lowercase,
+		Paragraphs:    m.paragraphs,
+		Titles:        m.titles != nil,
+		StripHeadings: m.headings,
+		Normalize:     m.normalize,
+	})
+}
+
+// tokenizeText prepares input as opts ask and splits it into paragraphs of
+// sentences of words. Input is a single paragraph unless opts learn
+// paragraph breaks or titles.
+func tokenizeText(input string, opts TrainOptions) [][][]string {
+	if opts.Normalize {
+		input = normalizeText(input)
+	}
+	if opts.StripHeadings {
+		input = stripHeadings(input)
+	}
+	if opts.Lowercase {
+		input = strings.ToLower(input)
+	}
+
+	paragraphs := []string{input}
+	if opts.Paragraphs || opts.Titles {
+		paragraphs = blankLines.Split(input, -1)
+	}
 	// now loop over fields, grouping by sentence, meaning gorup until a period is found.
-	words := strings.Fields(input)
+	tokenized := make([][][]string, len(paragraphs))
+	for i, paragraph := range paragraphs {
+		tokenized[i] = splitSentences(strings.Fields(paragraph))
+	}
+	return tokenized
+}
+
+// stripHeadings removes every line matching one of HeadingPatterns, keeping
+// blank lines so paragraph boundaries survive
+func stripHeadings(input string) string {
+	lines := strings.Split(input, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" && slices.ContainsFunc(HeadingPatterns, func(pattern *regexp.Regexp) bool {
+			return pattern.MatchString(line)
+		}) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n")
+}
+
+// blankLines matches the whitespace between paragraphs
+var blankLines = regexp.MustCompile(`\n[ \t\r]*\n\s*`)
+
+// splitSentences groups words into sentences ending in terminating punctuation.
+// Trailing words without punctuation form a final sentence.
+func splitSentences(words []string) [][]string {
+	sentences := [][]string{}
 	lastIndex := 0
 	for i := 0; i < len(words); i++ {
-		word := words[i]
-		lastChar := word[len(word)-1:]
-		if slices.Contains(terminatingPunctuation, lastChar) {
-			chain.chain.Add(words[lastIndex : i+1])
-			fmt.Println(strings.Join(words[lastIndex:i+1], " "))
+		if EndsSentence(words[i]) {
+			sentences = append(sentences, words[lastIndex:i+1])
 			lastIndex = i + 1
 		}
 	}
 	if lastIndex < len(words) {
-		chain.chain.Add(words[lastIndex:])
+		sentences = append(sentences, words[lastIndex:])
 	}
-
-	return nil
+	return sentences
 }
 
+// LoadModel reads a model saved by SerializeModel in any format this build
+// knows, from bare legacy chains up to ModelFormat
 func LoadModel(data []byte) (MarkovChain, error) {
-	var chain gomarkov.Chain
-	err := json.Unmarshal(data, &chain)
+	return LoadImportedModel(data, DefaultSentinels)
+}
+
+// LoadImportedModel is LoadModel for models trained elsewhere, whose chains
+// are delimited by sentinels unless the model records its own. Serializing
+// the result records the sentinels, so LoadModel reads it afterwards.
+func LoadImportedModel(data []byte, sentinels Sentinels) (MarkovChain, error) {
+	var header struct {
+		Format *int            `json:"format"`
+		Chain  json.RawMessage `json:"chain"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return MarkovChain{}, fmt.Errorf("%w: %v", ErrCorruptModel, err)
+	}
+	format := blobModelFormat
+	switch {
+	case header.Format != nil:
+		format = *header.Format
+	case header.Chain == nil:
+		format = legacyModelFormat
+	}
+
+	switch format {
+	case legacyModelFormat:
+		// The data is the gomarkov chain itself
+		return loadModelBlob(modelBlob{Chain: data}, sentinels)
+	case blobModelFormat:
+		var blob modelBlob
+		if err := json.Unmarshal(data, &blob); err != nil {
+			return MarkovChain{}, fmt.Errorf("%w: %v", ErrCorruptModel, err)
+		}
+		if blob.Chain == nil {
+			return MarkovChain{}, fmt.Errorf("%w: no chain", ErrCorruptModel)
+		}
+		return loadModelBlob(blob, sentinels)
+	}
+	return MarkovChain{}, fmt.Errorf("%w: format %d, this build reads up to %d", ErrUnsupportedModelFormat, format, ModelFormat)
+}
+
+// loadModelBlob builds a MarkovChain from the chains and settings of blob,
+// delimited by sentinels unless blob records its own
+func loadModelBlob(blob modelBlob, sentinels Sentinels) (MarkovChain, error) {
+	if blob.Sentinels != nil {
+		sentinels = *blob.Sentinels
+	}
+	chain, err := unmarshalGomarkovBackend(blob.Chain)
 	if err != nil {
+		return MarkovChain{}, fmt.Errorf("%w: %v", ErrCorruptModel, err)
+	}
+	if err := validateSentinels(blob.Chain, sentinels); err != nil {
 		return MarkovChain{}, err
 	}
-	return MarkovChain{chain: &chain}, nil
+	var titles ChainBackend
+	if blob.Titles != nil {
+		titleChain, err := unmarshalGomarkovBackend(blob.Titles)
+		if err != nil {
+			return MarkovChain{}, fmt.Errorf("%w: invalid title model: %v", ErrCorruptModel, err)
+		}
+		titles = titleChain
+		if err := validateSentinels(blob.Titles, sentinels); err != nil {
+			return MarkovChain{}, fmt.Errorf("%w: invalid title model: %v", ErrCorruptModel, err)
+		}
+	}
+	var bigrams ChainBackend
+	if blob.Bigrams != nil {
+		bigramChain, err := unmarshalGomarkovBackend(blob.Bigrams)
+		if err != nil {
+			return MarkovChain{}, fmt.Errorf("%w: invalid bigram model: %v", ErrCorruptModel, err)
+		}
+		bigrams = bigramChain
+	}
+	return MarkovChain{
+		chain:      chain,
+		titles:     titles,
+		bigrams:    bigrams,
+		sentinels:  sentinels,
+		lowercase:  blob.Lowercase,
+		paragraphs: blob.Paragraphs,
+		headings:   blob.Headings,
+		normalize:  blob.Normalize,
+		options:    blob.Generate,
+	}, nil
+}
+
+// validateSentinels checks that both sentinel tokens are states in the serialized chain
+func validateSentinels(data []byte, sentinels Sentinels) error {
+	var states struct {
+		SpoolMap map[string]int `json:"spool_map"`
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		return fmt.Errorf("%w: %v", ErrCorruptModel, err)
+	}
+	if len(states.SpoolMap) == 0 {
+		return ErrEmptyModel
+	}
+	if _, ok := states.SpoolMap[sentinels.Start]; !ok {
+		return fmt.Errorf("%w: start token %q not found in model", ErrCorruptModel, sentinels.Start)
+	}
+	if _, ok := states.SpoolMap[sentinels.End]; !ok {
+		return fmt.Errorf("%w: end token %q not found in model", ErrCorruptModel, sentinels.End)
+	}
+	return nil
 }
 
 func SerializeModel(chain MarkovChain) ([]byte, error) {
-	return json.Marshal(chain.chain)
+	chainData, err := marshalBackend(chain.chain)
+	if err != nil {
+		return nil, err
+	}
+	var titleData []byte
+	if chain.titles != nil {
+		titleData, err = marshalBackend(chain.titles)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var bigramData []byte
+	if chain.bigrams != nil {
+		bigramData, err = marshalBackend(chain.bigrams)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var sentinels *Sentinels
+	if s := chain.Sentinels(); s != DefaultSentinels {
+		sentinels = &s
+	}
+	return json.Marshal(modelBlob{
+		Format:     ModelFormat,
+		Chain:      chainData,
+		Titles:     titleData,
+		Bigrams:    bigramData,
+		Lowercase:  chain.lowercase,
+		Paragraphs: chain.paragraphs,
+		Headings:   chain.headings,
+		Normalize:  chain.normalize,
+		Generate:   chain.options,
+		Sentinels:  sentinels,
+	})
 }
 
 func GenerateStory(prngSeed int64, chain MarkovChain) (string, *rand.Rand, error) {
 	prng := rand.New(rand.NewSource(prngSeed))
-	tokens := []string{gomarkov.StartToken}
-	for tokens[len(tokens)-1] != gomarkov.EndToken {
-		next, _ := chain.chain.GenerateDeterministic(tokens[(len(tokens)-1):], prng)
-		tokens = append(tokens, next)
+	story, err := GenerateStoryWithSentinels(prng, chain, chain.Sentinels())
+	return story, prng, err
+}
+
+// GenerateRawTokens returns the tokens GenerateStory walks for prngSeed,
+// including the start and end sentinels and any ParagraphMarker, before they
+// are joined into a sentence
+func GenerateRawTokens(prngSeed int64, chain MarkovChain) ([]string, error) {
+	prng := rand.New(rand.NewSource(prngSeed))
+	sentinels := chain.Sentinels()
+	tokens, err := generateTokens(MarkovGenerator{}, prng, chain, sentinels, nil, MaxSentenceWords)
+	if err != nil {
+		return nil, err
 	}
-	return strings.Join(tokens[1:len(tokens)-1], " "), prng, nil
+	raw := append([]string{sentinels.Start}, tokens...)
+	return append(raw, sentinels.End), nil
 }
 
 func GenerateStoryFromPrng(prng *rand.Rand, chain MarkovChain) (string, error) {
-	tokens := []string{gomarkov.StartToken}
-	for tokens[len(tokens)-1] != gomarkov.EndToken {
-		next, _ := chain.chain.GenerateDeterministic(tokens[(len(tokens)-1):], prng)
+	return GenerateStoryWithSentinels(prng, chain, chain.Sentinels())
+}
+
+// GenerateStoryWithSentinels walks the chain from sentinels.Start until
+// sentinels.End, returning the tokens in between
+func GenerateStoryWithSentinels(prng *rand.Rand, chain MarkovChain, sentinels Sentinels) (string, error) {
+	tokens, err := generateTokens(MarkovGenerator{}, prng, chain, sentinels, nil, MaxSentenceWords)
+	if err != nil {
+		return "", err
+	}
+	return chain.finishSentence(tokens), nil
+}
+
+// RepetitionPenalty is the chance, from 0 to 1, that a token already used on
+// the page is redrawn while generating the page body. Zero disables it.
+var RepetitionPenalty = 0.0
+
+// tokenHistory counts the tokens already used on a page so later sentences
+// can avoid repeating them
+type tokenHistory struct {
+	counts map[string]int
+
+	// penalty is the chance, from 0 to 1, that a repeated token is redrawn
+	penalty float64
+}
+
+func newTokenHistory(penalty float64) *tokenHistory {
+	return &tokenHistory{counts: map[string]int{}, penalty: penalty}
+}
+
+// add counts each word token
+func (h *tokenHistory) add(tokens []string) {
+	for _, token := range tokens {
+		if token != ParagraphMarker {
+			h.counts[token]++
+		}
+	}
+}
+
+// generateTokens returns the raw tokens of one generated sentence, each
+// chosen by picker, without sentinels but including any ParagraphMarker.
+// Tokens already in history are down-weighted by its penalty, and the new
+// tokens are added to it. A nil history disables both. The sentence is cut
+// after maxWords words when maxWords is positive.
+func generateTokens(picker tokenPicker, prng *rand.Rand, chain MarkovChain, sentinels Sentinels, history *tokenHistory, maxWords int) ([]string, error) {
+	return continueTokens(picker, prng, chain, sentinels, nil, history, maxWords)
+}
+
+// continueTokens is generateTokens for a sentence that begins with prefix,
+// whose last token must be a state of the chain
+func continueTokens(picker tokenPicker, prng *rand.Rand, chain MarkovChain, sentinels Sentinels, prefix []string, history *tokenHistory, maxWords int) ([]string, error) {
+	tokens := append([]string{sentinels.Start}, prefix...)
+	words := len(prefix)
+	for tokens[len(tokens)-1] != sentinels.End {
+		if maxWords > 0 && words >= maxWords {
+			// Keep the start sentinel, which is stripped with the end below
+			tokens = append([]string{sentinels.Start}, append(capSentence(tokens[1:]), sentinels.End)...)
+			break
+		}
+		if len(tokens) > MaxStoryTokens {
+			if ClampToSentence {
+				return clampToSentence(tokens[1:]), nil
+			}
+			return nil, countFailure(fmt.Errorf("%w: stopped after %d tokens", ErrGenerationCapExceeded, MaxStoryTokens))
+		}
+		next, err := picker.pickToken(chain, tokens, prng)
+		if err != nil {
+			return nil, err
+		}
+		if history != nil && history.counts[next] > 0 && next != sentinels.End && prng.Float64() < history.penalty {
+			// Give the transition one more draw so repeats become less likely
+			next, err = picker.pickToken(chain, tokens, prng)
+			if err != nil {
+				return nil, err
+			}
+		}
 		tokens = append(tokens, next)
+		if next != sentinels.End && next != ParagraphMarker {
+			words++
+		}
+	}
+	tokens = tokens[1 : len(tokens)-1]
+	if history != nil {
+		history.add(tokens)
+	}
+	return tokens, nil
+}
+
+// GenerateStoryFromPrefix generates a sentence that begins with prefix. When
+// the model has never seen the prefix's last word, it falls back to an
+// ordinary sentence from the start token.
+func GenerateStoryFromPrefix(prng *rand.Rand, chain MarkovChain, prefix string) (string, error) {
+	tokens, err := generateTokensFromPrefix(MarkovGenerator{}, prng, chain, chain.Sentinels(), prefix, nil, MaxSentenceWords)
+	if err != nil {
+		return "", err
 	}
-	return strings.Join(tokens[1:len(tokens)-1], " "), nil
+	return chain.finishSentence(tokens), nil
+}
+
+// generateTokensFromPrefix is continueTokens for a user-supplied phrase,
+// falling back to generateTokens when the phrase can't be continued
+func generateTokensFromPrefix(picker tokenPicker, prng *rand.Rand, chain MarkovChain, sentinels Sentinels, prefix string, history *tokenHistory, maxWords int) ([]string, error) {
+	if chain.lowercase {
+		prefix = strings.ToLower(prefix)
+	}
+	words := strings.Fields(prefix)
+	if len(words) == 0 || !chain.hasState(words[len(words)-1]) {
+		return generateTokens(picker, prng, chain, sentinels, history, maxWords)
+	}
+	return continueTokens(picker, prng, chain, sentinels, words, history, maxWords)
+}
+
+// firstOption is a PRNG that always picks the first transition, used to probe
+// the chain without consuming a caller's random numbers
+type firstOption struct{}
+
+func (firstOption) Intn(int) int { return 0 }
+
+// hasState reports whether token is a state the chain can continue from
+func (m MarkovChain) hasState(token string) bool {
+	if token == m.Sentinels().End {
+		return false
+	}
+	_, err := m.chain.GenerateDeterministic(gomarkov.NGram{token}, firstOption{})
+	return err == nil
+}
+
+// nextToken draws the token that follows the last of tokens, using the last
+// two when the model has a bigram chain that has seen them. A start token the
+// chain doesn't know means the model is empty; any other unknown or exhausted
+// state is a dead end.
+func (m MarkovChain) nextToken(tokens []string, prng gomarkov.PRNG) (string, error) {
+	if m.bigrams != nil {
+		if next, ok := m.nextBigramToken(tokens, prng); ok {
+			return next, nil
+		}
+	}
+
+	current := tokens[len(tokens)-1]
+	next, err := m.chain.GenerateDeterministic(gomarkov.NGram{current}, guardedPRNG{prng})
+	if err != nil {
+		if current == m.Sentinels().Start {
+			return "", countFailure(fmt.Errorf("%w: %v", ErrEmptyModel, err))
+		}
+		return "", countFailure(fmt.Errorf("%w: %v", ErrDeadEndState, err))
+	}
+	if next == "" {
+		return "", countFailure(fmt.Errorf("%w: no transitions from %q", ErrDeadEndState, current))
+	}
+	return next, nil
+}
+
+// nextBigramToken draws the token that follows the last two of tokens from
+// the bigram chain, reporting false when it has never seen that pair. The
+// first token is paired with the start sentinel, as the chain was trained.
+func (m MarkovChain) nextBigramToken(tokens []string, prng gomarkov.PRNG) (string, bool) {
+	previous := m.Sentinels().Start
+	if len(tokens) > 1 {
+		previous = tokens[len(tokens)-2]
+	}
+	next, err := m.bigrams.GenerateDeterministic(gomarkov.NGram{previous, tokens[len(tokens)-1]}, guardedPRNG{prng})
+	return next, err == nil && next != ""
+}
+
+// endsParagraph reports whether generated tokens close a paragraph
+func endsParagraph(tokens []string) bool {
+	return len(tokens) > 0 && tokens[len(tokens)-1] == ParagraphMarker
+}
+
+// clampToSentence trims truncated tokens back to the last one ending a
+// sentence. With no complete sentence the tokens are kept and an ellipsis added.
+func clampToSentence(tokens []string) []string {
+	failureCounts.clamped.Add(1)
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if EndsSentence(tokens[i]) {
+			return tokens[:i+1]
+		}
+	}
+	if len(tokens) == 0 {
+		return tokens
+	}
+	clamped := slices.Clone(tokens)
+	clamped[len(clamped)-1] += "..."
+	return clamped
+}
+
+// capSentence ends tokens, a sentence cut short by MaxSentenceWords, with a
+// terminator in place of any trailing comma or dash
+func capSentence(tokens []string) []string {
+	if len(tokens) == 0 || EndsSentence(tokens[len(tokens)-1]) {
+		return tokens
+	}
+	terminator := "."
+	if len(SentenceTerminators) > 0 && SentenceTerminators[0] != "" {
+		terminator = SentenceTerminators[0]
+	}
+	capped := slices.Clone(tokens)
+	last := strings.TrimRight(capped[len(capped)-1], ",;:-–—")
+	if last == "" {
+		last = capped[len(capped)-1]
+	}
+	capped[len(capped)-1] = last + terminator
+	return capped
+}
+
+// EndsSentence reports whether word ends with one of SentenceTerminators,
+// ignoring any trailing ClosingPunctuation
+func EndsSentence(word string) bool {
+	word = strings.TrimRight(word, ClosingPunctuation)
+	for _, terminator := range SentenceTerminators {
+		if terminator != "" && strings.HasSuffix(word, terminator) {
+			return true
+		}
+	}
+	return false
+}
+
+// finishSentence joins generated tokens, dropping any paragraph marker and
+// restoring the leading capital for models trained with lowercased tokens
+func (m MarkovChain) finishSentence(tokens []string) string {
+	if endsParagraph(tokens) {
+		tokens = tokens[:len(tokens)-1]
+	}
+	sentence := strings.Join(tokens, " ")
+	if !m.lowercase || sentence == "" {
+		return sentence
+	}
+	first, size := utf8.DecodeRuneInString(sentence)
+	return string(unicode.ToUpper(first)) + sentence[size:]
 }
 
 func GenerateStoryBasic(chain MarkovChain) (string, error) {
-	tokens := []string{gomarkov.StartToken}
-	for tokens[len(tokens)-1] != gomarkov.EndToken {
-		next, _ := chain.chain.Generate(tokens[(len(tokens) - 1):])
+	sentinels := chain.Sentinels()
+	tokens := []string{sentinels.Start}
+	for tokens[len(tokens)-1] != sentinels.End {
+		if len(tokens) > MaxStoryTokens {
+			if ClampToSentence {
+				return chain.finishSentence(clampToSentence(tokens[1:])), nil
+			}
+			return "", countFailure(fmt.Errorf("%w: stopped after %d tokens", ErrGenerationCapExceeded, MaxStoryTokens))
+		}
+		next, err := chain.nextToken(tokens, globalPRNG{})
+		if err != nil {
+			return "", err
+		}
 		fmt.Println(next)
 		// time.Sleep(100 * time.Millisecond)
 		tokens = append(tokens, next)
 	}
-	return strings.Join(tokens[1:len(tokens)-1], " "), nil
+	return chain.finishSentence(tokens[1 : len(tokens)-1]), nil
 }
@@ -0,0 +1,328 @@
+package train
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mb-14/gomarkov"
+)
+
+func TestChapterHeadingPattern(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"CHAPTER I", true},
+		{"Chapter XIV.", true},
+		{"  chapter mcmxcix", true},
+		{"Part 2: The Return", true},
+		{"BOOK IV: Of Kings", true},
+		{"Section 12", true},
+		{"Part civil war broke out.", false},
+		{"Book mild and gentle", false},
+		{"Chapter iiii", false},
+		{"Section 3 of the law says otherwise.", false},
+		{"Part of the problem", false},
+		{"Chapter XL", true},
+		{"Part MCMXCIX: Coda", true},
+		{"Chapter", false},
+		{"Chapter .", false},
+		{"Part : The Return", false},
+		{"Chapter and verse were all he knew.", false},
+		{"Chapter one began at dawn.", false},
+	}
+	for _, tt := range tests {
+		if got := HeadingPatterns[0].MatchString(tt.line); got != tt.want {
+			t.Errorf("%q: matched %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestStripHeadingsKeepsProse(t *testing.T) {
+	input := "CHAPTER I\n\nPart civil war broke out.\nChapter and verse were quoted.\nThe end came.\n\n42\n"
+	want := "\nPart civil war broke out.\nChapter and verse were quoted.\nThe end came.\n\n"
+	if got := stripHeadings(input); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestImportedModelSentinels(t *testing.T) {
+	sentinels := Sentinels{Start: "<s>", End: "</s>"}
+	chain := gomarkov.NewChain(1)
+	for _, sentence := range []string{"The cat sat.", "The dog ran.", "A bird sang."} {
+		chain.Add(append(append([]string{sentinels.Start}, strings.Fields(sentence)...), sentinels.End))
+	}
+	data, err := json.Marshal(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := LoadImportedModel(data, sentinels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := SerializeModel(imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := LoadModel(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.Sentinels() != sentinels {
+		t.Fatalf("the saved model has sentinels %+v, want %+v", model.Sentinels(), sentinels)
+	}
+
+	for seed := int64(0); seed < 20; seed++ {
+		story, _, err := GenerateStory(seed, model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if story == "" || strings.Contains(story, sentinels.Start) || strings.Contains(story, sentinels.End) {
+			t.Errorf("seed %d: generated %q", seed, story)
+		}
+	}
+}
+
+func TestLoadModelFormats(t *testing.T) {
+	corpus := "The cat sat. The dog ran. A bird sang."
+	model, err := BuildModel(corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	versioned, err := SerializeModel(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blob map[string]json.RawMessage
+	if err := json.Unmarshal(versioned, &blob); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(blob["format"]); got != strconv.Itoa(ModelFormat) {
+		t.Fatalf("saved with format %s, want %d", got, ModelFormat)
+	}
+
+	// Blobs saved before the format field was added
+	delete(blob, "format")
+	headerless, err := json.Marshal(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Models saved before modelBlob existed are the gomarkov chain itself
+	chain := gomarkov.NewChain(1)
+	for _, sentence := range strings.Split(corpus, ". ") {
+		chain.Add(strings.Fields(strings.TrimSuffix(sentence, ".") + "."))
+	}
+	legacy, err := json.Marshal(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{"versioned": versioned, "headerless": headerless, "legacy": legacy} {
+		loaded, err := LoadModel(data)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		story, _, err := GenerateStory(1, loaded)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		for _, sentence := range strings.SplitAfter(story, ".") {
+			if sentence = strings.TrimSpace(sentence); sentence != "" && !strings.Contains(corpus, sentence) {
+				t.Errorf("%s: generated %q, which isn't in the corpus", name, sentence)
+			}
+		}
+	}
+
+	blob["format"] = json.RawMessage(strconv.Itoa(ModelFormat + 1))
+	future, err := json.Marshal(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadModel(future); !errors.Is(err, ErrUnsupportedModelFormat) {
+		t.Errorf("loading a future format: got %v, want ErrUnsupportedModelFormat", err)
+	}
+}
+
+// runawayModel returns a model whose sentences start with first and then
+// almost always repeat "go" far past MaxStoryTokens
+func runawayModel(t *testing.T, first ...string) MarkovChain {
+	t.Helper()
+	sentinels := Sentinels{Start: "<s>", End: "</s>"}
+	tokens := append([]string{sentinels.Start}, first...)
+	for i := 0; i < 100*MaxStoryTokens; i++ {
+		tokens = append(tokens, "go")
+	}
+	chain := gomarkov.NewChain(1)
+	chain.Add(append(tokens, sentinels.End))
+	data, err := json.Marshal(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := LoadImportedModel(data, sentinels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model
+}
+
+func TestClampToSentence(t *testing.T) {
+	old := ClampToSentence
+	t.Cleanup(func() { ClampToSentence = old })
+
+	ClampToSentence = false
+	if _, _, err := GenerateStory(1, runawayModel(t, "Hi", "there.")); !errors.Is(err, ErrGenerationCapExceeded) {
+		t.Fatalf("without clamping got %v, want ErrGenerationCapExceeded", err)
+	}
+
+	ClampToSentence = true
+	story, _, err := GenerateStory(1, runawayModel(t, "Hi", "there."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if story != "Hi there." {
+		t.Errorf("got %.40q, want the complete sentence \"Hi there.\"", story)
+	}
+
+	// Without a complete sentence the text is kept and marked as cut off
+	story, _, err = GenerateStory(1, runawayModel(t, "Hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(story, "Hi go go") || !strings.HasSuffix(story, "go...") {
+		t.Errorf("got %.40q...%q, want the words ending with an ellipsis", story, story[max(0, len(story)-10):])
+	}
+}
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{`He said "Stop." She stopped.`, []string{`He said "Stop."`, `She stopped.`}},
+		{`Well… maybe. (Or not.) Fine!`, []string{`Well…`, `maybe.`, `(Or not.)`, `Fine!`}},
+		{`It rained。 We stayed in？ Yes`, []string{`It rained。`, `We stayed in？`, `Yes`}},
+		{`"Really?" ‘Really.’ Done`, []string{`"Really?"`, `‘Really.’`, `Done`}},
+		{`No terminator here`, []string{`No terminator here`}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, sentence := range splitSentences(strings.Fields(tt.text)) {
+			got = append(got, strings.Join(sentence, " "))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("splitSentences(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestConfiguredTerminators(t *testing.T) {
+	defer func(terminators []string) { SentenceTerminators = terminators }(SentenceTerminators)
+	SentenceTerminators = []string{";"}
+	if !EndsSentence("done;") || !EndsSentence(`"done;"`) {
+		t.Error("a configured terminator doesn't end a sentence")
+	}
+	if EndsSentence("done.") {
+		t.Error("a terminator that was configured away still ends a sentence")
+	}
+}
+
+// successors returns the tokens the chain has seen follow token
+func successors(t *testing.T, chain MarkovChain, token string) []string {
+	t.Helper()
+	data, err := exportBackend(chain.chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[int]string{}
+	for name, index := range data.SpoolMap {
+		names[index] = name
+	}
+	var next []string
+	for index := range data.FreqMat[data.SpoolMap[token]] {
+		next = append(next, names[index])
+	}
+	slices.Sort(next)
+	return next
+}
+
+func TestParagraphMarkers(t *testing.T) {
+	input := "Alpha one. Alpha two.\n\nBeta one. Beta two.\n  \nGamma end."
+	end := DefaultSentinels.End
+	tests := []struct {
+		name       string
+		paragraphs bool
+		next       map[string]string
+	}{
+		{name: "flat", next: map[string]string{"one.": end, "two.": end, "end.": end}},
+		{name: "paragraphs", paragraphs: true, next: map[string]string{"one.": end, "two.": ParagraphMarker, "end.": ParagraphMarker, ParagraphMarker: end}},
+	}
+	for _, tt := range tests {
+		chain, err := BuildModelWithOptions(input, TrainOptions{Paragraphs: tt.paragraphs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Only the last sentence of each paragraph is followed by a marker
+		for token, want := range tt.next {
+			if got := successors(t, chain, token); len(got) != 1 || got[0] != want {
+				t.Errorf("%s: %q is followed by %q, want only %q", tt.name, token, got, want)
+			}
+		}
+		if _, ok := vocabulary(t, chain.chain)[ParagraphMarker]; ok != tt.paragraphs {
+			t.Errorf("%s: records paragraph markers %v", tt.name, ok)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	// Ebook punctuation with a non-breaking space after "it’s"
+	corpus := "“Hello,” she said — it’s fine… I don’t know."
+
+	raw, err := BuildModelWithOptions(corpus, TrainOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without the option the text is kept as it was written
+	words := vocabulary(t, raw.chain)
+	for _, token := range []string{"“Hello,”", "—", "it’s", "fine…", "don’t"} {
+		if _, ok := words[token]; !ok {
+			t.Errorf("the unnormalized model lacks %q", token)
+		}
+	}
+
+	normalized, err := BuildModelWithOptions(corpus, TrainOptions{Normalize: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	words = vocabulary(t, normalized.chain)
+	for _, token := range []string{`"Hello,"`, "--", "it's", "fine...", "don't"} {
+		if _, ok := words[token]; !ok {
+			t.Errorf("the normalized model lacks %q", token)
+		}
+	}
+	for token := range words {
+		if strings.ContainsAny(token, "“”‘’—… ") {
+			t.Errorf("the normalized model has the token %q", token)
+		}
+	}
+
+	// Seeds given to a saved model are normalized like its training text
+	data, err := SerializeModel(normalized)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadModel(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.tokenize("don’t"); len(got) != 1 || !slices.Equal(got[0][0], []string{"don't"}) {
+		t.Errorf("the loaded model tokenized %q as %q, want %q", "don’t", got, "don't")
+	}
+}
@@ -0,0 +1,128 @@
+package train
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"sync"
+	"unicode"
+)
+
+// tokenCount is how often a token follows some state in a chain
+type tokenCount struct {
+	token string
+	count int
+}
+
+// maxTrendingTables bounds the cache of ranked tokens, which gains an entry
+// per chain backend ranked and is cleared when full
+const maxTrendingTables = 8
+
+var trendingTables = struct {
+	sync.Mutex
+	tables map[ChainBackend][]tokenCount
+}{tables: map[ChainBackend][]tokenCount{}}
+
+// TrendingSeeds returns up to n seeds for the model's "trending" stories. Each
+// is hashed from one of the model's most frequent words and its count, so the
+// list is the same on every request and changes when the model is retrained.
+// A model with fewer than n words gives fewer seeds.
+func TrendingSeeds(chain MarkovChain, n int) ([]int64, error) {
+	ranked, err := rankedTokens(chain)
+	if err != nil {
+		return nil, err
+	}
+	seeds := []int64{}
+	for _, tc := range ranked {
+		if len(seeds) >= n {
+			break
+		}
+		hash := fnv.New64a()
+		fmt.Fprintf(hash, "%s\x00%d", tc.token, tc.count)
+		// Keep seeds non-negative so they round trip through the url
+		seeds = append(seeds, int64(hash.Sum64()>>1))
+	}
+	return seeds, nil
+}
+
+// GenerateTrendingPosts generates the pages for TrendingSeeds, most frequent
+// word first
+func GenerateTrendingPosts(chain MarkovChain, n int) ([]GeneratedPage, error) {
+	seeds, err := TrendingSeeds(chain, n)
+	if err != nil {
+		return nil, err
+	}
+	posts := []GeneratedPage{}
+	for _, seed := range seeds {
+		post, err := GeneratePage(seed, chain)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, nil
+}
+
+// rankedTokens lists the words of the body chain by how often they follow
+// some state, most frequent first with ties in alphabetical order. Sentinels,
+// paragraph markers and tokens without a letter, such as punctuation, are
+// left out.
+func rankedTokens(chain MarkovChain) ([]tokenCount, error) {
+	backend := chain.chain
+	cacheable := reflect.TypeOf(backend).Comparable()
+	if cacheable {
+		trendingTables.Lock()
+		ranked, ok := trendingTables.tables[backend]
+		trendingTables.Unlock()
+		if ok {
+			return ranked, nil
+		}
+	}
+
+	data, err := exportChain(chain)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[int]int, len(data.SpoolMap))
+	for _, transitions := range data.FreqMat {
+		for next, count := range transitions {
+			counts[next] += count
+		}
+	}
+
+	sentinels := chain.Sentinels()
+	ranked := []tokenCount{}
+	for token, index := range data.SpoolMap {
+		if counts[index] == 0 || token == sentinels.Start || token == sentinels.End || token == ParagraphMarker || !hasLetter(token) {
+			continue
+		}
+		ranked = append(ranked, tokenCount{token: token, count: counts[index]})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].count != ranked[j].count {
+			return ranked[i].count > ranked[j].count
+		}
+		return ranked[i].token < ranked[j].token
+	})
+
+	if cacheable {
+		trendingTables.Lock()
+		if len(trendingTables.tables) >= maxTrendingTables {
+			clear(trendingTables.tables)
+		}
+		trendingTables.tables[backend] = ranked
+		trendingTables.Unlock()
+	}
+	return ranked, nil
+}
+
+// hasLetter reports whether token contains a letter
+func hasLetter(token string) bool {
+	for _, r := range token {
+		if unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
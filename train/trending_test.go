@@ -0,0 +1,46 @@
+package train
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTrendingSeeds(t *testing.T) {
+	seedsFor := func(corpus string, n int) []int64 {
+		t.Helper()
+		chain, err := BuildModel(corpus)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seeds, err := TrendingSeeds(chain, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return seeds
+	}
+
+	first := seedsFor(pruneCorpus, 3)
+	if len(first) != 3 {
+		t.Fatalf("got %d seeds, want 3", len(first))
+	}
+	for _, seed := range first {
+		if seed < 0 {
+			t.Errorf("seed %d is negative", seed)
+		}
+	}
+
+	// The same corpus trained again is a different chain with the same words
+	if again := seedsFor(pruneCorpus, 3); !slices.Equal(first, again) {
+		t.Errorf("seeds changed for the same model: %v, then %v", first, again)
+	}
+
+	retrained := seedsFor(pruneCorpus+"\n\nThe dog sat on the mat.", 3)
+	if slices.Equal(first, retrained) {
+		t.Errorf("seeds %v didn't change when the model did", first)
+	}
+
+	// A small model gives one seed per word rather than n
+	if small := seedsFor("Hello world.", 10); len(small) != 2 {
+		t.Errorf("got %d seeds for a two word model, want 2", len(small))
+	}
+}
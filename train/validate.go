@@ -0,0 +1,87 @@
+package train
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Thresholds below or above which ValidateCorpus warns
+const (
+	// MinCorpusSentences is the fewest sentences that give varied stories
+	MinCorpusSentences = 20
+
+	// MaxTokenLength is the longest token, in characters, expected in prose.
+	// Longer ones are usually URLs, markup or text with missing spaces.
+	MaxTokenLength = 40
+)
+
+// CorpusReport describes how a corpus would be tokenized for training
+type CorpusReport struct {
+	// Tokens is the number of words, punctuation included
+	Tokens int `json:"tokens"`
+
+	// Vocabulary is the number of distinct tokens
+	Vocabulary int `json:"vocabulary"`
+
+	// Sentences is the number of sentences, counting trailing words
+	// without terminal punctuation as one
+	Sentences int `json:"sentences"`
+
+	// LongestToken is the length in characters of the longest token
+	LongestToken int `json:"longest_token"`
+
+	// Warnings explain why the corpus may give a poor model
+	Warnings []string `json:"warnings"`
+}
+
+// ValidateCorpus tokenizes input exactly as training with opts would and
+// reports its size and problems, without building a model
+func ValidateCorpus(input string, opts TrainOptions) CorpusReport {
+	report := CorpusReport{Warnings: []string{}}
+	vocabulary := map[string]bool{}
+	terminated := false
+	longTokens := 0
+	longExample := ""
+	for _, sentences := range tokenizeText(input, opts) {
+		for _, sentence := range sentences {
+			report.Sentences++
+			for _, token := range sentence {
+				report.Tokens++
+				vocabulary[token] = true
+				length := utf8.RuneCountInString(token)
+				report.LongestToken = max(report.LongestToken, length)
+				if length > MaxTokenLength {
+					if longTokens == 0 {
+						longExample = token
+					}
+					longTokens++
+				}
+			}
+			terminated = terminated || EndsSentence(sentence[len(sentence)-1])
+		}
+	}
+	report.Vocabulary = len(vocabulary)
+
+	switch {
+	case report.Tokens == 0:
+		report.Warnings = append(report.Warnings, "the corpus has no words")
+	case !terminated:
+		report.Warnings = append(report.Warnings, "no sentence ends in terminal punctuation, so the whole corpus is one sentence")
+	case report.Sentences < MinCorpusSentences:
+		report.Warnings = append(report.Warnings, fmt.Sprintf("only %d sentences; at least %d give varied stories", report.Sentences, MinCorpusSentences))
+	}
+	if longTokens == 1 {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("1 token is longer than %d characters: %q", MaxTokenLength, truncateToken(longExample)))
+	} else if longTokens > 1 {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("%d tokens are longer than %d characters, such as %q", longTokens, MaxTokenLength, truncateToken(longExample)))
+	}
+	return report
+}
+
+// truncateToken shortens a token quoted in a warning
+func truncateToken(token string) string {
+	if utf8.RuneCountInString(token) <= MaxTokenLength {
+		return token
+	}
+	return string([]rune(token)[:MaxTokenLength]) + "..."
+}
@@ -0,0 +1,68 @@
+package train
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateCorpusMatchesTraining(t *testing.T) {
+	const input = "One two. Three four\n\nFive six. Seven\n\nEight."
+	tests := []struct {
+		name          string
+		opts          TrainOptions
+		wantSentences int
+	}{
+		{name: "one paragraph", wantSentences: 3},
+		{name: "paragraphs", opts: TrainOptions{Paragraphs: true}, wantSentences: 5},
+		{name: "titles", opts: TrainOptions{Titles: true}, wantSentences: 5},
+		{name: "lowercase", opts: TrainOptions{Lowercase: true}, wantSentences: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := ValidateCorpus(input, tt.opts)
+			if report.Sentences != tt.wantSentences {
+				t.Errorf("got %d sentences, want %d", report.Sentences, tt.wantSentences)
+			}
+
+			chain, err := BuildModelWithOptions(input, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			trained := 0
+			for _, sentences := range chain.tokenize(input) {
+				trained += len(sentences)
+			}
+			if report.Sentences != trained {
+				t.Errorf("validation counts %d sentences, training %d", report.Sentences, trained)
+			}
+		})
+	}
+}
+
+func TestValidateCorpusWarnings(t *testing.T) {
+	long := strings.Repeat("x", MaxTokenLength+1)
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "empty", input: "  ", want: []string{"the corpus has no words"}},
+		{name: "unterminated", input: "no ending here", want: []string{"no sentence ends in terminal punctuation"}},
+		{name: "short", input: "A cat sat. A dog ran.", want: []string{"only 2 sentences"}},
+		{name: "long token", input: strings.Repeat("A cat sat. ", MinCorpusSentences) + long + ".", want: []string{"1 token is longer than"}},
+		{name: "clean", input: strings.Repeat("A cat sat. ", MinCorpusSentences)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := ValidateCorpus(tt.input, TrainOptions{})
+			if len(report.Warnings) != len(tt.want) {
+				t.Fatalf("got warnings %q, want %d", report.Warnings, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(report.Warnings[i], want) {
+					t.Errorf("warning %d is %q, want prefix %q", i, report.Warnings[i], want)
+				}
+			}
+		})
+	}
+}
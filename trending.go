@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"html"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/abigpotostew/endless/train"
+)
+
+const (
+	// trendingPostCount is how many posts the trending page lists
+	trendingPostCount = 10
+	// homeTrendingCount is how many trending posts the home page links to
+	homeTrendingCount = 5
+)
+
+// trendingHandler lists the model's trending stories. They are derived from
+// its most frequent words rather than the date, so the page only changes when
+// the model does.
+func (app *App) trendingHandler(w http.ResponseWriter, r *http.Request) {
+	posts, err := app.trendingPosts(trendingPostCount)
+	if errors.Is(err, errModelUnavailable) {
+		app.writeErrorPage(w, http.StatusServiceUnavailable, "Stories are temporarily unavailable. Please try again soon.")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to generate trending posts: %v", err)
+		app.writeErrorPage(w, generationErrorStatus(err), "Something went wrong while writing the trending stories.")
+		return
+	}
+
+	var postsHTML strings.Builder
+	for _, post := range posts {
+		postsHTML.WriteString(`
+            <li>
+                <a href="` + html.EscapeString(sitePath(post.Link.Url)) + `">` + html.EscapeString(post.Link.Title) + `</a>
+                <span class="author">by ` + html.EscapeString(post.Author) + `</span>
+                <p>` + html.EscapeString(app.excerpt(post.Content)) + `</p>
+            </li>`)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write([]byte(`<!DOCTYPE html>
+<html lang="` + html.EscapeString(app.siteLang) + `">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Trending - Endless Stories</title>
+    <meta name="description" content="The stories everyone is reading on Endless Stories.">
+    <meta property="og:type" content="website">
+    <meta property="og:url" content="` + html.EscapeString(app.fullURL(r)) + `">
+    <meta property="og:title" content="Trending - Endless Stories">
+    <meta property="og:site_name" content="Endless Stories">
+    <meta property="og:locale" content="` + html.EscapeString(app.siteLocale) + `">
+    <link rel="canonical" href="` + html.EscapeString(app.fullURL(r)) + `">
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            max-width: 800px;
+            margin: 0 auto;
+            padding: 20px;
+            line-height: 1.6;
+            color: #333;
+        }
+        a {
+            color: ` + defaultThemeColor + `;
+        }
+        .posts-list {
+            padding-left: 1.5em;
+        }
+        .posts-list li {
+            margin-bottom: 20px;
+        }
+        .posts-list a {
+            font-weight: bold;
+            text-decoration: none;
+        }
+        .posts-list .author {
+            color: #888;
+            font-size: 0.9em;
+        }
+        .posts-list p {
+            margin: 5px 0 0 0;
+            color: #666;
+        }
+    </style>
+</head>
+<body>
+    <nav><a href="` + html.EscapeString(sitePath("/")) + `">Endless Stories</a></nav>
+    <main>
+        <h1>Trending</h1>
+        <ol class="posts-list">` + postsHTML.String() + `
+        </ol>
+    </main>
+</body>
+</html>`))
+}
+
+// trendingPosts returns the first n of the current model's trending posts.
+// They only change with the model, so they are generated once per cache
+// generation and shared until the model cache is cleared.
+func (app *App) trendingPosts(n int) ([]train.GeneratedPage, error) {
+	app.cacheMu.Lock()
+	gen := app.cacheGen
+	cached := app.trending
+	if app.trendingGen != gen {
+		cached = nil
+	}
+	app.cacheMu.Unlock()
+
+	if cached == nil {
+		chain, err := app.loadLatestChain()
+		if err != nil {
+			return nil, err
+		}
+		posts, err := train.GenerateTrendingPosts(chain, trendingPostCount)
+		if err != nil {
+			return nil, err
+		}
+
+		app.cacheMu.Lock()
+		// A clear while generating means these posts may be for an old model
+		if app.cacheGen == gen {
+			app.trending = posts
+			app.trendingGen = gen
+		}
+		app.cacheMu.Unlock()
+		cached = posts
+	}
+	return cached[:min(n, len(cached))], nil
+}
+
+// trendingSectionHTML renders the home page's short list of trending posts,
+// linking to the full list
+func trendingSectionHTML(posts []train.GeneratedPage) string {
+	if len(posts) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(`
+    <div class="trending">
+        <h2>Trending</h2>
+        <ol>`)
+	for _, post := range posts {
+		b.WriteString(`
+            <li><a href="` + html.EscapeString(sitePath(post.Link.Url)) + `">` + html.EscapeString(post.Link.Title) + `</a></li>`)
+	}
+	b.WriteString(`
+        </ol>
+        <a href="` + html.EscapeString(sitePath("/trending")) + `" class="trending-more">All trending stories</a>
+    </div>
+    `)
+	return b.String()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/abigpotostew/endless/store"
+	"github.com/abigpotostew/endless/train"
+)
+
+// testCorpus is small, but every word has a way out so generation ends
+const testCorpus = "The cat saw the cat saw the cat saw the dog. The dog ran. I saw the dog. The cat saw a bird. A bird sang. My dog sat."
+
+// newTestApp returns an App backed by a fresh database holding a model
+// trained on corpus
+func newTestApp(t *testing.T, corpus string) *App {
+	t.Helper()
+	postStore, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { postStore.Close() })
+
+	app := &App{store: postStore, siteLang: "en", siteLocale: "en_US", excerptLength: 150}
+	if _, err := app.trainModel(corpus, train.TrainOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+func seedsOf(posts []train.GeneratedPage) []int64 {
+	seeds := make([]int64, len(posts))
+	for i, post := range posts {
+		seeds[i] = post.Link.Seed
+	}
+	return seeds
+}
+
+func TestTrendingPostsFollowTheModel(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+
+	first, err := app.trendingPosts(trendingPostCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) == 0 {
+		t.Fatal("no trending posts")
+	}
+	home, err := app.trendingPosts(homeTrendingCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := min(homeTrendingCount, len(first)); len(home) != want {
+		t.Errorf("got %d posts for the home page, want %d", len(home), want)
+	}
+	if &home[0] != &first[0] {
+		t.Error("trending posts were generated again for the same model")
+	}
+
+	if _, err := app.trainModel(testCorpus+" The bird saw my cat. My cat ran.", train.TrainOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	retrained, err := app.trendingPosts(trendingPostCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Equal(seedsOf(first), seedsOf(retrained)) {
+		t.Errorf("trending seeds %v didn't change with the model", seedsOf(first))
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// getVariants asks the variants handler for the stories of target
+func getVariants(app *App, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	app.variantsHandler(rec, httptest.NewRequest("GET", target, nil))
+	return rec
+}
+
+func TestVariantsAreDistinct(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	rec := getVariants(app, "/api/variants?seed=100&n=5")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	var variants []PostResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &variants); err != nil {
+		t.Fatal(err)
+	}
+	if len(variants) != 5 {
+		t.Fatalf("got %d variants, want 5", len(variants))
+	}
+	urls := map[string]bool{}
+	for i, variant := range variants {
+		if variant.Seed != int64(100+i) {
+			t.Errorf("variant %d has seed %d, want %d", i, variant.Seed, 100+i)
+		}
+		if urls[variant.Url] {
+			t.Errorf("variant %d repeats %s", i, variant.Url)
+		}
+		urls[variant.Url] = true
+	}
+}
+
+func TestVariantsSeedOverflow(t *testing.T) {
+	app := newTestApp(t, testCorpus)
+	tests := []struct {
+		seed int64
+		n    int
+		want int
+	}{
+		{seed: math.MaxInt64, n: 1, want: http.StatusOK},
+		{seed: math.MaxInt64 - 4, n: 5, want: http.StatusOK},
+		{seed: math.MaxInt64 - 3, n: 5, want: http.StatusBadRequest},
+		{seed: math.MaxInt64, n: 2, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		target := "/api/variants?seed=" + strconv.FormatInt(tt.seed, 10) + "&n=" + strconv.Itoa(tt.n)
+		rec := getVariants(app, target)
+		if rec.Code != tt.want {
+			t.Errorf("%s: got status %d, want %d: %s", target, rec.Code, tt.want, rec.Body)
+			continue
+		}
+		if rec.Code != http.StatusOK {
+			continue
+		}
+		var variants []PostResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &variants); err != nil {
+			t.Fatal(err)
+		}
+		for _, variant := range variants {
+			if variant.Seed < 0 {
+				t.Errorf("%s: variant with negative seed %d", target, variant.Seed)
+			}
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package version
+
+import "time"
+
+// Version and Commit identify the build. They are set at link time, e.g.
+//
+//	go build -ldflags "-X github.com/abigpotostew/endless/version.Version=v1.2.0 -X github.com/abigpotostew/endless/version.Commit=$(git rev-parse --short HEAD)"
+var (
+	Version = "dev"
+	Commit  = "unknown"
+)
+
+// started is when the process loaded this package
+var started = time.Now()
+
+// Uptime returns how long the process has been running
+func Uptime() time.Duration {
+	return time.Since(started)
+}
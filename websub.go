@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// websubTimeout bounds a single publish request to the hub
+const websubTimeout = 10 * time.Second
+
+// notifyHub tells the configured WebSub hub that the home page has new
+// stories. It returns immediately; failures are only logged.
+func (app *App) notifyHub() {
+	if app.websubHub == "" {
+		return
+	}
+	go func() {
+		if err := publishToHub(app.websubHub, app.websubTopic); err != nil {
+			log.Printf("Failed to notify WebSub hub %s: %v", app.websubHub, err)
+			return
+		}
+		log.Printf("Notified WebSub hub %s of %s", app.websubHub, app.websubTopic)
+	}()
+}
+
+// publishToHub sends a WebSub publish request announcing that topic changed
+func publishToHub(hub, topic string) error {
+	client := &http.Client{Timeout: websubTimeout}
+	resp, err := client.PostForm(hub, url.Values{
+		"hub.mode": {"publish"},
+		"hub.url":  {topic},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("hub responded %s", resp.Status)
+	}
+	return nil
+}
+
+// setHubLinks advertises the hub on the topic so subscribers can find it
+func (app *App) setHubLinks(w http.ResponseWriter) {
+	if app.websubHub == "" {
+		return
+	}
+	w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="hub"`, app.websubHub))
+	w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="self"`, app.websubTopic))
+}
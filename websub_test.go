@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/abigpotostew/endless/train"
+)
+
+func TestTrainNotifiesHub(t *testing.T) {
+	published := make(chan url.Values, 1)
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		published <- r.PostForm
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer hub.Close()
+
+	app := newTestApp(t, testCorpus)
+	app.websubHub = hub.URL
+	app.websubTopic = "https://stories.example.com/feed.xml"
+	if _, err := app.trainModel(testCorpus, train.TrainOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case form := <-published:
+		if form.Get("hub.mode") != "publish" || form.Get("hub.url") != app.websubTopic {
+			t.Errorf("the hub got %v, want a publish of %s", form, app.websubTopic)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the hub wasn't notified")
+	}
+}